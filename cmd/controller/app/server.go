@@ -34,6 +34,7 @@ import (
 
 	"github.com/baidu/easyfaas/cmd/controller/options"
 	"github.com/baidu/easyfaas/pkg/controller"
+	genericserver "github.com/baidu/easyfaas/pkg/server"
 )
 
 func Run(runOptions *options.ControllerOptions) error {
@@ -45,7 +46,22 @@ func Run(runOptions *options.ControllerOptions) error {
 		return err
 	}
 	handler := wrapRouter(app, runOptions)
-	return fasthttp.ListenAndServe(addr, handler)
+	server := &fasthttp.Server{Handler: handler}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.ListenAndServe(addr)
+	}()
+
+	stopCh := genericserver.SetupSignalHandler()
+	select {
+	case err := <-errCh:
+		return err
+	case <-stopCh:
+		app.Drain()
+	case <-app.Drained():
+	}
+	logs.Info("controller drained, shutting down")
+	return server.Shutdown()
 }
 
 func Init(runOptions *options.ControllerOptions) (app *controller.Controller, err error) {
@@ -65,9 +81,19 @@ func wrapRouter(controller *controller.Controller, runOptions *options.Controlle
 	router.Get("/healthz", controller.HealthzHandler)
 
 	router.Post("/v1/functions/<functionName>/invocations", controller.InvokeHandler)
+	router.Post("/v1/functions/<functionName>/batch-invocations", controller.BatchInvokeHandler)
+	router.Post("/2015-03-31/functions/<functionName>/invocations", controller.LambdaInvokeHandler)
+	router.Get("/v1/invocations/<requestID>", controller.GetInvocationHandler)
+	router.Delete("/v1/invocations/<requestID>", controller.CancelInvocationHandler)
 	router.Get("/v1/runtimes", controller.ListRuntimesHandler)
 	router.Get("/v1/resource", controller.GetResourceHandler)
 	router.Post("/v1/runtimes/<runtimeID>/invalidate", controller.InvalidateRuntime)
+	router.Put("/v1/functions/<functionName>/provisioned", controller.PutProvisionedConcurrencyHandler)
+	router.Get("/v1/functions/<functionName>/provisioned", controller.GetProvisionedConcurrencyHandler)
+	router.Delete("/v1/functions/<functionName>/provisioned", controller.DeleteProvisionedConcurrencyHandler)
+	router.Get("/v1/debug/predictions", controller.ListPredictionsHandler)
+	router.Post("/v1/admin/drain", controller.DrainHandler)
+	router.Get("/v1/admin/breakers", controller.ListBreakersHandler)
 
 	if runOptions.HTTPEnhanced {
 		logs.V(9).Info("equipped with http trigger feature")
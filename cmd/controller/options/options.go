@@ -23,8 +23,13 @@ import (
 	"github.com/spf13/pflag"
 
 	"github.com/baidu/easyfaas/pkg/funclet/client"
+	"github.com/baidu/easyfaas/pkg/controller/destination"
+	"github.com/baidu/easyfaas/pkg/controller/eventqueue"
 	"github.com/baidu/easyfaas/pkg/controller/function"
+	"github.com/baidu/easyfaas/pkg/controller/predictor"
+	"github.com/baidu/easyfaas/pkg/controller/ratelimit"
 	"github.com/baidu/easyfaas/pkg/controller/registry"
+	"github.com/baidu/easyfaas/pkg/controller/resultstore"
 	"github.com/baidu/easyfaas/pkg/controller/rtctrl"
 	genericoptions "github.com/baidu/easyfaas/pkg/server/options"
 )
@@ -38,6 +43,11 @@ type ControllerOptions struct {
 	HttpTriggerRepositoryOptions *registry.Options
 	RuntimeConfigOptions         *rtctrl.RuntimeConfigOptions
 	AliasCacheOptions            *function.StorageCacheOptions
+	EventQueueOptions            *eventqueue.Options
+	PredictorOptions             *predictor.Options
+	ResultStoreOptions           *resultstore.Options
+	DestinationOptions           *destination.Options
+	RateLimitOptions             *ratelimit.Options
 	// Task cycle interval
 	// Units: seconds
 	TaskInterval int
@@ -46,6 +56,10 @@ type ControllerOptions struct {
 	// Units: seconds
 	MetricsTaskInterval int
 
+	// Max time in-flight invocations run to completion when draining
+	// Units: seconds
+	DrainTimeout int
+
 	// Runtime maximum idle time
 	// Units: seconds
 	MaxRuntimeIdle int
@@ -58,6 +72,47 @@ type ControllerOptions struct {
 	// Units: seconds
 	MaxRunnerResetTimeout int
 
+	// Idle time before a warm runtime is frozen, 0 means never freeze
+	// Units: seconds
+	RuntimeFreezeIdle int
+
+	// Interval of liveness probes to warm runtimes, 0 means never probe
+	// Units: seconds
+	RuntimeProbeInterval int
+	// Time a warm runtime has to answer a liveness probe
+	// Units: milliseconds
+	RuntimeProbeTimeout int
+	// Consecutive probe failures before a runtime is invalidated and reborn
+	RuntimeProbeFailureThreshold int
+
+	// Max number of requests waiting for runtimes of one function
+	// 0 means requests are rejected at once when runtimes are exhausted
+	MaxWaitQueueLength int
+
+	// Max time a request waits for a released runtime
+	// Units: milliseconds
+	MaxWaitTime int
+
+	// Policy to pick idle warm runtimes to evict when no cold runtime is free
+	// lru, lfu or none
+	EvictionPolicy string
+
+	// Consecutive warm-up or init failures of a function before its cold starts fail fast
+	// 0 means the breaker is disabled
+	WarmUpBreakerThreshold int
+	// Time the warm-up breaker stays open before probing again
+	// Units: seconds
+	WarmUpBreakerCoolOff int
+
+	// Share of the runtimes reserved for every priority class above the invocation's one
+	// 0 means runtimes are not reserved by priority
+	PriorityReservedShare float64
+
+	// Max number of payloads in one batch invocation
+	MaxBatchSize int
+	// Max number of items of one batch invocation running at the same time
+	MaxBatchParallelism int
+
 	// Runtime concurrent mode switch
 	ConcurrentMode bool
 	// HTTP trigger feature switch
@@ -68,6 +123,10 @@ type ControllerOptions struct {
 
 	EnableCanary bool
 
+	// Header or query parameter whose value a request sticks to a canary version by
+	CanaryStickyHeader string
+	CanaryStickyQuery  string
+
 	SimpleAuth bool
 }
 
@@ -80,11 +139,28 @@ func NewOptions() *ControllerOptions {
 		HttpTriggerRepositoryOptions: registry.NewEmptyOption(),
 		RuntimeConfigOptions:         rtctrl.NewRuntimeConfigOptions(),
 		AliasCacheOptions:            function.NewStorageCacheOptions(),
+		EventQueueOptions:            eventqueue.NewOptions(),
+		PredictorOptions:             predictor.NewOptions(),
+		ResultStoreOptions:           resultstore.NewOptions(),
+		DestinationOptions:           destination.NewOptions(),
+		RateLimitOptions:             ratelimit.NewOptions(),
 		TaskInterval:                 5,
 		MetricsTaskInterval:          10,
+		DrainTimeout:                 30,
+		WarmUpBreakerThreshold:       5,
+		WarmUpBreakerCoolOff:         30,
+		MaxBatchSize:                 100,
+		MaxBatchParallelism:          10,
 		MaxRuntimeIdle:               60,
 		MaxRunnerDefunct:             90,
 		MaxRunnerResetTimeout:        60,
+		RuntimeFreezeIdle:            0,
+		RuntimeProbeInterval:         0,
+		RuntimeProbeTimeout:          1000,
+		RuntimeProbeFailureThreshold: 3,
+		MaxWaitQueueLength:           100,
+		MaxWaitTime:                  1000,
+		EvictionPolicy:               rtctrl.EvictionPolicyLRU,
 		ConcurrentMode:               true,
 		GoMaxProcs:                   runtime.NumCPU(),
 		HTTPEnhanced:                 false,
@@ -101,14 +177,34 @@ func (s *ControllerOptions) AddFlags(fs *pflag.FlagSet) {
 	s.DispatcherV2Options.AddFlags(fs)
 	s.RuntimeConfigOptions.AddFlags(fs)
 	s.AliasCacheOptions.AddFlags("alias", fs)
+	s.EventQueueOptions.AddFlags(fs)
+	s.PredictorOptions.AddFlags(fs)
+	s.ResultStoreOptions.AddFlags(fs)
+	s.DestinationOptions.AddFlags(fs)
+	s.RateLimitOptions.AddFlags(fs)
 	fs.IntVar(&s.TaskInterval, "task-interval", s.TaskInterval, "cron task interval")
 	fs.IntVar(&s.MetricsTaskInterval, "metric-task-interval", s.MetricsTaskInterval, "metric task interval")
+	fs.IntVar(&s.DrainTimeout, "drain-timeout", s.DrainTimeout, "max time(s) in-flight invocations run to completion when draining")
 	fs.IntVar(&s.MaxRuntimeIdle, "max-runtime-idle", s.MaxRuntimeIdle, "max runtime idle timeout")
 	fs.IntVar(&s.MaxRunnerDefunct, "max-runner-defunct", s.MaxRunnerDefunct, "max runner defunct timeout")
 	fs.IntVar(&s.MaxRunnerResetTimeout, "max-runner-reset-timeout", s.MaxRunnerResetTimeout, "max runner reset timeout")
+	fs.IntVar(&s.RuntimeFreezeIdle, "runtime-freeze-idle", s.RuntimeFreezeIdle, "idle time(s) before a warm runtime is frozen, 0 means never freeze")
+	fs.IntVar(&s.RuntimeProbeInterval, "runtime-probe-interval", s.RuntimeProbeInterval, "interval(s) of liveness probes to warm runtimes, 0 means never probe")
+	fs.IntVar(&s.RuntimeProbeTimeout, "runtime-probe-timeout", s.RuntimeProbeTimeout, "time(ms) a warm runtime has to answer a liveness probe")
+	fs.IntVar(&s.RuntimeProbeFailureThreshold, "runtime-probe-failure-threshold", s.RuntimeProbeFailureThreshold, "consecutive probe failures before a runtime is invalidated and reborn")
+	fs.IntVar(&s.MaxWaitQueueLength, "max-wait-queue-length", s.MaxWaitQueueLength, "max requests waiting for runtimes of a function")
+	fs.IntVar(&s.MaxWaitTime, "max-wait-time", s.MaxWaitTime, "max time(ms) a request waits for a released runtime")
+	fs.IntVar(&s.WarmUpBreakerThreshold, "warmup-breaker-threshold", s.WarmUpBreakerThreshold, "consecutive warm-up failures of a function before its cold starts fail fast, 0 means disabled")
+	fs.IntVar(&s.WarmUpBreakerCoolOff, "warmup-breaker-cool-off", s.WarmUpBreakerCoolOff, "time(s) the warm-up breaker stays open before probing again")
+	fs.Float64Var(&s.PriorityReservedShare, "priority-reserved-share", s.PriorityReservedShare, "share of runtimes reserved for every higher priority class, in [0, 0.5)")
+	fs.IntVar(&s.MaxBatchSize, "max-batch-size", s.MaxBatchSize, "max payloads in one batch invocation")
+	fs.IntVar(&s.MaxBatchParallelism, "max-batch-parallelism", s.MaxBatchParallelism, "max items of one batch invocation running at the same time")
+	fs.StringVar(&s.EvictionPolicy, "eviction-policy", s.EvictionPolicy, "policy to evict idle warm runtimes when no cold runtime is free: lru, lfu or none")
 	fs.BoolVar(&s.ConcurrentMode, "concurrent-mode", s.ConcurrentMode, "whether runtime run concurrently")
 	fs.IntVar(&s.GoMaxProcs, "maxprocs", s.GoMaxProcs, "go max procs")
 	fs.BoolVar(&s.HTTPEnhanced, "http-enhanced", s.HTTPEnhanced, "whether to equip with http trigger feature")
 	fs.BoolVar(&s.SimpleAuth, "enable-simple-auth", s.SimpleAuth, "whether to use simple auth")
 	fs.BoolVar(&s.EnableCanary, "enable-canary", s.EnableCanary, "whether to enable canary")
+	fs.StringVar(&s.CanaryStickyHeader, "canary-sticky-header", s.CanaryStickyHeader, "header whose value a request sticks to a canary version by")
+	fs.StringVar(&s.CanaryStickyQuery, "canary-sticky-query", s.CanaryStickyQuery, "query parameter whose value a request sticks to a canary version by")
 }
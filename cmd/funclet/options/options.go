@@ -44,6 +44,8 @@ type FuncletOptions struct {
 	InvokerDispatcherPort int
 	KillRuntimeWaitTime   int // times of waiting for process to exit (unit seconds)
 	RunningMode           string
+	// RuntimeAPIAddress: address of the controller runtime api reachable from runtimes, e.g. 127.0.0.1:9001
+	RuntimeAPIAddress string
 
 	ResourceOption   *runtime.ResourceOption
 	RunnerSpecOption *runner.RunnerSpecOption
@@ -97,4 +99,6 @@ func (s *FuncletOptions) AddFlags(fs *pflag.FlagSet) {
 	fs.IntVar(&s.KillRuntimeWaitTime, "kill-runtime-wait-times", s.KillRuntimeWaitTime, "Times of waiting for process to exit (unit seconds)")
 	fs.IntVar(&s.InvokerDispatcherPort, "invoker-dis-port", s.InvokerDispatcherPort, "Port of invoker dispatcher")
 	fs.StringVar(&s.RunningMode, "running-mode", s.RunningMode, "Running mode: common,ide; default common")
+	fs.StringVar(&s.RuntimeAPIAddress, "runtime-api-address", s.RuntimeAPIAddress,
+		"address of the controller runtime api reachable from runtimes, AWS_LAMBDA_RUNTIME_API is not set when empty")
 }
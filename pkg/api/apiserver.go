@@ -97,6 +97,23 @@ type Concurrency struct {
 	AccountReservedSum int
 }
 
+// ListReservedConcurrencyInput
+type ListReservedConcurrencyInput struct {
+	RequestID string
+}
+
+// ReservedConcurrency: reserved concurrency of a function, shared by all its versions
+type ReservedConcurrency struct {
+	FunctionBrn                  string
+	Uid                          string
+	ReservedConcurrentExecutions int64
+}
+
+// ListReservedConcurrencyOutput
+type ListReservedConcurrencyOutput struct {
+	Functions []*ReservedConcurrency
+}
+
 type LogConfiguration struct {
 	LogType string
 
@@ -119,6 +136,21 @@ type FunctionConfiguration struct {
 	LogType            string  `json:",omitempty"`
 	LogBosDir          string  `json:",omitempty"`
 	PodConcurrentQuota uint64  `json:",omitempty"`
+	// Priority: priority class of the invocations of the function, e.g. high, normal or low
+	Priority string `json:",omitempty"`
+
+	DestinationConfig *DestinationConfig `json:",omitempty"`
+}
+
+// DestinationConfig: where results of event invocations are sent
+type DestinationConfig struct {
+	OnSuccess *DestinationTarget `json:",omitempty"`
+	OnFailure *DestinationTarget `json:",omitempty"`
+}
+
+// DestinationTarget: a function brn or a http(s) webhook address
+type DestinationTarget struct {
+	Destination string
 }
 
 func IsNoneLogType(logType string) bool {
@@ -186,4 +218,16 @@ type Alias struct {
 	CreatedAt               time.Time
 	AdditionalVersion       *string
 	AdditionalVersionWeight *float64
+	RoutingConfig           *AliasRoutingConfiguration
+}
+
+// AliasRoutingConfiguration: traffic weights of the versions besides FunctionVersion,
+// FunctionVersion takes the rest
+type AliasRoutingConfiguration struct {
+	AdditionalVersionWeights []VersionWeight
+}
+
+type VersionWeight struct {
+	Version string
+	Weight  float64
 }
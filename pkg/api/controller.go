@@ -20,6 +20,7 @@ import (
 	"io"
 	"net/url"
 	"strings"
+	"time"
 )
 
 const MinMemorySize int64 = 128
@@ -251,3 +252,51 @@ const (
 	TriggerTypeVscode              = "vscode"
 	TriggerTypeGeneric             = "generic"
 )
+
+type ProvisionedConcurrencyStatus = string
+
+const (
+	ProvisionedConcurrencyInProgress ProvisionedConcurrencyStatus = "IN_PROGRESS"
+	ProvisionedConcurrencyReady                                   = "READY"
+	ProvisionedConcurrencyFailed                                  = "FAILED"
+)
+
+// PutProvisionedConcurrencyInput: target number of pre-warmed runtimes of a function version
+type PutProvisionedConcurrencyInput struct {
+	ProvisionedConcurrentExecutions int
+}
+
+type ProvisionedConcurrencyOutput struct {
+	FunctionBrn                              string
+	RequestedProvisionedConcurrentExecutions int
+	AvailableProvisionedConcurrentExecutions int
+	Status                                   ProvisionedConcurrencyStatus
+	StatusReason                             string `json:",omitempty"`
+}
+
+const (
+	DestinationConditionSuccess = "Success"
+)
+
+// DestinationRecord is the envelope sent to the destination of an event invocation
+type DestinationRecord struct {
+	Version         string                     `json:"version"`
+	Timestamp       time.Time                  `json:"timestamp"`
+	RequestContext  DestinationRequestContext  `json:"requestContext"`
+	RequestPayload  string                     `json:"requestPayload"`
+	ResponseContext DestinationResponseContext `json:"responseContext"`
+	ResponsePayload string                     `json:"responsePayload"`
+}
+
+type DestinationRequestContext struct {
+	RequestID              string `json:"requestId"`
+	ExternalRequestID      string `json:"externalRequestId"`
+	FunctionBrn            string `json:"functionBrn"`
+	Condition              string `json:"condition"`
+	ApproximateInvokeCount int    `json:"approximateInvokeCount"`
+}
+
+type DestinationResponseContext struct {
+	StatusCode    int    `json:"statusCode"`
+	FunctionError string `json:"functionError,omitempty"`
+}
@@ -37,6 +37,7 @@ const (
 	EventInit   Event = "event_init"
 	EventWarmup Event = "event_warmup"
 	EventReset  Event = "event_reset"
+	EventFreeze Event = "event_freeze"
 )
 
 const (
@@ -150,6 +151,17 @@ type ContainerInfo struct {
 	IsFrozen       bool
 	Resource       *Resource
 	ResourceStats  *ResourceStats
+	// Function is loaded by the last warm up, nil when the container is cold
+	Function *ContainerFunction
+}
+
+// ContainerFunction is the function metadata of a warm container kept by funclet,
+// a restarted controller restores its runtimes from it
+type ContainerFunction struct {
+	CommitID       string
+	FunctionBrn    string
+	MemorySize     int64
+	ConcurrentMode bool
 }
 
 // MarshalLogObject is marshaler for ContainerInfo
@@ -170,6 +182,7 @@ func (c *ContainerInfo) Copy() (nc *ContainerInfo) {
 		IsFrozen:       c.IsFrozen,
 		WithStreamMode: c.WithStreamMode,
 		Resource:       c.Resource.Copy(),
+		Function:       c.Function,
 	}
 }
 
@@ -247,6 +260,8 @@ type FuncletClientWarmUpInput struct {
 	NeedScaleUp           bool
 	ScaleUpRecommendation *ScaleUpRecommendation
 	WithStreamMode        bool // TODO: remove it after apiserver deployed in production
+	// RuntimeAPIToken: token of the controller runtime api given to the runtime
+	RuntimeAPIToken string
 }
 
 type WarmupRequest struct {
@@ -261,6 +276,8 @@ type WarmUpContainerArgs struct {
 	RuntimeConfiguration  *RuntimeConfiguration
 	ScaleUpRecommendation *ScaleUpRecommendation
 	WithStreamMode        bool // TODO: remove it after apiserver deployed in production
+	// RuntimeAPIToken: token of the controller runtime api given to the runtime
+	RuntimeAPIToken string
 }
 
 type WarmUpResponse struct {
@@ -297,6 +314,24 @@ type ResetRequest struct {
 	ScaleDownRecommendation *ScaleDownRecommendation
 }
 
+type FuncletClientFreezeInput struct {
+	ContainerID string
+	RequestID   string
+	State       FreezerState
+}
+
+type FreezeRequest struct {
+	ContainerID string
+	RequestID   string
+	// State: FROZEN or THAWED
+	State FreezerState
+}
+
+type FreezeResponse struct {
+	ContainerID string
+	State       FreezerState
+}
+
 type ResetResponse struct {
 	ScaleDownResult *ScaleDownImplementationResult
 }
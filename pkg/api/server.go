@@ -29,12 +29,27 @@ const (
 	HeaderLogType       = "Log-Type"
 	HeaderLogToBody     = "Log-To-Body"
 	HeaderXAuthToken    = "X-Auth-Token"
+	HeaderPriority      = "X-easyfaas-Priority"
+	HeaderInvocationID  = "X-easyfaas-Invocation-Id"
+	// HeaderDestinationHops: number of destination functions the event has passed through
+	HeaderDestinationHops = "X-easyfaas-Destination-Hops"
 
 	BceFaasUIDKey          = "BCE-FAAS-UID"
 	BceFaasTriggerKey      = "X-easyfaas-Faas-Trigger"
 	XBceFunctionError      = "X-easyfaas-Function-Error"
 	HeadereasyfaasExecTime = "X-easyfaas-Function-Exectime"
 	HeaderLogResult        = "X-Bce-Log-Result"
+	HeaderExecutedVersion  = "X-easyfaas-Executed-Version"
+
+	// headers of the AWS Lambda Invoke API
+	HeaderAmzInvocationType  = "X-Amz-Invocation-Type"
+	HeaderAmzLogType         = "X-Amz-Log-Type"
+	HeaderAmzClientContext   = "X-Amz-Client-Context"
+	HeaderAmzFunctionError   = "X-Amz-Function-Error"
+	HeaderAmzLogResult       = "X-Amz-Log-Result"
+	HeaderAmzExecutedVersion = "X-Amz-Executed-Version"
+	HeaderAmznRequestID      = "X-Amzn-RequestId"
+	HeaderAmznErrorType      = "X-Amzn-ErrorType"
 
 	QueryLogType   = "logType"
 	QueryLogToBody = "logToBody"
@@ -0,0 +1,211 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	routing "github.com/qiangxue/fasthttp-routing"
+
+	"github.com/baidu/easyfaas/pkg/api"
+	innerErr "github.com/baidu/easyfaas/pkg/error"
+	"github.com/baidu/easyfaas/pkg/util/id"
+	"github.com/baidu/easyfaas/pkg/util/json"
+	"github.com/baidu/easyfaas/pkg/util/logs"
+)
+
+// batchItemResult: result of one payload of a batch invocation
+type batchItemResult struct {
+	Index        int    `json:"index"`
+	RequestID    string `json:"requestId"`
+	StatusCode   int    `json:"statusCode"`
+	Payload      string `json:"payload,omitempty"`
+	ErrorType    string `json:"errorType,omitempty"`
+	ErrorMessage string `json:"errorMessage,omitempty"`
+	DurationMS   int64  `json:"durationMs"`
+}
+
+// BatchInvokeHandler invokes the function once for every payload of the request body array
+// function metadata is resolved once and shared by all the items
+func (controller *Controller) BatchInvokeHandler(c *routing.Context) error {
+	base, err := controller.newInvokeContext(c)
+	if err != nil {
+		return writeErrorResponse(c, err)
+	}
+	var payloads []json.RawMessage
+	if err := json.Unmarshal(c.PostBody(), &payloads); err != nil {
+		return writeErrorResponse(c, innerErr.NewInvalidRequestContentException("request body should be an array of payloads", err))
+	}
+	if len(payloads) == 0 {
+		return writeErrorResponse(c, innerErr.NewInvalidRequestContentException("empty batch", nil))
+	}
+	if max := controller.runOptions.MaxBatchSize; max > 0 && len(payloads) > max {
+		return writeErrorResponse(c, innerErr.NewInvalidRequestContentException("batch size exceeds "+strconv.Itoa(max), nil))
+	}
+	parallelism, err := controller.batchParallelism(c)
+	if err != nil {
+		return writeErrorResponse(c, err)
+	}
+
+	if err := controller.drain.admit(); err != nil {
+		return writeErrorResponse(c, err)
+	}
+	defer controller.drain.done()
+
+	startTime := time.Now()
+	defer base.Logger.TimeTrack(startTime, "Batch invocation total time")
+
+	if _, err := controller.getFunction(&base); err != nil {
+		return writeErrorResponse(c, err)
+	}
+	if _, err := controller.getRuntimeConfiguration(&base); err != nil {
+		return writeErrorResponse(c, err)
+	}
+
+	var results []batchItemResult
+	status := http.StatusOK
+	if base.InvokeType == api.InvokeTypeEvent {
+		// the same status as a single event invocation, for the batch and every queued item
+		results = controller.enqueueBatch(&base, payloads)
+		status = http.StatusCreated
+	} else {
+		results = controller.doBatch(&base, payloads, parallelism)
+	}
+	body, err := json.Marshal(results)
+	if err != nil {
+		return err
+	}
+	c.SetStatusCode(status)
+	c.Response.SetBody(body)
+	return nil
+}
+
+// batchParallelism: the Parallelism query parameter, capped by the max batch parallelism
+func (controller *Controller) batchParallelism(c *routing.Context) (int, error) {
+	parallelism := controller.runOptions.MaxBatchParallelism
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	query := string(c.QueryArgs().Peek("Parallelism"))
+	if query == "" {
+		return parallelism, nil
+	}
+	n, err := strconv.Atoi(query)
+	if err != nil || n < 1 {
+		return 0, innerErr.NewInvalidParameterValueException("Parallelism should be a positive integer", nil)
+	}
+	if n < parallelism {
+		parallelism = n
+	}
+	return parallelism, nil
+}
+
+// batchItemContext copies the resolved batch context for the payload;
+// every item gets its own request id and keeps the external request id of the caller
+func batchItemContext(base *InvokeContext, index int, payload []byte) *InvokeContext {
+	ctx := *base
+	requestID := id.GetRequestID()
+	headers := make(map[string]string, len(base.Request.Headers))
+	for k, v := range base.Request.Headers {
+		headers[k] = v
+	}
+	ctx.RequestID = requestID
+	if ctx.InvokeType == api.InvokeTypeEvent {
+		ctx.Request = api.NewInvokeProxyRequest(headers, payload, nil)
+	} else {
+		ctx.Request = api.NewInvokeProxyRequest(headers, payload, bytes.NewBuffer(payload))
+	}
+	ctx.Response = api.NewInvokeProxyResponseWithRequestID(base.ExternalRequestID)
+	ctx.Logger = logs.NewLogger().WithField("request_id", requestID).
+		WithField("external_request_id", base.ExternalRequestID).
+		WithField("batch_request_id", base.RequestID).WithField("batch_index", strconv.Itoa(index)).
+		WithField("invoke-type", base.InvokeType)
+	if base.Metrics != nil {
+		ctx.Metrics = NewInvokeMetrics(requestID)
+	}
+	return &ctx
+}
+
+// doBatch runs the items through the invoke pipeline, at most parallelism of them at the same time
+func (controller *Controller) doBatch(base *InvokeContext, payloads []json.RawMessage, parallelism int) []batchItemResult {
+	results := make([]batchItemResult, len(payloads))
+	sem := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+	for i, payload := range payloads {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int, payload []byte) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			ctx := batchItemContext(base, i, payload)
+			startTime := time.Now()
+			controller.Do(ctx)
+			results[i] = batchResult(ctx, i, time.Since(startTime))
+		}(i, payload)
+	}
+	wg.Wait()
+	return results
+}
+
+// enqueueBatch hands every item to the event invocation path
+func (controller *Controller) enqueueBatch(base *InvokeContext, payloads []json.RawMessage) []batchItemResult {
+	results := make([]batchItemResult, len(payloads))
+	for i, payload := range payloads {
+		ctx := batchItemContext(base, i, payload)
+		results[i] = batchItemResult{
+			Index:      i,
+			RequestID:  ctx.RequestID,
+			StatusCode: http.StatusCreated,
+		}
+		if err := controller.enqueueEvent(ctx); err != nil {
+			ctx.Logger.Errorf("enqueue event failed: %s", err)
+			finalErr := innerErr.GenericKunFinalError(innerErr.NewServiceException("enqueue event failed", err))
+			results[i].StatusCode = finalErr.Status
+			results[i].ErrorType = string(finalErr.Code)
+			results[i].ErrorMessage = finalErr.Error()
+		}
+	}
+	return results
+}
+
+func batchResult(ctx *InvokeContext, index int, duration time.Duration) batchItemResult {
+	if ctx.Response.BodyStream != nil {
+		body, _ := ioutil.ReadAll(ctx.Response.BodyStream)
+		ctx.Response.BodyStream.Close()
+		ctx.Response.Body = body
+	}
+	res := eventResult(ctx)
+	result := batchItemResult{
+		Index:        index,
+		RequestID:    ctx.RequestID,
+		StatusCode:   res.StatusCode,
+		ErrorType:    res.ErrorType,
+		ErrorMessage: res.ErrorMessage,
+		DurationMS:   duration.Milliseconds(),
+	}
+	if res.ErrorMessage == "" {
+		result.Payload = string(ctx.Response.Body)
+	}
+	return result
+}
@@ -0,0 +1,114 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	routing "github.com/qiangxue/fasthttp-routing"
+	"github.com/valyala/fasthttp"
+
+	"github.com/baidu/easyfaas/pkg/api"
+	"github.com/baidu/easyfaas/pkg/util/json"
+	"github.com/baidu/easyfaas/pkg/util/logs"
+)
+
+func TestDoBatch(t *testing.T) {
+	controller, funclet := newTestController(4, &fakeDataStorer{})
+	controller.breaker = newWarmUpBreaker(0, 0)
+	funclet.warmUpErr = fmt.Errorf("code location was empty")
+
+	brn := "brn:cloud:faas:bj:8f6e:function:hello:1"
+	base := &InvokeContext{
+		RunOptions:  controller.runOptions,
+		RequestID:   "batch-req",
+		FunctionBRN: brn,
+		Function:    testFunction(brn, "commit-1"),
+		Runtime:     &api.RuntimeConfiguration{Name: "nodejs12"},
+		Clients:     &Clients{FuncletClient: funclet},
+		Request:     api.NewInvokeProxyRequest(map[string]string{}, nil, nil),
+		Logger:      logs.NewLogger(),
+		Metrics:     NewInvokeMetrics("batch-req"),
+		InvokeType:  string(api.InvokeTypeCommon),
+	}
+	payloads := []json.RawMessage{[]byte(`{"a":1}`), []byte(`{"a":2}`), []byte(`{"a":3}`), []byte(`{"a":4}`)}
+	results := controller.doBatch(base, payloads, 2)
+	if len(results) != len(payloads) {
+		t.Fatalf("expect %d results, got %d", len(payloads), len(results))
+	}
+	seen := make(map[string]bool)
+	for i, res := range results {
+		if res.Index != i {
+			t.Errorf("result %d out of order: %+v", i, res)
+		}
+		if res.StatusCode < http.StatusBadRequest || res.ErrorType == "" || res.ErrorMessage == "" {
+			t.Errorf("expect failed item, got %+v", res)
+		}
+		if res.RequestID == "" || res.RequestID == base.RequestID || seen[res.RequestID] {
+			t.Errorf("expect a distinct request id per item, got %+v", res)
+		}
+		seen[res.RequestID] = true
+	}
+	if len(funclet.warmUps) != len(payloads) {
+		t.Errorf("every item should be dispatched, warm ups %v", funclet.warmUps)
+	}
+}
+
+func TestBatchParallelism(t *testing.T) {
+	controller, _ := newTestController(0, &fakeDataStorer{})
+	controller.runOptions.MaxBatchParallelism = 4
+	cases := []struct {
+		query string
+		want  int
+		err   bool
+	}{
+		{"", 4, false},
+		{"Parallelism=2", 2, false},
+		{"Parallelism=16", 4, false},
+		{"Parallelism=0", 0, true},
+		{"Parallelism=x", 0, true},
+	}
+	for _, c := range cases {
+		ctx := &routing.Context{RequestCtx: &fasthttp.RequestCtx{}}
+		ctx.Request.SetRequestURI("/v1/functions/hello/batch-invocations?" + c.query)
+		got, err := controller.batchParallelism(ctx)
+		if (err != nil) != c.err || got != c.want {
+			t.Errorf("query %q: expect %d err %v, got %d %v", c.query, c.want, c.err, got, err)
+		}
+	}
+}
+
+func TestBatchItemContext(t *testing.T) {
+	base := &InvokeContext{
+		RequestID:         "batch-req",
+		ExternalRequestID: "caller-req",
+		Request:           api.NewInvokeProxyRequest(map[string]string{api.HeaderXRequestID: "caller-req"}, nil, nil),
+		InvokeType:        api.InvokeTypeEvent,
+	}
+	ctx := batchItemContext(base, 1, []byte(`{"a":1}`))
+	if ctx.RequestID == "" || ctx.RequestID == base.RequestID {
+		t.Errorf("expect a request id of the item, got %s", ctx.RequestID)
+	}
+	if ctx.ExternalRequestID != "caller-req" || ctx.Request.Headers[api.HeaderXRequestID] != "caller-req" {
+		t.Errorf("expect the external request id of the caller kept, got %s", ctx.ExternalRequestID)
+	}
+	if string(ctx.Request.Body) != `{"a":1}` {
+		t.Errorf("unexpected item body %s", ctx.Request.Body)
+	}
+}
@@ -0,0 +1,215 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	routing "github.com/qiangxue/fasthttp-routing"
+
+	innerErr "github.com/baidu/easyfaas/pkg/error"
+	"github.com/baidu/easyfaas/pkg/util/json"
+)
+
+// states of the warm-up circuit breaker
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half-open"
+)
+
+// FunctionBreaker: warm-up circuit breaker of one function commit
+type FunctionBreaker struct {
+	CommitID    string    `json:"CommitID"`
+	FunctionBRN string    `json:"FunctionBRN"`
+	State       string    `json:"State"`
+	Failures    int       `json:"Failures"`
+	LastError   string    `json:"LastError,omitempty"`
+	RetryTime   time.Time `json:"RetryTime"`
+	// probing: a half-open probe is warming up
+	probing bool
+}
+
+// warmUpBreaker fails fast the cold starts of a function commit
+// after its warm-up or init failed threshold times in a row,
+// one probe is let through when the cool-off period passed
+type warmUpBreaker struct {
+	lock      sync.Mutex
+	threshold int
+	coolOff   time.Duration
+	functions map[string]*FunctionBreaker
+	now       func() time.Time
+}
+
+func newWarmUpBreaker(threshold int, coolOff time.Duration) *warmUpBreaker {
+	return &warmUpBreaker{
+		threshold: threshold,
+		coolOff:   coolOff,
+		functions: make(map[string]*FunctionBreaker),
+		now:       time.Now,
+	}
+}
+
+// allow returns the time to wait when the cold start is rejected
+func (b *warmUpBreaker) allow(commitID string) (time.Duration, error) {
+	if b.threshold <= 0 {
+		return 0, nil
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	fb, ok := b.functions[commitID]
+	if !ok || fb.State == BreakerClosed {
+		return 0, nil
+	}
+	now := b.now()
+	if fb.State == BreakerOpen && !now.Before(fb.RetryTime) {
+		fb.State = BreakerHalfOpen
+	}
+	if fb.State == BreakerHalfOpen && !fb.probing {
+		fb.probing = true
+		return 0, nil
+	}
+	wait := fb.RetryTime.Sub(now)
+	if wait < 0 {
+		// the probe is still warming up
+		wait = 0
+	}
+	return wait, fmt.Errorf("function warm up failed %d times in a row, last error: %s", fb.Failures, fb.LastError)
+}
+
+// cancel gives the probe back when no runtime was warmed up
+func (b *warmUpBreaker) cancel(commitID string) {
+	if b.threshold <= 0 {
+		return
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if fb, ok := b.functions[commitID]; ok {
+		fb.probing = false
+	}
+}
+
+func (b *warmUpBreaker) success(commitID string) {
+	if b.threshold <= 0 {
+		return
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	delete(b.functions, commitID)
+}
+
+func (b *warmUpBreaker) failure(commitID, functionBRN string, cause error) {
+	if b.threshold <= 0 {
+		return
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	fb, ok := b.functions[commitID]
+	if !ok {
+		fb = &FunctionBreaker{CommitID: commitID, State: BreakerClosed}
+		b.functions[commitID] = fb
+	}
+	fb.FunctionBRN = functionBRN
+	fb.Failures++
+	fb.LastError = cause.Error()
+	if fb.State == BreakerHalfOpen || fb.Failures >= b.threshold {
+		fb.State = BreakerOpen
+		fb.RetryTime = b.now().Add(b.coolOff)
+		fb.probing = false
+	}
+}
+
+func (b *warmUpBreaker) list() []FunctionBreaker {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	list := make([]FunctionBreaker, 0, len(b.functions))
+	for _, fb := range b.functions {
+		list = append(list, *fb)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CommitID < list[j].CommitID
+	})
+	return list
+}
+
+// allowColdStart checks the breaker of the function before warming up a runtime
+func (controller *Controller) allowColdStart(ctx *InvokeContext) error {
+	wait, err := controller.breaker.allow(*ctx.Function.Configuration.CommitID)
+	if err == nil {
+		ctx.coldStartProbe = true
+		return nil
+	}
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	ctx.Response.SetHeader(headerRetryAfter, strconv.Itoa(seconds))
+	return innerErr.NewServiceUnavailableException(err.Error(), nil)
+}
+
+// coldStartCanceled gives the probe back when no runtime was warmed up
+func (controller *Controller) coldStartCanceled(ctx *InvokeContext) {
+	if !ctx.coldStartProbe {
+		return
+	}
+	ctx.coldStartProbe = false
+	controller.breaker.cancel(*ctx.Function.Configuration.CommitID)
+}
+
+// coldStartFailed records the failure of warming up the runtime
+func (controller *Controller) coldStartFailed(ctx *InvokeContext, cause error) {
+	if !ctx.coldStartProbe {
+		return
+	}
+	ctx.coldStartProbe = false
+	controller.breaker.failure(*ctx.Function.Configuration.CommitID, ctx.FunctionBRN, cause)
+}
+
+// coldStartDone records the result of the function init on the cold runtime;
+// it is deferred as well, so the probe is given back when the invocation panics or has no output
+func (controller *Controller) coldStartDone(ctx *InvokeContext) {
+	if !ctx.coldStartProbe {
+		return
+	}
+	if ctx.Output == nil || ctx.Input.Runtime == nil {
+		controller.coldStartCanceled(ctx)
+		return
+	}
+	if ctx.Output.RuntimeNotReady {
+		controller.coldStartFailed(ctx, fmt.Errorf("runtime %s not ready after warm up", ctx.Input.Runtime.RuntimeID))
+		return
+	}
+	ctx.coldStartProbe = false
+	controller.breaker.success(*ctx.Function.Configuration.CommitID)
+}
+
+// ListBreakersHandler shows the warm-up circuit breakers of functions
+func (controller *Controller) ListBreakersHandler(c *routing.Context) error {
+	body, err := json.Marshal(controller.breaker.list())
+	if err != nil {
+		return err
+	}
+	c.Response.SetBody(body)
+	return nil
+}
@@ -0,0 +1,139 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/baidu/easyfaas/pkg/api"
+	"github.com/baidu/easyfaas/pkg/controller/rtctrl"
+	innerErr "github.com/baidu/easyfaas/pkg/error"
+	"github.com/baidu/easyfaas/pkg/util/logs"
+)
+
+func TestWarmUpBreaker(t *testing.T) {
+	now := time.Now()
+	b := newWarmUpBreaker(2, 10*time.Second)
+	b.now = func() time.Time { return now }
+	cause := fmt.Errorf("download code timeout")
+
+	b.failure("commit-1", "brn-1", cause)
+	if _, err := b.allow("commit-1"); err != nil {
+		t.Fatalf("breaker should stay closed below threshold: %s", err)
+	}
+	b.failure("commit-1", "brn-1", cause)
+	wait, err := b.allow("commit-1")
+	if err == nil || wait != 10*time.Second {
+		t.Fatalf("breaker should be open, wait %s err %v", wait, err)
+	}
+	if _, err := b.allow("commit-2"); err != nil {
+		t.Errorf("other functions are not affected: %s", err)
+	}
+
+	// one probe after cool-off, the failed probe opens the breaker again
+	now = now.Add(10 * time.Second)
+	if _, err := b.allow("commit-1"); err != nil {
+		t.Fatalf("expect a probe after cool-off: %s", err)
+	}
+	if _, err := b.allow("commit-1"); err == nil {
+		t.Error("only one probe is let through")
+	}
+	b.failure("commit-1", "brn-1", cause)
+	if list := b.list(); len(list) != 1 || list[0].State != BreakerOpen || list[0].Failures != 3 {
+		t.Errorf("unexpected breakers %+v", list)
+	}
+
+	// the probe given back lets the next one through
+	now = now.Add(10 * time.Second)
+	b.allow("commit-1")
+	b.cancel("commit-1")
+	if _, err := b.allow("commit-1"); err != nil {
+		t.Fatalf("expect a probe after cancel: %s", err)
+	}
+	b.success("commit-1")
+	if list := b.list(); len(list) != 0 {
+		t.Errorf("succeeded probe should close the breaker, got %+v", list)
+	}
+}
+
+func TestColdStartBreaker(t *testing.T) {
+	controller, funclet := newTestController(3, &fakeDataStorer{})
+	controller.breaker = newWarmUpBreaker(2, time.Minute)
+	funclet.warmUpErr = fmt.Errorf("code location was empty")
+
+	function := testFunction("brn:cloud:faas:bj:8f6e:function:hello:1", "commit-1")
+	newCtx := func() *InvokeContext {
+		return &InvokeContext{
+			FunctionBRN: "brn:cloud:faas:bj:8f6e:function:hello:1",
+			Function:    function,
+			Clients:     &Clients{FuncletClient: funclet},
+			Input:       &rtctrl.InvocationInput{Configuration: function.Configuration, Logger: logs.NewLogger()},
+			Response:    api.NewInvokeProxyResponseWithRequestID("req"),
+			Logger:      logs.NewLogger(),
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := controller.tryGetRuntime(newCtx()); err == nil {
+			t.Fatal("expect warm up failure")
+		}
+	}
+	ctx := newCtx()
+	_, err := controller.tryGetRuntime(ctx)
+	if e, ok := err.(innerErr.FinalError); !ok || e.Status != http.StatusServiceUnavailable {
+		t.Fatalf("expect fail fast, got %v", err)
+	}
+	if ctx.Response.Headers[headerRetryAfter] != "60" {
+		t.Errorf("expect Retry-After 60, got %v", ctx.Response.Headers)
+	}
+	if len(funclet.warmUps) != 2 {
+		t.Errorf("open breaker should not warm up runtimes, got %v", funclet.warmUps)
+	}
+}
+
+func TestColdStartProbeReleased(t *testing.T) {
+	controller, _ := newTestController(1, &fakeDataStorer{})
+	controller.breaker = newWarmUpBreaker(1, time.Minute)
+	now := time.Now()
+	controller.breaker.now = func() time.Time { return now }
+	controller.breaker.failure("commit-1", "brn-1", fmt.Errorf("init failed"))
+	now = now.Add(time.Minute)
+
+	function := testFunction("brn-1", "commit-1")
+	ctx := &InvokeContext{
+		Function: function,
+		Input:    &rtctrl.InvocationInput{Configuration: function.Configuration},
+		Response: api.NewInvokeProxyResponseWithRequestID("req"),
+	}
+	if err := controller.allowColdStart(ctx); err != nil {
+		t.Fatalf("expect a probe after cool-off: %s", err)
+	}
+	if _, err := controller.breaker.allow("commit-1"); err == nil {
+		t.Fatal("only one probe is let through")
+	}
+
+	// the invocation ends without any output, eg. it panics
+	controller.coldStartDone(ctx)
+	if ctx.coldStartProbe {
+		t.Error("probe should be resolved")
+	}
+	if _, err := controller.breaker.allow("commit-1"); err != nil {
+		t.Errorf("expect the probe given back: %s", err)
+	}
+}
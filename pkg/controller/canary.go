@@ -0,0 +1,129 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"strings"
+
+	"github.com/baidu/easyfaas/pkg/api"
+	innerErr "github.com/baidu/easyfaas/pkg/error"
+)
+
+const functionVersionLabel = "function_version"
+
+// aliasVersionWeights lists the weighted versions of the alias besides FunctionVersion;
+// a version set both in RoutingConfig and in AdditionalVersion is counted once,
+// RoutingConfig wins, and the weights may not exceed 1 in total
+func aliasVersionWeights(alias *api.Alias) ([]api.VersionWeight, error) {
+	var candidates []api.VersionWeight
+	if alias.RoutingConfig != nil {
+		candidates = append(candidates, alias.RoutingConfig.AdditionalVersionWeights...)
+	}
+	if alias.AdditionalVersion != nil && *alias.AdditionalVersion != "" &&
+		alias.AdditionalVersionWeight != nil && *alias.AdditionalVersionWeight != 0 {
+		candidates = append(candidates, api.VersionWeight{
+			Version: *alias.AdditionalVersion,
+			Weight:  *alias.AdditionalVersionWeight,
+		})
+	}
+
+	var weights []api.VersionWeight
+	var sum float64
+	seen := make(map[string]struct{}, len(candidates))
+	for _, w := range candidates {
+		if w.Version == "" || w.Version == alias.FunctionVersion {
+			continue
+		}
+		if _, ok := seen[w.Version]; ok {
+			continue
+		}
+		seen[w.Version] = struct{}{}
+		if w.Weight < 0 {
+			return nil, fmt.Errorf("negative weight %v of version %s", w.Weight, w.Version)
+		}
+		sum += w.Weight
+		weights = append(weights, w)
+	}
+	if sum > 1 {
+		return nil, fmt.Errorf("version weights sum to %v, exceeding 1", sum)
+	}
+	return weights, nil
+}
+
+// selectAliasVersion picks a version of the alias by weight;
+// the same non-empty sticky key always lands on the same version
+func selectAliasVersion(alias *api.Alias, stickyKey string) (string, error) {
+	weights, err := aliasVersionWeights(alias)
+	if err != nil {
+		return "", err
+	}
+	if len(weights) == 0 {
+		return alias.FunctionVersion, nil
+	}
+	var r float64
+	if stickyKey != "" {
+		h := fnv.New64a()
+		h.Write([]byte(alias.AliasBrn))
+		h.Write([]byte{0})
+		h.Write([]byte(stickyKey))
+		r = float64(h.Sum64()>>11) / (1 << 53)
+	} else {
+		r = rand.Float64()
+	}
+	var sum float64
+	for _, w := range weights {
+		sum += w.Weight
+		if r < sum {
+			return w.Version, nil
+		}
+	}
+	return alias.FunctionVersion, nil
+}
+
+// routeAlias selects the version of the alias the request runs and reports it
+func (controller *Controller) routeAlias(ctx *InvokeContext, alias *api.Alias) (string, error) {
+	version, err := selectAliasVersion(alias, controller.canaryStickyKey(ctx))
+	if err != nil {
+		ctx.Logger.Warnf("invalid routing config of alias %s: %s", alias.AliasBrn, err)
+		return "", innerErr.NewInvalidParameterValueException(fmt.Sprintf("invalid routing config of alias %s: %s", alias.Name, err), err)
+	}
+	ctx.Response.SetHeader(api.HeaderExecutedVersion, version)
+	if ctx.Metrics != nil {
+		ctx.Metrics.SetLabel(functionVersionLabel, version)
+	}
+	return version, nil
+}
+
+// canaryStickyKey returns the value the canary routing of the request sticks to
+func (controller *Controller) canaryStickyKey(ctx *InvokeContext) string {
+	if ctx.canaryKey != "" {
+		return ctx.canaryKey
+	}
+	header := controller.runOptions.CanaryStickyHeader
+	if header == "" || ctx.Request == nil {
+		return ""
+	}
+	for k, v := range ctx.Request.Headers {
+		if strings.EqualFold(k, header) {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,168 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"math"
+	"strconv"
+	"testing"
+
+	"github.com/baidu/easyfaas/pkg/api"
+	"github.com/baidu/easyfaas/pkg/brn"
+	"github.com/baidu/easyfaas/pkg/util/logs"
+)
+
+func mustSelectAliasVersion(t *testing.T, alias *api.Alias, stickyKey string) string {
+	version, err := selectAliasVersion(alias, stickyKey)
+	if err != nil {
+		t.Fatalf("select version of alias failed: %s", err)
+	}
+	return version
+}
+
+func TestSelectAliasVersion(t *testing.T) {
+	alias := &api.Alias{
+		AliasBrn:        "brn:cloud:faas:bj:8f6e:function:hello:prod",
+		FunctionVersion: "1",
+		RoutingConfig: &api.AliasRoutingConfiguration{
+			AdditionalVersionWeights: []api.VersionWeight{{Version: "2", Weight: 0.2}, {Version: "3", Weight: 0.3}},
+		},
+	}
+	counts := make(map[string]int)
+	total := 10000
+	for i := 0; i < total; i++ {
+		counts[mustSelectAliasVersion(t, alias, "user-"+strconv.Itoa(i))]++
+	}
+	for version, weight := range map[string]float64{"1": 0.5, "2": 0.2, "3": 0.3} {
+		if got := float64(counts[version]) / float64(total); math.Abs(got-weight) > 0.03 {
+			t.Errorf("version %s: expect weight %.2f, got %.3f", version, weight, got)
+		}
+	}
+
+	version := mustSelectAliasVersion(t, alias, "sticky-user")
+	for i := 0; i < 100; i++ {
+		if v := mustSelectAliasVersion(t, alias, "sticky-user"); v != version {
+			t.Fatalf("expect sticky key always routed to %s, got %s", version, v)
+		}
+	}
+
+	if v := mustSelectAliasVersion(t, &api.Alias{FunctionVersion: "1"}, ""); v != "1" {
+		t.Errorf("expect alias without weights routed to its version, got %s", v)
+	}
+	additional, weight := "2", 1.0
+	legacy := &api.Alias{FunctionVersion: "1", AdditionalVersion: &additional, AdditionalVersionWeight: &weight}
+	if v := mustSelectAliasVersion(t, legacy, ""); v != "2" {
+		t.Errorf("expect the additional version weighted 1 always chosen, got %s", v)
+	}
+}
+
+func TestAliasVersionWeights(t *testing.T) {
+	additional, weight := "2", 0.6
+	alias := &api.Alias{
+		FunctionVersion:         "1",
+		AdditionalVersion:       &additional,
+		AdditionalVersionWeight: &weight,
+		RoutingConfig: &api.AliasRoutingConfiguration{
+			AdditionalVersionWeights: []api.VersionWeight{{Version: "2", Weight: 0.5}},
+		},
+	}
+	// the version set both ways is counted once, the routing config wins
+	weights, err := aliasVersionWeights(alias)
+	if err != nil {
+		t.Fatalf("expect duplicated version accepted, got %s", err)
+	}
+	if len(weights) != 1 || weights[0].Weight != 0.5 {
+		t.Errorf("expect version 2 weighted 0.5 once, got %+v", weights)
+	}
+
+	alias.RoutingConfig.AdditionalVersionWeights = append(alias.RoutingConfig.AdditionalVersionWeights,
+		api.VersionWeight{Version: "3", Weight: 0.6})
+	if _, err := selectAliasVersion(alias, ""); err == nil {
+		t.Errorf("expect weights summing beyond 1 rejected")
+	}
+	alias.RoutingConfig.AdditionalVersionWeights = []api.VersionWeight{{Version: "3", Weight: -0.1}}
+	if _, err := selectAliasVersion(alias, ""); err == nil {
+		t.Errorf("expect negative weight rejected")
+	}
+}
+
+func TestCanaryStickyKey(t *testing.T) {
+	controller, _ := newTestController(0, &fakeDataStorer{})
+	ctx := &InvokeContext{Request: api.NewInvokeProxyRequest(map[string]string{"X-User-Id": "u1"}, nil, nil)}
+	if key := controller.canaryStickyKey(ctx); key != "" {
+		t.Errorf("expect no sticky key when not configured, got %s", key)
+	}
+	controller.runOptions.CanaryStickyHeader = "x-user-id"
+	if key := controller.canaryStickyKey(ctx); key != "u1" {
+		t.Errorf("expect sticky key from header, got %s", key)
+	}
+	ctx.canaryKey = "q1"
+	if key := controller.canaryStickyKey(ctx); key != "q1" {
+		t.Errorf("expect sticky key from query first, got %s", key)
+	}
+}
+
+func TestGetFunctionByAliasName(t *testing.T) {
+	function := testFunction("brn:cloud:faas:bj:8f6e:function:hello:3", "commit-3")
+	function.Configuration.Uid = "8f6e"
+	storer := &fakeDataStorer{
+		functions: map[string]*api.GetFunctionOutput{"hello": function},
+		aliases: map[string]*api.GetAliasOutput{
+			brn.GenerateFuncBrnString("bj", "8f6e", "hello", "prod"):  {Uid: "8f6e", FunctionName: "hello", FunctionVersion: "3", Name: "prod"},
+			brn.GenerateFuncBrnString("bj", "8f6e", "hello", "stale"): {Uid: "other", FunctionName: "hello", FunctionVersion: "2", Name: "stale"},
+		},
+	}
+	controller, _ := newTestController(0, storer)
+	newCtx := func(qualifier string) *InvokeContext {
+		return &InvokeContext{
+			RunOptions:   controller.runOptions,
+			Clients:      &Clients{DataStorer: storer},
+			AccountID:    "8f6e",
+			FunctionName: "hello",
+			Qualifier:    qualifier,
+			Response:     api.NewInvokeProxyResponseWithRequestID("req"),
+			Logger:       logs.NewLogger(),
+			Metrics:      NewInvokeMetrics("req"),
+		}
+	}
+
+	ctx := newCtx("prod")
+	if _, err := controller.getFunction(ctx); err != nil {
+		t.Fatalf("get function by alias failed: %s", err)
+	}
+	if ctx.Qualifier != "3" || ctx.Response.Headers[api.HeaderExecutedVersion] != "3" {
+		t.Errorf("expect alias resolved to version 3, got %s", ctx.Qualifier)
+	}
+	if _, err := controller.getFunction(newCtx("staging")); err == nil {
+		t.Errorf("expect error for unknown alias")
+	}
+	if _, err := controller.getFunction(newCtx("a:b")); err == nil {
+		t.Errorf("expect error for invalid qualifier")
+	}
+
+	// aliases are looked up within the account of the caller
+	ctx = newCtx("prod")
+	ctx.AccountID = "other"
+	if err := controller.getAliasVersionByName(ctx); err == nil || ctx.Qualifier != "prod" {
+		t.Errorf("expect alias of another account not found, got %v", err)
+	}
+	// an alias owned by another account is rejected
+	ctx = newCtx("stale")
+	if err := controller.getAliasVersionByName(ctx); err == nil || ctx.Qualifier != "stale" {
+		t.Errorf("expect alias owned by another account rejected, got %v", err)
+	}
+}
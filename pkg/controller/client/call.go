@@ -74,7 +74,7 @@ func (mc *ControllerCallClient) Invoke(ir *api.InvokeRequest) (response *api.Inv
 	response = api.NewInvokeResponse()
 	response.SetStatusCode(ctx.Response.StatusCode)
 	response.SetHeaders(&ctx.Response.Headers)
-	if ctx.WithStreamMode {
+	if ctx.WithStreamMode || ctx.Response.BodyStream != nil {
 		response.SetBodyStream(ctx.Response.BodyStream)
 	} else {
 		response.SetBody(ctx.Response.Body)
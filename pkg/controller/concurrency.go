@@ -0,0 +1,328 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/baidu/easyfaas/pkg/api"
+	"github.com/baidu/easyfaas/pkg/brn"
+	innerErr "github.com/baidu/easyfaas/pkg/error"
+	"github.com/baidu/easyfaas/pkg/util/id"
+	"github.com/baidu/easyfaas/pkg/util/logs"
+)
+
+// FunctionConcurrency: concurrency statistics of one function
+type FunctionConcurrency struct {
+	// nil means the function has no reserved concurrency
+	Reserved  *int64 `json:"Reserved,omitempty"`
+	InFlight  int64  `json:"InFlight"`
+	Admitted  uint64 `json:"Admitted"`
+	Throttled uint64 `json:"Throttled"`
+
+	account string
+	// versions of the function seen, their idle warm runtimes serve the reservation
+	commitIDs map[string]struct{}
+}
+
+// reservation of a function, from its meta or from the reservation list of the registry
+type reservation struct {
+	key      string
+	account  string
+	commitID string
+	reserved *int64
+}
+
+// idleWarmCounter counts the idle warm runtimes of a function version
+type idleWarmCounter func(commitID string) int
+
+// ConcurrencyStatistics: admission statistics of the controller
+type ConcurrencyStatistics struct {
+	ReservedSum       int64                           `json:"ReservedSum"`
+	UnusedReserved    int64                           `json:"UnusedReserved"`
+	InFlight          int64                           `json:"InFlight"`
+	Admitted          uint64                          `json:"Admitted"`
+	Throttled         uint64                          `json:"Throttled"`
+	ReservedFunctions map[string]*FunctionConcurrency `json:"ReservedFunctions"`
+}
+
+// concurrencyLimiter enforces the reserved concurrency of functions:
+// a reserved function never runs more invocations than its reservation,
+// and the cold runtimes it has not used yet are kept from the other functions
+type concurrencyLimiter struct {
+	lock      sync.Mutex
+	functions map[string]*FunctionConcurrency
+	// reserved concurrency sum of accounts, covering their functions not seen yet
+	accounts  map[string]int64
+	inFlight  int64
+	admitted  uint64
+	throttled uint64
+}
+
+func newConcurrencyLimiter() *concurrencyLimiter {
+	return &concurrencyLimiter{
+		functions: make(map[string]*FunctionConcurrency),
+		accounts:  make(map[string]int64),
+	}
+}
+
+// reserve records the reservation of a function before any invocation of it
+func (l *concurrencyLimiter) reserve(r reservation) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	fc, ok := l.functions[r.key]
+	if !ok {
+		if r.reserved == nil {
+			return
+		}
+		fc = &FunctionConcurrency{}
+		l.functions[r.key] = fc
+	}
+	fc.Reserved = r.reserved
+	if r.account != "" {
+		fc.account = r.account
+	}
+	if r.commitID != "" {
+		if fc.commitIDs == nil {
+			fc.commitIDs = make(map[string]struct{})
+		}
+		fc.commitIDs[r.commitID] = struct{}{}
+	}
+	if fc.Reserved == nil && fc.InFlight == 0 {
+		delete(l.functions, r.key)
+	}
+}
+
+// reserveAccount records the reserved concurrency sum of the account
+func (l *concurrencyLimiter) reserveAccount(account string, sum int64) {
+	if account == "" {
+		return
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if sum <= 0 {
+		delete(l.accounts, account)
+		return
+	}
+	l.accounts[account] = sum
+}
+
+// accountReserved returns the reserved concurrency sum of the account, 0 if it has none
+func (l *concurrencyLimiter) accountReserved(account string) int64 {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.accounts[account]
+}
+
+// acquire admits an invocation of the function
+func (l *concurrencyLimiter) acquire(key string, reserved *int64) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	fc, ok := l.functions[key]
+	if !ok {
+		fc = &FunctionConcurrency{}
+		l.functions[key] = fc
+	}
+	fc.Reserved = reserved
+	if reserved != nil && fc.InFlight >= *reserved {
+		fc.Throttled++
+		l.throttled++
+		return innerErr.NewTooManyRequestsException(
+			fmt.Sprintf("reserved concurrency %d of function exceeded", *reserved), nil)
+	}
+	fc.InFlight++
+	fc.Admitted++
+	l.inFlight++
+	l.admitted++
+	return nil
+}
+
+func (l *concurrencyLimiter) release(key string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	fc, ok := l.functions[key]
+	if !ok || fc.InFlight == 0 {
+		return
+	}
+	fc.InFlight--
+	l.inFlight--
+	if fc.InFlight == 0 && fc.Reserved == nil {
+		delete(l.functions, key)
+	}
+}
+
+// admitCold decides whether the function may occupy one of the cold runtimes
+func (l *concurrencyLimiter) admitCold(key string, cold int, idle idleWarmCounter) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	fc, ok := l.functions[key]
+	if ok && fc.Reserved != nil {
+		return nil
+	}
+	// nothing is reserved, the request waits for a released runtime if no cold one is left
+	unused := l.unusedReservedLocked(idle)
+	if unused == 0 || int64(cold) > unused {
+		return nil
+	}
+	if ok {
+		fc.Throttled++
+	}
+	l.throttled++
+	return innerErr.NewTooManyRequestsException(
+		fmt.Sprintf("%d cold runtimes are reserved for other functions", unused), nil)
+}
+
+// unusedReservedLocked: reserved slots which have not been taken by their functions,
+// slots served by idle warm runtimes of the function need no cold runtime;
+// the reserved sum of an account beyond its known functions is kept as well
+func (l *concurrencyLimiter) unusedReservedLocked(idle idleWarmCounter) (unused int64) {
+	known := make(map[string]int64)
+	for _, fc := range l.functions {
+		if fc.Reserved == nil {
+			continue
+		}
+		known[fc.account] += *fc.Reserved
+		free := *fc.Reserved - fc.InFlight
+		if idle != nil && free > 0 {
+			for commitID := range fc.commitIDs {
+				free -= int64(idle(commitID))
+			}
+		}
+		if free > 0 {
+			unused += free
+		}
+	}
+	for account, sum := range l.accounts {
+		if sum > known[account] {
+			unused += sum - known[account]
+		}
+	}
+	return
+}
+
+func (l *concurrencyLimiter) statistics(idle idleWarmCounter) *ConcurrencyStatistics {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	s := &ConcurrencyStatistics{
+		UnusedReserved:    l.unusedReservedLocked(idle),
+		InFlight:          l.inFlight,
+		Admitted:          l.admitted,
+		Throttled:         l.throttled,
+		ReservedFunctions: make(map[string]*FunctionConcurrency),
+	}
+	for key, fc := range l.functions {
+		if fc.Reserved == nil {
+			continue
+		}
+		s.ReservedSum += *fc.Reserved
+		c := *fc
+		s.ReservedFunctions[key] = &c
+	}
+	return s
+}
+
+// concurrencyKey: unqualified function brn shared by all versions and aliases
+func concurrencyKey(ctx *InvokeContext) string {
+	conf := ctx.Function.Configuration
+	if conf.FunctionArn != nil {
+		if key, err := unqualifiedFunctionBRN(*conf.FunctionArn); err == nil {
+			return key
+		}
+	}
+	if conf.FunctionName != nil {
+		return conf.Uid + ":" + *conf.FunctionName
+	}
+	return ctx.FunctionBRN
+}
+
+func unqualifiedFunctionBRN(functionBRN string) (string, error) {
+	fb, err := brn.ParseFunction(functionBRN)
+	if err != nil {
+		return "", err
+	}
+	fb.Resource = "function:" + fb.FunctionName
+	return fb.BRN.String(), nil
+}
+
+func reservedConcurrency(ctx *InvokeContext) *int64 {
+	if ctx.Function.Concurrency == nil {
+		return nil
+	}
+	return ctx.Function.Concurrency.ReservedConcurrentExecutions
+}
+
+func (controller *Controller) acquireConcurrency(ctx *InvokeContext) error {
+	ctx.concurrencyKey = concurrencyKey(ctx)
+	conf := ctx.Function.Configuration
+	r := reservation{
+		key:      ctx.concurrencyKey,
+		account:  conf.Uid,
+		reserved: reservedConcurrency(ctx),
+	}
+	if conf.CommitID != nil {
+		r.commitID = *conf.CommitID
+	}
+	controller.concurrency.reserve(r)
+	if ctx.Function.Concurrency != nil {
+		controller.concurrency.reserveAccount(conf.Uid, int64(ctx.Function.Concurrency.AccountReservedSum))
+	}
+	if err := controller.concurrency.acquire(ctx.concurrencyKey, reservedConcurrency(ctx)); err != nil {
+		ctx.concurrencyKey = ""
+		return err
+	}
+	return nil
+}
+
+func (controller *Controller) releaseConcurrency(ctx *InvokeContext) {
+	if ctx.concurrencyKey == "" {
+		return
+	}
+	controller.concurrency.release(ctx.concurrencyKey)
+}
+
+// loadReservations learns the reserved concurrency of all functions up front,
+// so their cold runtimes are kept before their first invocations
+func (controller *Controller) loadReservations() error {
+	out, err := controller.insideDataStorer.ListReservedConcurrency(&api.ListReservedConcurrencyInput{
+		RequestID: id.GetRequestID(),
+	})
+	if err != nil {
+		return err
+	}
+	for _, rc := range out.Functions {
+		key, err := unqualifiedFunctionBRN(rc.FunctionBrn)
+		if err != nil {
+			logs.Warnf("skip reserved concurrency of %s: %s", rc.FunctionBrn, err)
+			continue
+		}
+		reserved := rc.ReservedConcurrentExecutions
+		controller.concurrency.reserve(reservation{key: key, account: rc.Uid, reserved: &reserved})
+	}
+	logs.Infof("load reserved concurrency of %d functions", len(out.Functions))
+	return nil
+}
+
+// idleWarmRuntimes counts the idle warm runtimes of the function version for the concurrency limiter
+func (controller *Controller) idleWarmRuntimes(commitID string) int {
+	return controller.runtimeDispatcher.IdleWarmRuntimeCount(commitID)
+}
@@ -0,0 +1,153 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/lambda"
+
+	"github.com/baidu/easyfaas/pkg/api"
+)
+
+func TestConcurrencyLimiter(t *testing.T) {
+	l := newConcurrencyLimiter()
+	reserved := int64(2)
+
+	if err := l.acquire("reserved", &reserved); err != nil {
+		t.Fatalf("first invocation should be admitted: %s", err)
+	}
+	if err := l.acquire("reserved", &reserved); err != nil {
+		t.Fatalf("second invocation should be admitted: %s", err)
+	}
+	if err := l.acquire("reserved", &reserved); err == nil {
+		t.Fatalf("third invocation should be throttled")
+	}
+	l.release("reserved")
+	if err := l.acquire("reserved", &reserved); err != nil {
+		t.Fatalf("invocation should be admitted after release: %s", err)
+	}
+
+	zero := int64(0)
+	if err := l.acquire("disabled", &zero); err == nil {
+		t.Fatalf("function with zero reserved concurrency should be throttled")
+	}
+
+	s := l.statistics(nil)
+	if s.ReservedSum != 2 || s.InFlight != 2 || s.Throttled != 2 || s.Admitted != 3 {
+		t.Errorf("unexpected statistics %+v", s)
+	}
+}
+
+func TestConcurrencyLimiterKeepsReservedColdRuntimes(t *testing.T) {
+	l := newConcurrencyLimiter()
+	reserved := int64(2)
+
+	if err := l.acquire("reserved", &reserved); err != nil {
+		t.Fatal(err)
+	}
+	l.release("reserved")
+	if err := l.acquire("other", nil); err != nil {
+		t.Fatalf("unreserved invocation should be admitted: %s", err)
+	}
+
+	// two cold runtimes are kept for the reserved function
+	if err := l.admitCold("other", 2, nil); err == nil {
+		t.Errorf("unreserved function should not take reserved cold runtimes")
+	}
+	if err := l.admitCold("other", 3, nil); err != nil {
+		t.Errorf("unreserved function should take spare cold runtime: %s", err)
+	}
+	if err := l.admitCold("reserved", 1, nil); err != nil {
+		t.Errorf("reserved function should take its cold runtime: %s", err)
+	}
+
+	l.release("other")
+	if _, ok := l.functions["other"]; ok {
+		t.Errorf("idle unreserved function should be removed")
+	}
+}
+
+func TestConcurrencyLimiterReservationsUpFront(t *testing.T) {
+	l := newConcurrencyLimiter()
+	reserved := int64(2)
+
+	// a reservation loaded before any invocation keeps its cold runtimes
+	l.reserve(reservation{key: "reserved", account: "account-1", reserved: &reserved})
+	if err := l.admitCold("other", 2, nil); err == nil {
+		t.Errorf("unreserved function should not take reserved cold runtimes")
+	}
+
+	// idle warm runtimes of the reserved function serve its reservation
+	l.reserve(reservation{key: "reserved", account: "account-1", commitID: "commit-1", reserved: &reserved})
+	idle := func(commitID string) int {
+		if commitID == "commit-1" {
+			return 1
+		}
+		return 0
+	}
+	if err := l.admitCold("other", 2, idle); err != nil {
+		t.Errorf("only one cold runtime should be kept: %s", err)
+	}
+
+	// the reserved sum of the account covers its functions not seen yet
+	l.reserveAccount("account-1", 5)
+	if s := l.statistics(idle); s.UnusedReserved != 4 {
+		t.Errorf("expect 4 unused reserved slots, got %+v", s)
+	}
+	l.reserveAccount("account-1", 0)
+	if s := l.statistics(idle); s.UnusedReserved != 1 {
+		t.Errorf("expect 1 unused reserved slot, got %+v", s)
+	}
+}
+
+func TestLoadReservations(t *testing.T) {
+	controller, _ := newTestController(1, &fakeDataStorer{
+		reserved: []*api.ReservedConcurrency{{
+			FunctionBrn:                  "brn:cloud:faas:bj:8f6e6a4c4aa0f6a5a4d3fe2d3d3c5e39:function:hello:$LATEST",
+			Uid:                          "8f6e6a4c4aa0f6a5a4d3fe2d3d3c5e39",
+			ReservedConcurrentExecutions: 3,
+		}},
+	})
+	if err := controller.loadReservations(); err != nil {
+		t.Fatal(err)
+	}
+	s := controller.concurrency.statistics(nil)
+	fc, ok := s.ReservedFunctions["brn:cloud:faas:bj:8f6e6a4c4aa0f6a5a4d3fe2d3d3c5e39:function:hello"]
+	if !ok || *fc.Reserved != 3 || s.UnusedReserved != 3 {
+		t.Errorf("unexpected statistics %+v", s)
+	}
+}
+
+func TestConcurrencyKey(t *testing.T) {
+	arn := "brn:cloud:faas:bj:8f6e6a4c4aa0f6a5a4d3fe2d3d3c5e39:function:hello:1"
+	name := "hello"
+	ctx := &InvokeContext{
+		Function: &api.GetFunctionOutput{
+			Configuration: &api.FunctionConfiguration{
+				FunctionConfiguration: lambda.FunctionConfiguration{
+					FunctionArn:  &arn,
+					FunctionName: &name,
+				},
+			},
+		},
+	}
+	expected := "brn:cloud:faas:bj:8f6e6a4c4aa0f6a5a4d3fe2d3d3c5e39:function:hello"
+	if key := concurrencyKey(ctx); key != expected {
+		t.Errorf("expect key %s, got %s", expected, key)
+	}
+}
@@ -63,4 +63,19 @@ type InvokeContext struct {
 
 	InvokeType  string
 	TriggerType string
+
+	// priority class of the invocation when competing for runtimes
+	Priority rtctrl.Priority
+
+	// function key of the admitted concurrency, released after invocation
+	concurrencyKey string
+
+	// how the runtime of the invocation is got, eg. warm or cold
+	runtimeVia string
+
+	// the half-open probe of the warm-up breaker is held until the cold start is resolved
+	coldStartProbe bool
+
+	// value the canary routing of the request sticks to
+	canaryKey string
 }
@@ -0,0 +1,162 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/baidu/easyfaas/pkg/api"
+	"github.com/baidu/easyfaas/pkg/controller/destination"
+	"github.com/baidu/easyfaas/pkg/controller/eventqueue"
+	"github.com/baidu/easyfaas/pkg/util/id"
+	"github.com/baidu/easyfaas/pkg/util/json"
+	"github.com/baidu/easyfaas/pkg/util/logs"
+)
+
+const destinationRecordVersion = "1.0"
+
+func (controller *Controller) initDestinations(o *destination.Options) {
+	controller.webhook = destination.NewWebhook(o)
+	controller.destinations = destination.NewDispatcher(o)
+}
+
+// destinationHops returns the number of destination functions the event has passed through
+func destinationHops(ctx *InvokeContext) int {
+	if ctx.Request == nil {
+		return 0
+	}
+	hops, err := strconv.Atoi(ctx.Request.Headers[api.HeaderDestinationHops])
+	if err != nil || hops < 0 {
+		return 0
+	}
+	return hops
+}
+
+// finishEvent records the result of an event attempt and delivers the final one to the destination
+func (controller *Controller) finishEvent(ctx *InvokeContext, res *eventqueue.Result, attempts int, retry bool) {
+	controller.recordResult(ctx, res, retry)
+	if !retry {
+		controller.deliverDestination(ctx, res, attempts)
+	}
+}
+
+// deliverDestination queues the record of an event invocation for its on-success or on-failure destination
+func (controller *Controller) deliverDestination(ctx *InvokeContext, res *eventqueue.Result, attempts int) {
+	if ctx.Function == nil || ctx.Function.Configuration == nil || ctx.Function.Configuration.DestinationConfig == nil {
+		return
+	}
+	config := ctx.Function.Configuration.DestinationConfig
+	target := config.OnFailure
+	condition := eventqueue.ReasonRetriesExhausted
+	switch res.Outcome {
+	case eventqueue.OutcomeSucceeded:
+		target = config.OnSuccess
+		condition = api.DestinationConditionSuccess
+	case eventqueue.OutcomeFailed:
+		condition = eventqueue.ReasonUnretryable
+	}
+	if target == nil || target.Destination == "" {
+		return
+	}
+	record := &api.DestinationRecord{
+		Version:   destinationRecordVersion,
+		Timestamp: time.Now(),
+		RequestContext: api.DestinationRequestContext{
+			RequestID:              ctx.RequestID,
+			ExternalRequestID:      ctx.ExternalRequestID,
+			FunctionBrn:            ctx.FunctionBRN,
+			Condition:              condition,
+			ApproximateInvokeCount: attempts,
+		},
+		RequestPayload: string(ctx.Request.Body),
+		ResponseContext: api.DestinationResponseContext{
+			StatusCode:    res.StatusCode,
+			FunctionError: res.ErrorType,
+		},
+		ResponsePayload: string(ctx.Response.Body),
+	}
+
+	kind, err := destination.KindOf(target.Destination)
+	if err != nil {
+		ctx.Logger.Errorf("skip destination of %s: %s", ctx.FunctionBRN, err)
+		return
+	}
+	address := target.Destination
+	var send func() error
+	switch kind {
+	case destination.KindFunction:
+		// destination functions may have destinations as well, a chain is cut at max hops to break loops
+		hops := destinationHops(ctx) + 1
+		if hops > controller.runOptions.DestinationOptions.MaxHops {
+			ctx.Logger.Warnf("skip destination %s: %d destination functions in a row", address, hops)
+			return
+		}
+		send = func() error {
+			return controller.invokeDestination(ctx, address, record, hops)
+		}
+	case destination.KindWebhook:
+		if err := controller.webhook.Check(address); err != nil {
+			ctx.Logger.Errorf("skip destination of %s: %s", ctx.FunctionBRN, err)
+			return
+		}
+		send = func() error {
+			return controller.webhook.Send(address, record)
+		}
+	}
+	if err := controller.destinations.Deliver(address, send, ctx.Logger); err != nil {
+		ctx.Logger.Errorf("deliver %s result to destination %s failed: %s", condition, address, err)
+		return
+	}
+	ctx.Logger.Infof("queue %s result for destination %s", condition, address)
+}
+
+// invokeDestination invokes the destination function with the record as an event,
+// hops is the number of destination functions the record has passed through
+func (controller *Controller) invokeDestination(parent *InvokeContext, functionBRN string, record *api.DestinationRecord, hops int) error {
+	body, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	headers := map[string]string{
+		"Content-Type":            "application/json",
+		api.HeaderDestinationHops: strconv.Itoa(hops),
+	}
+	requestID := id.GetRequestID()
+	ctx := &InvokeContext{
+		RunOptions:        controller.runOptions,
+		ExternalRequestID: requestID,
+		RequestID:         requestID,
+		AccountID:         parent.AccountID,
+		Authorization:     parent.Authorization,
+		CallerUser:        parent.CallerUser,
+		Clients:           controller.NewClients(ClientModeInside),
+		Request:           api.NewInvokeProxyRequest(headers, body, nil),
+		Response:          api.NewInvokeProxyResponseWithRequestID(requestID),
+		Logger: logs.NewLogger().WithField("request_id", requestID).
+			WithField("external_request_id", requestID).WithField("invoke-type", api.InvokeTypeEvent).
+			WithField("source_request_id", parent.RequestID),
+		LogType:     api.LogTypeNone,
+		InvokeType:  api.InvokeTypeEvent,
+		TriggerType: api.TriggerTypeGeneric,
+		FunctionBRN: functionBRN,
+	}
+	if controller.runOptions.RecommendedOptions.Features.EnableMetrics {
+		ctx.Metrics = NewInvokeMetrics(requestID)
+	}
+	return controller.enqueueEvent(ctx)
+}
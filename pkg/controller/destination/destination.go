@@ -0,0 +1,101 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package destination
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/baidu/easyfaas/pkg/api"
+	"github.com/baidu/easyfaas/pkg/brn"
+	"github.com/baidu/easyfaas/pkg/util/json"
+)
+
+type Kind string
+
+const (
+	KindFunction Kind = "function"
+	KindWebhook  Kind = "webhook"
+)
+
+// KindOf tells whether the destination is a function brn or a webhook address
+func KindOf(destination string) (Kind, error) {
+	if strings.HasPrefix(destination, "http://") || strings.HasPrefix(destination, "https://") {
+		return KindWebhook, nil
+	}
+	if _, err := brn.Parse(destination); err != nil {
+		return "", fmt.Errorf("invalid destination %s: %s", destination, err)
+	}
+	return KindFunction, nil
+}
+
+// Webhook posts destination records to http endpoints of the allowed hosts
+type Webhook struct {
+	client       *http.Client
+	allowedHosts []string
+}
+
+func NewWebhook(o *Options) *Webhook {
+	return &Webhook{
+		client: &http.Client{
+			Timeout: o.WebhookTimeout,
+			// a redirect could lead the request to any host
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		allowedHosts: o.WebhookAllowedHosts,
+	}
+}
+
+// Check rejects the address unless its host is allowed
+func (w *Webhook) Check(address string) error {
+	u, err := url.Parse(address)
+	if err != nil {
+		return fmt.Errorf("invalid webhook %s: %s", address, err)
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range w.allowedHosts {
+		allowed = strings.ToLower(allowed)
+		if host == allowed || (strings.HasPrefix(allowed, ".") && strings.HasSuffix(host, allowed)) {
+			return nil
+		}
+	}
+	return fmt.Errorf("webhook host %s is not allowed", host)
+}
+
+func (w *Webhook) Send(address string, record *api.DestinationRecord) error {
+	if err := w.Check(address); err != nil {
+		return err
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	resp, err := w.client.Post(address, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("destination webhook responded %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package destination
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/baidu/easyfaas/pkg/api"
+	"github.com/baidu/easyfaas/pkg/util/logs"
+)
+
+func TestKindOf(t *testing.T) {
+	cases := []struct {
+		destination string
+		kind        Kind
+		err         bool
+	}{
+		{destination: "brn:cloud:faas:bj:8f6e:function:next:$LATEST", kind: KindFunction},
+		{destination: "https://example.com/hook", kind: KindWebhook},
+		{destination: "http://127.0.0.1:8080", kind: KindWebhook},
+		{destination: "next-function", err: true},
+	}
+	for _, c := range cases {
+		kind, err := KindOf(c.destination)
+		if (err != nil) != c.err || kind != c.kind {
+			t.Errorf("%s: expect kind %q error %v, got %q %v", c.destination, c.kind, c.err, kind, err)
+		}
+	}
+}
+
+func TestWebhookSend(t *testing.T) {
+	var got api.DestinationRecord
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		if got.RequestContext.RequestID == "rejected" {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	o := NewOptions()
+	o.WebhookAllowedHosts = []string{"127.0.0.1"}
+	webhook := NewWebhook(o)
+	record := &api.DestinationRecord{
+		RequestContext: api.DestinationRequestContext{RequestID: "req", Condition: api.DestinationConditionSuccess},
+	}
+	if err := webhook.Send(server.URL, record); err != nil {
+		t.Errorf("send record failed: %s", err)
+	}
+	if got.RequestContext.Condition != api.DestinationConditionSuccess {
+		t.Errorf("unexpected record received: %+v", got)
+	}
+	record.RequestContext.RequestID = "rejected"
+	if err := webhook.Send(server.URL, record); err == nil {
+		t.Errorf("expect error when the webhook rejects the record")
+	}
+}
+
+func TestWebhookCheck(t *testing.T) {
+	o := NewOptions()
+	o.WebhookAllowedHosts = []string{"hooks.example.com", ".example.org"}
+	webhook := NewWebhook(o)
+	cases := map[string]bool{
+		"https://hooks.example.com/a":   true,
+		"https://HOOKS.example.com:443": true,
+		"https://a.example.org/hook":    true,
+		"https://example.org/hook":      false,
+		"http://169.254.169.254/latest": false,
+		"http://127.0.0.1:8080":         false,
+	}
+	for address, allowed := range cases {
+		if err := webhook.Check(address); (err == nil) != allowed {
+			t.Errorf("%s: allowed %v, want %v", address, err == nil, allowed)
+		}
+	}
+	if err := NewWebhook(NewOptions()).Check("https://hooks.example.com"); err == nil {
+		t.Error("webhooks should be disabled without allowed hosts")
+	}
+}
+
+func TestDispatcherRetry(t *testing.T) {
+	o := NewOptions()
+	o.RetryInterval = time.Millisecond
+	o.MaxRetries = 2
+	d := NewDispatcher(o)
+	defer d.Stop()
+
+	attempts := make(chan int, 10)
+	var n int
+	err := d.Deliver("flaky", func() error {
+		n++
+		attempts <- n
+		if n < 2 {
+			return fmt.Errorf("unavailable")
+		}
+		return nil
+	}, logs.NewLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for want := 1; want <= 2; want++ {
+		select {
+		case got := <-attempts:
+			if got != want {
+				t.Fatalf("expect attempt %d, got %d", want, got)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("attempt %d timeout", want)
+		}
+	}
+	select {
+	case <-attempts:
+		t.Error("succeeded delivery should not be retried")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
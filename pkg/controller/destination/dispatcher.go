@@ -0,0 +1,118 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package destination
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/baidu/easyfaas/pkg/util/logs"
+)
+
+var ErrQueueFull = errors.New("destination queue is full")
+
+type delivery struct {
+	name   string
+	send   func() error
+	logger *logs.Logger
+}
+
+// Dispatcher delivers records in background, so that a slow destination never blocks the event workers;
+// failed deliveries are retried with exponential backoff
+type Dispatcher struct {
+	queue         chan *delivery
+	maxRetries    int
+	retryInterval time.Duration
+	stopOnce      sync.Once
+	stopCh        chan struct{}
+	wg            sync.WaitGroup
+}
+
+func NewDispatcher(o *Options) *Dispatcher {
+	d := &Dispatcher{
+		queue:         make(chan *delivery, o.QueueLength),
+		maxRetries:    o.MaxRetries,
+		retryInterval: o.RetryInterval,
+		stopCh:        make(chan struct{}),
+	}
+	workers := o.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	for i := 0; i < workers; i++ {
+		d.wg.Add(1)
+		go d.work()
+	}
+	return d
+}
+
+// Deliver queues the delivery to the destination named name
+func (d *Dispatcher) Deliver(name string, send func() error, logger *logs.Logger) error {
+	select {
+	case d.queue <- &delivery{name: name, send: send, logger: logger}:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Stop drops the queued deliveries and waits for the running ones
+func (d *Dispatcher) Stop() {
+	d.stopOnce.Do(func() {
+		close(d.stopCh)
+	})
+	d.wg.Wait()
+	if n := len(d.queue); n > 0 {
+		logs.Warnf("%d destination records are dropped", n)
+	}
+}
+
+func (d *Dispatcher) work() {
+	defer d.wg.Done()
+	for {
+		select {
+		case <-d.stopCh:
+			return
+		case dl := <-d.queue:
+			d.deliver(dl)
+		}
+	}
+}
+
+func (d *Dispatcher) deliver(dl *delivery) {
+	interval := d.retryInterval
+	for attempt := 0; ; attempt++ {
+		err := dl.send()
+		if err == nil {
+			return
+		}
+		if attempt >= d.maxRetries {
+			dl.logger.Errorf("deliver record to destination %s failed after %d attempts: %s", dl.name, attempt+1, err)
+			return
+		}
+		dl.logger.Warnf("deliver record to destination %s failed, retry in %s: %s", dl.name, interval, err)
+		timer := time.NewTimer(interval)
+		select {
+		case <-timer.C:
+		case <-d.stopCh:
+			timer.Stop()
+			return
+		}
+		interval *= 2
+	}
+}
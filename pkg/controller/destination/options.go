@@ -0,0 +1,73 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package destination
+package destination
+
+import (
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+const (
+	defaultWebhookTimeout = 5 * time.Second
+	defaultWorkers        = 4
+	defaultQueueLength    = 1000
+	defaultMaxRetries     = 3
+	defaultRetryInterval  = time.Second
+	defaultMaxHops        = 3
+)
+
+type Options struct {
+	// Timeout of the request to a webhook destination
+	WebhookTimeout time.Duration
+	// Hosts webhook destinations are allowed to post to, webhooks are disabled if it is empty;
+	// a host starting with a dot matches its subdomains
+	WebhookAllowedHosts []string
+	// Number of the workers delivering records
+	Workers int
+	// Max number of records waiting for delivery
+	QueueLength int
+	// Max retries of a failed delivery
+	MaxRetries int
+	// Interval before the first retry, doubled for every retry
+	RetryInterval time.Duration
+	// Max length of a chain of destination functions
+	MaxHops int
+}
+
+func NewOptions() *Options {
+	return &Options{
+		WebhookTimeout: defaultWebhookTimeout,
+		Workers:        defaultWorkers,
+		QueueLength:    defaultQueueLength,
+		MaxRetries:     defaultMaxRetries,
+		RetryInterval:  defaultRetryInterval,
+		MaxHops:        defaultMaxHops,
+	}
+}
+
+func (s *Options) AddFlags(fs *pflag.FlagSet) {
+	fs.DurationVar(&s.WebhookTimeout, "destination-webhook-timeout", s.WebhookTimeout, "timeout of the request to a webhook destination")
+	fs.StringSliceVar(&s.WebhookAllowedHosts, "destination-webhook-allowed-hosts", s.WebhookAllowedHosts,
+		"hosts webhook destinations are allowed to post to, a host starting with a dot matches its subdomains")
+	fs.IntVar(&s.Workers, "destination-workers", s.Workers, "number of the workers delivering records to destinations")
+	fs.IntVar(&s.QueueLength, "destination-queue-length", s.QueueLength, "max number of records waiting for delivery")
+	fs.IntVar(&s.MaxRetries, "destination-max-retries", s.MaxRetries, "max retries of a failed delivery")
+	fs.DurationVar(&s.RetryInterval, "destination-retry-interval", s.RetryInterval, "interval before the first retry of a failed delivery")
+	fs.IntVar(&s.MaxHops, "destination-max-hops", s.MaxHops, "max length of a chain of destination functions")
+}
@@ -0,0 +1,119 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/baidu/easyfaas/pkg/api"
+	"github.com/baidu/easyfaas/pkg/controller/destination"
+	"github.com/baidu/easyfaas/pkg/controller/eventqueue"
+	"github.com/baidu/easyfaas/pkg/controller/resultstore"
+	"github.com/baidu/easyfaas/pkg/util/logs"
+)
+
+func TestDeliverDestination(t *testing.T) {
+	records := make(chan *api.DestinationRecord, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		record := &api.DestinationRecord{}
+		json.NewDecoder(r.Body).Decode(record)
+		records <- record
+	}))
+	defer server.Close()
+
+	controller, _ := newTestController(0, &fakeDataStorer{})
+	controller.initResultStore(resultstore.NewOptions(), &eventqueue.Options{})
+	o := destination.NewOptions()
+	o.WebhookAllowedHosts = []string{"127.0.0.1"}
+	controller.initDestinations(o)
+	defer controller.destinations.Stop()
+	ctx := &InvokeContext{
+		RequestID:   "source-request",
+		FunctionBRN: "brn:cloud:faas:bj:8f6e:function:source:$LATEST",
+		Function: &api.GetFunctionOutput{Configuration: &api.FunctionConfiguration{
+			DestinationConfig: &api.DestinationConfig{
+				OnSuccess: &api.DestinationTarget{Destination: server.URL},
+				OnFailure: &api.DestinationTarget{Destination: "brn:cloud:faas:bj:8f6e:function:failure:$LATEST"},
+			},
+		}},
+		Request:  api.NewInvokeProxyRequest(nil, []byte("event"), nil),
+		Response: api.NewInvokeProxyResponseWithRequestID("source-request"),
+		Logger:   logs.NewLogger(),
+	}
+	ctx.Response.Body = []byte("result")
+	controller.recordPending(ctx)
+
+	controller.finishEvent(ctx, &eventqueue.Result{Outcome: eventqueue.OutcomeRetryable}, 1, true)
+	if l := len(records); l != 0 {
+		t.Errorf("expect no delivery before the final attempt, got %d", l)
+	}
+
+	controller.finishEvent(ctx, &eventqueue.Result{Outcome: eventqueue.OutcomeSucceeded, StatusCode: http.StatusOK}, 2, false)
+	record := <-records
+	if record.RequestContext.RequestID != "source-request" || record.RequestContext.Condition != api.DestinationConditionSuccess ||
+		record.RequestContext.ApproximateInvokeCount != 2 || record.RequestPayload != "event" || record.ResponsePayload != "result" {
+		t.Errorf("unexpected destination record %+v", record)
+	}
+
+	controller.finishEvent(ctx, &eventqueue.Result{Outcome: eventqueue.OutcomeFailed, ErrorType: "Unhandled"}, 1, false)
+	if !waitResults(controller, 2) {
+		t.Errorf("expect the failure destination function invoked as an event, got %d results", controller.results.Len())
+	}
+}
+
+func TestDestinationLoopGuard(t *testing.T) {
+	controller, _ := newTestController(0, &fakeDataStorer{})
+	controller.initResultStore(resultstore.NewOptions(), &eventqueue.Options{})
+	controller.initDestinations(destination.NewOptions())
+	defer controller.destinations.Stop()
+	newCtx := func(hops string) *InvokeContext {
+		return &InvokeContext{
+			RequestID:   "source-request",
+			FunctionBRN: "brn:cloud:faas:bj:8f6e:function:source:$LATEST",
+			Function: &api.GetFunctionOutput{Configuration: &api.FunctionConfiguration{
+				DestinationConfig: &api.DestinationConfig{
+					OnSuccess: &api.DestinationTarget{Destination: "brn:cloud:faas:bj:8f6e:function:source:$LATEST"},
+				},
+			}},
+			Request:  api.NewInvokeProxyRequest(map[string]string{api.HeaderDestinationHops: hops}, nil, nil),
+			Response: api.NewInvokeProxyResponseWithRequestID("source-request"),
+			Logger:   logs.NewLogger(),
+		}
+	}
+
+	// the chain of destination functions is cut at max hops
+	controller.deliverDestination(newCtx("3"), &eventqueue.Result{Outcome: eventqueue.OutcomeSucceeded}, 1)
+	controller.deliverDestination(newCtx("2"), &eventqueue.Result{Outcome: eventqueue.OutcomeSucceeded}, 1)
+	if !waitResults(controller, 1) {
+		t.Errorf("expect one destination function invoked, got %d results", controller.results.Len())
+	}
+}
+
+func waitResults(controller *Controller, n int) bool {
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		if controller.results.Len() == n {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return false
+}
@@ -0,0 +1,165 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	routing "github.com/qiangxue/fasthttp-routing"
+
+	innerErr "github.com/baidu/easyfaas/pkg/error"
+	"github.com/baidu/easyfaas/pkg/util/id"
+	"github.com/baidu/easyfaas/pkg/util/logs"
+)
+
+// drainer tracks in-flight invocations and rejects new ones once draining
+type drainer struct {
+	lock     sync.Mutex
+	draining bool
+	inflight int
+	// idle is closed when draining and nothing is in flight
+	idle chan struct{}
+	// stopCh is closed when draining starts to stop the background tasks
+	stopCh chan struct{}
+	// drained is closed when the controller is ready to exit
+	drained chan struct{}
+}
+
+func newDrainer() *drainer {
+	return &drainer{
+		idle:    make(chan struct{}),
+		stopCh:  make(chan struct{}),
+		drained: make(chan struct{}),
+	}
+}
+
+// admit starts an invocation unless the controller is draining
+func (d *drainer) admit() error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.draining {
+		return innerErr.NewServiceUnavailableException("controller is draining", nil)
+	}
+	d.inflight++
+	return nil
+}
+
+// track starts work spawned by an admitted invocation, it is waited even when draining
+func (d *drainer) track() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.inflight++
+}
+
+func (d *drainer) done() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.inflight--
+	if d.draining && d.inflight == 0 {
+		d.closeIdle()
+	}
+}
+
+func (d *drainer) closeIdle() {
+	select {
+	case <-d.idle:
+	default:
+		close(d.idle)
+	}
+}
+
+// start switches to draining, returns false when already draining
+func (d *drainer) start() bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.draining {
+		return false
+	}
+	d.draining = true
+	close(d.stopCh)
+	if d.inflight == 0 {
+		d.closeIdle()
+	}
+	return true
+}
+
+// wait returns the number of invocations still in flight after timeout
+func (d *drainer) wait(timeout time.Duration) int {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-d.idle:
+	case <-timer.C:
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.inflight
+}
+
+func (d *drainer) isDraining() bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.draining
+}
+
+// Drain rejects new invocations, waits for in-flight ones up to the drain timeout
+// and cools down the runtimes through funclet
+func (controller *Controller) Drain() {
+	if !controller.drain.start() {
+		<-controller.drain.drained
+		return
+	}
+	logger := logs.NewLogger().WithField("task_id", id.GetTaskID())
+	timeout := time.Duration(controller.runOptions.DrainTimeout) * time.Second
+	logger.Infof("start draining, wait %s for in-flight invocations", timeout)
+
+	// running attempts are waited through handleEvent, pending events stay on disk
+	if controller.eventQueue != nil {
+		go controller.eventQueue.Stop()
+	}
+	if left := controller.drain.wait(timeout); left > 0 {
+		logger.Warnf("drain timeout with %d invocations in flight", left)
+	}
+	if controller.destinations != nil {
+		controller.destinations.Stop()
+	}
+
+	for _, rt := range controller.runtimeDispatcher.RuntimeList() {
+		recommend, err := controller.runtimeDispatcher.DrainRuntime(rt)
+		if err != nil {
+			logger.V(9).Infof("drain runtime %s skipped: %s", rt.RuntimeID, err)
+			continue
+		}
+		controller.resetStoppedRuntime(rt, recommend, logger)
+	}
+	logger.Info("finish draining")
+	close(controller.drain.drained)
+}
+
+// Drained is closed when the controller finishes draining
+func (controller *Controller) Drained() <-chan struct{} {
+	return controller.drain.drained
+}
+
+// DrainHandler starts draining in background
+func (controller *Controller) DrainHandler(c *routing.Context) error {
+	go controller.Drain()
+	c.SetStatusCode(http.StatusAccepted)
+	return nil
+}
@@ -0,0 +1,103 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/baidu/easyfaas/pkg/controller/eventqueue"
+	"github.com/baidu/easyfaas/pkg/controller/rtctrl"
+	innerErr "github.com/baidu/easyfaas/pkg/error"
+)
+
+func TestDrain(t *testing.T) {
+	controller, funclet := newTestController(2, &fakeDataStorer{})
+	controller.runOptions.DrainTimeout = 5
+
+	input := &rtctrl.InvocationInput{Configuration: testFunction("", "commit-1").Configuration}
+	rt, _ := controller.runtimeDispatcher.OccupyColdRuntime(input)
+	rt.SetState(rtctrl.RuntimeStateWarm)
+	rt.Provisioned = true
+
+	if err := controller.drain.admit(); err != nil {
+		t.Fatalf("expect admitted before draining: %s", err)
+	}
+	go controller.Drain()
+	for !controller.drain.isDraining() {
+		time.Sleep(time.Millisecond)
+	}
+
+	err := controller.drain.admit()
+	if e, ok := err.(innerErr.FinalError); !ok || e.Status != http.StatusServiceUnavailable {
+		t.Errorf("expect 503 when draining, got %v", err)
+	}
+	select {
+	case <-controller.Drained():
+		t.Fatal("drain should wait for the in-flight invocation")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	// work of the in-flight invocation is still tracked
+	controller.drain.track()
+	controller.drain.done()
+	rt.Release()
+	controller.drain.done()
+
+	select {
+	case <-controller.Drained():
+	case <-time.After(time.Second):
+		t.Fatal("drain should finish after the invocation")
+	}
+	if fmt.Sprint(funclet.coolDowns) != fmt.Sprint([]string{rt.RuntimeID}) {
+		t.Errorf("expect runtime %s cooled down, got %v", rt.RuntimeID, funclet.coolDowns)
+	}
+
+	// draining again waits for the first one
+	controller.Drain()
+
+	// late work finishing after drain does not close twice
+	controller.drain.track()
+	controller.drain.done()
+}
+
+func TestDrainTimeout(t *testing.T) {
+	controller, _ := newTestController(1, &fakeDataStorer{})
+	controller.runOptions.DrainTimeout = 0
+	controller.drain.admit()
+	controller.Drain()
+	if left := controller.drain.wait(0); left != 1 {
+		t.Errorf("expect 1 invocation left after timeout, got %d", left)
+	}
+}
+
+func TestDrainDefersEvents(t *testing.T) {
+	controller, _ := newTestController(1, &fakeDataStorer{})
+	controller.runOptions.DrainTimeout = 0
+	controller.Drain()
+
+	// attempts picked up after draining starts are not run
+	res := controller.handleEvent(&eventqueue.Event{ID: "event-1", Attempts: 1})
+	if res.Outcome != eventqueue.OutcomeDeferred {
+		t.Errorf("expect event deferred when draining, got %s", res.Outcome)
+	}
+	if left := controller.drain.wait(0); left != 0 {
+		t.Errorf("expect no invocation in flight, got %d", left)
+	}
+}
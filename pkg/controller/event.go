@@ -0,0 +1,160 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/baidu/easyfaas/pkg/api"
+	"github.com/baidu/easyfaas/pkg/controller/eventqueue"
+	"github.com/baidu/easyfaas/pkg/util/logs"
+)
+
+func (controller *Controller) initEventQueue(o *eventqueue.Options) (err error) {
+	if !o.Enable {
+		return nil
+	}
+	controller.eventQueue, err = eventqueue.NewQueue(o, controller.handleEvent)
+	if err != nil {
+		return err
+	}
+	return controller.eventQueue.Start()
+}
+
+// enqueueEvent persists the event invocation; it runs in background when the queue is disabled
+func (controller *Controller) enqueueEvent(ctx *InvokeContext) error {
+	controller.recordPending(ctx)
+	if controller.eventQueue == nil {
+		controller.drain.track()
+		go func() {
+			defer controller.drain.done()
+			controller.recordRunning(ctx.RequestID, 1)
+			controller.Do(ctx)
+			controller.finishEvent(ctx, eventResult(ctx), 1, false)
+		}()
+		return nil
+	}
+	ev := &eventqueue.Event{
+		ID:                ctx.RequestID,
+		ExternalRequestID: ctx.ExternalRequestID,
+		AccountID:         ctx.AccountID,
+		FunctionName:      ctx.FunctionName,
+		FunctionBRN:       ctx.FunctionBRN,
+		Qualifier:         ctx.Qualifier,
+		CanaryKey:         ctx.canaryKey,
+		TriggerType:       ctx.TriggerType,
+		Headers:           eventHeaders(ctx.Request.Headers),
+		Body:              ctx.Request.Body,
+	}
+	if err := controller.eventQueue.Push(ev); err != nil {
+		controller.forgetResult(ctx.RequestID)
+		return err
+	}
+	return nil
+}
+
+// eventHeaders copies the request headers to persist, without the credentials of the caller
+func eventHeaders(headers map[string]string) map[string]string {
+	copied := make(map[string]string, len(headers))
+	for key, value := range headers {
+		if strings.EqualFold(key, api.HeaderAuthorization) {
+			continue
+		}
+		copied[key] = value
+	}
+	return copied
+}
+
+// handleEvent runs one attempt of a queued event invocation
+func (controller *Controller) handleEvent(ev *eventqueue.Event) *eventqueue.Result {
+	// no new attempts once draining, the event is attempted after restart
+	if err := controller.drain.admit(); err != nil {
+		return &eventqueue.Result{Outcome: eventqueue.OutcomeDeferred}
+	}
+	defer controller.drain.done()
+
+	headers := ev.Headers
+	if headers == nil {
+		headers = make(map[string]string)
+	}
+	ctx := InvokeContext{
+		RunOptions:        controller.runOptions,
+		ExternalRequestID: ev.ExternalRequestID,
+		RequestID:         ev.ID,
+		AccountID:         ev.AccountID,
+		CallerUser: &api.User{
+			ID: ev.AccountID,
+		},
+		Clients:  controller.NewClients(ClientModeInside),
+		Request:  api.NewInvokeProxyRequest(headers, ev.Body, nil),
+		Response: api.NewInvokeProxyResponseWithRequestID(ev.ExternalRequestID),
+		Logger: logs.NewLogger().WithField("request_id", ev.ID).
+			WithField("external_request_id", ev.ExternalRequestID).WithField("invoke-type", api.InvokeTypeEvent).
+			WithField("attempt", strconv.Itoa(ev.Attempts)),
+		LogType:      api.LogTypeNone,
+		InvokeType:   api.InvokeTypeEvent,
+		TriggerType:  ev.TriggerType,
+		FunctionName: ev.FunctionName,
+		FunctionBRN:  ev.FunctionBRN,
+		Qualifier:    ev.Qualifier,
+		canaryKey:    ev.CanaryKey,
+	}
+	if controller.runOptions.RecommendedOptions.Features.EnableMetrics {
+		ctx.Metrics = NewInvokeMetrics(ev.ID)
+	}
+
+	startTime := time.Now()
+	defer ctx.Logger.TimeTrack(startTime, "Event invocation total time")
+
+	controller.recordRunning(ev.ID, ev.Attempts)
+	controller.Do(&ctx)
+	res := eventResult(&ctx)
+	controller.finishEvent(&ctx, res, ev.Attempts, controller.eventQueue != nil && controller.eventQueue.WillRetry(ev, res))
+	return res
+}
+
+// eventResult decides whether a failed event attempt should be retried:
+// function errors, throttling and service errors are retried, other client errors are not
+func eventResult(ctx *InvokeContext) *eventqueue.Result {
+	status := ctx.Response.StatusCode
+	res := &eventqueue.Result{
+		Outcome:    eventqueue.OutcomeSucceeded,
+		StatusCode: status,
+	}
+	if ctx.Output != nil && ctx.Output.Output != nil && ctx.Output.Output.FuncError != "" {
+		res.Outcome = eventqueue.OutcomeRetryable
+		res.ErrorType = ctx.Output.Output.FuncError
+		res.ErrorMessage = ctx.Output.Output.ErrorInfo
+		return res
+	}
+	if errType, ok := ctx.Response.Headers[api.XBceFunctionError]; ok {
+		res.ErrorType = errType
+	}
+	if status < http.StatusBadRequest && res.ErrorType == "" {
+		return res
+	}
+	res.ErrorMessage = string(ctx.Response.Body)
+	if status >= http.StatusInternalServerError || status == http.StatusTooManyRequests {
+		res.Outcome = eventqueue.OutcomeRetryable
+	} else {
+		res.Outcome = eventqueue.OutcomeFailed
+	}
+	return res
+}
@@ -0,0 +1,132 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"net/http"
+	"testing"
+
+	routing "github.com/qiangxue/fasthttp-routing"
+	"github.com/valyala/fasthttp"
+
+	"github.com/baidu/easyfaas/pkg/api"
+	"github.com/baidu/easyfaas/pkg/controller/eventqueue"
+	"github.com/baidu/easyfaas/pkg/controller/resultstore"
+	"github.com/baidu/easyfaas/pkg/controller/rtctrl"
+)
+
+func TestEventResult(t *testing.T) {
+	cases := []struct {
+		name    string
+		status  int
+		header  string
+		output  *rtctrl.InvocationResponse
+		outcome eventqueue.Outcome
+	}{
+		{name: "succeeded", status: http.StatusOK, output: &rtctrl.InvocationResponse{}, outcome: eventqueue.OutcomeSucceeded},
+		{name: "function error", status: http.StatusOK, output: &rtctrl.InvocationResponse{FuncError: "Unhandled"}, outcome: eventqueue.OutcomeRetryable},
+		{name: "throttled", status: http.StatusTooManyRequests, header: "Unhandled", outcome: eventqueue.OutcomeRetryable},
+		{name: "service error", status: http.StatusInternalServerError, header: "Unhandled", outcome: eventqueue.OutcomeRetryable},
+		{name: "not found", status: http.StatusNotFound, header: "Unhandled", outcome: eventqueue.OutcomeFailed},
+	}
+	for _, c := range cases {
+		ctx := &InvokeContext{Response: api.NewInvokeProxyResponseWithRequestID("req")}
+		ctx.Response.SetStatusCode(c.status)
+		if c.header != "" {
+			ctx.Response.SetHeader(api.XBceFunctionError, c.header)
+		}
+		if c.output != nil {
+			ctx.Output = &rtctrl.InvocationOutput{Output: c.output}
+		}
+		if res := eventResult(ctx); res.Outcome != c.outcome {
+			t.Errorf("%s: expect outcome %s, got %s", c.name, c.outcome, res.Outcome)
+		}
+	}
+}
+
+func TestRecordEventResult(t *testing.T) {
+	controller, _ := newTestController(0, &fakeDataStorer{})
+	controller.initResultStore(resultstore.NewOptions(), &eventqueue.Options{})
+	ctx := &InvokeContext{
+		RequestID:   "invocation-id",
+		AccountID:   "8f6e",
+		FunctionBRN: "brn:function",
+		Response:    api.NewInvokeProxyResponseWithRequestID("req"),
+	}
+
+	controller.recordPending(ctx)
+	controller.recordRunning(ctx.RequestID, 1)
+	if r, _ := controller.results.Get(ctx.RequestID); r.Status != resultstore.StatusRunning || r.Function != "brn:function" {
+		t.Errorf("unexpected running record %+v", r)
+	}
+
+	ctx.Response.SetStatusCode(http.StatusInternalServerError)
+	controller.recordResult(ctx, &eventqueue.Result{Outcome: eventqueue.OutcomeRetryable, ErrorType: "Unhandled"}, true)
+	if r, _ := controller.results.Get(ctx.RequestID); r.Status != resultstore.StatusPending || r.ErrorType != "Unhandled" {
+		t.Errorf("expect a retried invocation pending, got %+v", r)
+	}
+
+	controller.recordRunning(ctx.RequestID, 2)
+	ctx.Output = &rtctrl.InvocationOutput{Statistic: &rtctrl.InvocationStatistic{
+		Statistic: &rtctrl.StatisticInfo{Duration: 12, MemoryUsed: 1024},
+	}}
+	ctx.Response.Body = []byte("result")
+	controller.recordResult(ctx, &eventqueue.Result{Outcome: eventqueue.OutcomeSucceeded, StatusCode: http.StatusOK}, false)
+	r, _ := controller.results.Get(ctx.RequestID)
+	if r.Status != resultstore.StatusSucceeded || r.Attempts != 2 || r.Payload != "result" ||
+		r.DurationMS != 12 || r.MemoryUsedBytes != 1024 {
+		t.Errorf("unexpected succeeded record %+v", r)
+	}
+}
+
+func TestEventHeaders(t *testing.T) {
+	headers := map[string]string{
+		api.HeaderAuthorization: "bce-auth-v1/secret",
+		"authorization":         "bce-auth-v1/secret",
+		api.HeaderXRequestID:    "req",
+	}
+	persisted := eventHeaders(headers)
+	if len(persisted) != 1 || persisted[api.HeaderXRequestID] != "req" {
+		t.Errorf("credentials should not be persisted, got %v", persisted)
+	}
+	if len(headers) != 3 {
+		t.Errorf("request headers should be kept, got %v", headers)
+	}
+}
+
+func TestGetInvocationOfOtherAccount(t *testing.T) {
+	controller, _ := newTestController(0, &fakeDataStorer{})
+	controller.initResultStore(resultstore.NewOptions(), &eventqueue.Options{})
+	controller.recordPending(&InvokeContext{RequestID: "invocation-id", AccountID: "8f6e", FunctionBRN: "brn:function"})
+
+	router := routing.New()
+	router.Get("/v1/invocations/<requestID>", controller.GetInvocationHandler)
+	get := func(accountID string) int {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.Header.SetMethod("GET")
+		ctx.Request.SetRequestURI("/v1/invocations/invocation-id")
+		ctx.Request.Header.Set(api.HeaderXAccountID, accountID)
+		router.HandleRequest(ctx)
+		return ctx.Response.StatusCode()
+	}
+	if status := get("8f6e"); status != http.StatusOK {
+		t.Errorf("expect the invocation found by its account, got %d", status)
+	}
+	if status := get("other"); status != http.StatusNotFound {
+		t.Errorf("expect the invocation of another account not found, got %d", status)
+	}
+}
@@ -0,0 +1,118 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package eventqueue
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DeadLetterSink receives events which could not be delivered
+type DeadLetterSink interface {
+	Send(letter *DeadLetter) error
+}
+
+// NewDeadLetterSink creates the sink configured by options
+func NewDeadLetterSink(o *Options) (DeadLetterSink, error) {
+	switch o.DeadLetterSink {
+	case DeadLetterSinkTypeNone, "":
+		return &discardSink{}, nil
+	case DeadLetterSinkTypeFile:
+		return NewFileSink(o.DeadLetterFile)
+	case DeadLetterSinkTypeHTTP:
+		if o.DeadLetterWebhook == "" {
+			return nil, fmt.Errorf("dead letter webhook address is empty")
+		}
+		return NewWebhookSink(o.DeadLetterWebhook, o.DeadLetterWebhookTimeout), nil
+	default:
+		return nil, fmt.Errorf("unknown dead letter sink %s", o.DeadLetterSink)
+	}
+}
+
+type discardSink struct{}
+
+func (s *discardSink) Send(letter *DeadLetter) error {
+	return nil
+}
+
+// FileSink appends dead letters to a local file, one json object per line
+type FileSink struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewFileSink(path string) (*FileSink, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	return &FileSink{path: path}, nil
+}
+
+func (s *FileSink) Send(letter *DeadLetter) error {
+	data, err := json.Marshal(letter)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(data)
+	return err
+}
+
+// WebhookSink posts dead letters to a http endpoint
+type WebhookSink struct {
+	address string
+	client  *http.Client
+}
+
+func NewWebhookSink(address string, timeout time.Duration) *WebhookSink {
+	return &WebhookSink{
+		address: address,
+		client: &http.Client{
+			Timeout: timeout,
+		},
+	}
+}
+
+func (s *WebhookSink) Send(letter *DeadLetter) error {
+	data, err := json.Marshal(letter)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Post(s.address, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("dead letter webhook responded %d", resp.StatusCode)
+	}
+	return nil
+}
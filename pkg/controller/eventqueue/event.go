@@ -0,0 +1,73 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package eventqueue
+
+import (
+	"time"
+)
+
+// Event is an async invocation persisted until it succeeds or is dead lettered;
+// credentials of the caller are never kept, attempts run with the service credentials
+type Event struct {
+	ID                string            `json:"id"`
+	ExternalRequestID string            `json:"externalRequestId"`
+	AccountID         string            `json:"accountId"`
+	FunctionName      string            `json:"functionName,omitempty"`
+	FunctionBRN       string            `json:"functionBrn,omitempty"`
+	Qualifier         string            `json:"qualifier,omitempty"`
+	CanaryKey         string            `json:"canaryKey,omitempty"`
+	TriggerType       string            `json:"triggerType"`
+	Headers           map[string]string `json:"headers"`
+	Body              []byte            `json:"body"`
+	Attempts          int               `json:"attempts"`
+	EnqueueTime       time.Time         `json:"enqueueTime"`
+	NextAttemptTime   time.Time         `json:"nextAttemptTime"`
+	LastResult        *Result           `json:"lastResult,omitempty"`
+}
+
+type Outcome string
+
+const (
+	OutcomeSucceeded Outcome = "succeeded"
+	// OutcomeRetryable: the attempt failed and may succeed later
+	OutcomeRetryable Outcome = "retryable"
+	// OutcomeFailed: the attempt failed and retrying makes no sense
+	OutcomeFailed Outcome = "failed"
+	// OutcomeDeferred: the event is not attempted, e.g. the controller is draining;
+	// it stays on disk for the next start
+	OutcomeDeferred Outcome = "deferred"
+)
+
+// Result of a single event attempt
+type Result struct {
+	Outcome      Outcome `json:"outcome"`
+	StatusCode   int     `json:"statusCode"`
+	ErrorType    string  `json:"errorType,omitempty"`
+	ErrorMessage string  `json:"errorMessage,omitempty"`
+}
+
+// DeadLetter is the record sent to the dead letter sink
+type DeadLetter struct {
+	Event      *Event    `json:"event"`
+	Reason     string    `json:"reason"`
+	FailedTime time.Time `json:"failedTime"`
+}
+
+const (
+	ReasonRetriesExhausted = "RetriesExhausted"
+	ReasonUnretryable      = "UnretryableError"
+)
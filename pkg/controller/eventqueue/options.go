@@ -0,0 +1,97 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package eventqueue
+package eventqueue
+
+import (
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+const (
+	defaultStoreDir        = "/tmp/eventqueue"
+	defaultDeadLetterFile  = "/tmp/eventqueue/deadletter.log"
+	defaultWorkers         = 16
+	defaultMaxRetries      = 2
+	defaultRetryBaseDelay  = time.Second
+	defaultRetryMaxDelay   = 5 * time.Minute
+	defaultWebhookTimeout  = 5 * time.Second
+	DeadLetterSinkTypeNone = "none"
+	DeadLetterSinkTypeFile = "file"
+	DeadLetterSinkTypeHTTP = "webhook"
+)
+
+type Options struct {
+	// Event queue switch, event invocations run directly in background when disabled
+	Enable bool
+
+	// Directory to persist pending events
+	StoreDir string
+
+	// Concurrent workers consuming the queue
+	Workers int
+
+	// Retries after the first failed attempt
+	MaxRetries int
+
+	// Backoff of the first retry, doubled for every following retry
+	RetryBaseDelay time.Duration
+
+	// Upper bound of the retry backoff
+	RetryMaxDelay time.Duration
+
+	// Dead letter sink type (eg. none,file,webhook)
+	DeadLetterSink string
+
+	// File to append dead letters to when sink type is file
+	DeadLetterFile string
+
+	// Address to post dead letters to when sink type is webhook
+	DeadLetterWebhook string
+
+	// Timeout of the webhook request
+	DeadLetterWebhookTimeout time.Duration
+}
+
+func NewOptions() *Options {
+	return &Options{
+		Enable:                   true,
+		StoreDir:                 defaultStoreDir,
+		Workers:                  defaultWorkers,
+		MaxRetries:               defaultMaxRetries,
+		RetryBaseDelay:           defaultRetryBaseDelay,
+		RetryMaxDelay:            defaultRetryMaxDelay,
+		DeadLetterSink:           DeadLetterSinkTypeFile,
+		DeadLetterFile:           defaultDeadLetterFile,
+		DeadLetterWebhookTimeout: defaultWebhookTimeout,
+	}
+}
+
+func (s *Options) AddFlags(fs *pflag.FlagSet) {
+	fs.BoolVar(&s.Enable, "event-queue-enable", s.Enable, "whether to persist event invocations in the event queue")
+	fs.StringVar(&s.StoreDir, "event-queue-dir", s.StoreDir, "event queue storage path")
+	fs.IntVar(&s.Workers, "event-queue-workers", s.Workers, "event queue worker number")
+	fs.IntVar(&s.MaxRetries, "event-max-retries", s.MaxRetries, "max retries of a failed event invocation")
+	fs.DurationVar(&s.RetryBaseDelay, "event-retry-base-delay", s.RetryBaseDelay, "backoff of the first event retry")
+	fs.DurationVar(&s.RetryMaxDelay, "event-retry-max-delay", s.RetryMaxDelay, "max backoff of event retries")
+	fs.StringVar(&s.DeadLetterSink, "event-deadletter-sink", s.DeadLetterSink, "dead letter sink type (eg. none,file,webhook)")
+	fs.StringVar(&s.DeadLetterFile, "event-deadletter-file", s.DeadLetterFile, "dead letter file path")
+	fs.StringVar(&s.DeadLetterWebhook, "event-deadletter-webhook", s.DeadLetterWebhook, "dead letter webhook address")
+	fs.DurationVar(&s.DeadLetterWebhookTimeout, "event-deadletter-webhook-timeout",
+		s.DeadLetterWebhookTimeout, "dead letter webhook request timeout")
+}
@@ -0,0 +1,279 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package eventqueue
+
+import (
+	"container/heap"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/baidu/easyfaas/pkg/util/logs"
+)
+
+// Handler runs a single attempt of the event
+type Handler func(ev *Event) *Result
+
+// Queue persists event invocations and runs them with retries
+type Queue struct {
+	options *Options
+	store   *diskStore
+	sink    DeadLetterSink
+	handler Handler
+
+	workers int
+	// ids of the events left by the last process
+	reloaded map[string]struct{}
+
+	// events waiting for their next attempt, ordered by the attempt time
+	lock    sync.Mutex
+	delayed eventHeap
+	wake    chan struct{}
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+func NewQueue(o *Options, handler Handler) (*Queue, error) {
+	sink, err := NewDeadLetterSink(o)
+	if err != nil {
+		return nil, err
+	}
+	return NewQueueWithSink(o, handler, sink)
+}
+
+func NewQueueWithSink(o *Options, handler Handler, sink DeadLetterSink) (*Queue, error) {
+	if handler == nil {
+		return nil, fmt.Errorf("event handler is nil")
+	}
+	store, err := newDiskStore(o.StoreDir)
+	if err != nil {
+		return nil, err
+	}
+	workers := o.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+	return &Queue{
+		options: o,
+		store:   store,
+		sink:    sink,
+		handler: handler,
+		workers: workers,
+		wake:    make(chan struct{}, workers),
+		stopCh:  make(chan struct{}),
+	}, nil
+}
+
+// Start reloads events left by the last process and starts the workers
+func (q *Queue) Start() error {
+	events, err := q.store.Load()
+	if err != nil {
+		return err
+	}
+	logs.Infof("reload %d pending events from %s", len(events), q.options.StoreDir)
+	q.reloaded = make(map[string]struct{}, len(events))
+	for _, ev := range events {
+		q.reloaded[ev.ID] = struct{}{}
+		q.schedule(ev)
+	}
+	for i := 0; i < q.workers; i++ {
+		q.wg.Add(1)
+		go q.work()
+	}
+	return nil
+}
+
+// Reloaded returns the ids of the events left by the last process, reloaded by Start
+func (q *Queue) Reloaded() map[string]struct{} {
+	return q.reloaded
+}
+
+// Stop waits for running attempts; pending events stay on disk
+func (q *Queue) Stop() {
+	close(q.stopCh)
+	q.wg.Wait()
+}
+
+// Push persists the event and schedules the first attempt
+func (q *Queue) Push(ev *Event) error {
+	if ev.ID == "" {
+		return fmt.Errorf("event id is empty")
+	}
+	now := time.Now()
+	ev.EnqueueTime = now
+	ev.NextAttemptTime = now
+	if err := q.store.Save(ev); err != nil {
+		return err
+	}
+	q.schedule(ev)
+	return nil
+}
+
+// schedule puts the event into the delay heap and wakes up a worker
+func (q *Queue) schedule(ev *Event) {
+	q.lock.Lock()
+	heap.Push(&q.delayed, ev)
+	q.lock.Unlock()
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// next blocks until an event is due, it returns nil once the queue is stopped
+func (q *Queue) next() *Event {
+	for {
+		// stopping wins over due events
+		select {
+		case <-q.stopCh:
+			return nil
+		default:
+		}
+
+		var timer *time.Timer
+		var timeout <-chan time.Time
+		q.lock.Lock()
+		if q.delayed.Len() > 0 {
+			wait := time.Until(q.delayed[0].NextAttemptTime)
+			if wait <= 0 {
+				ev := heap.Pop(&q.delayed).(*Event)
+				q.lock.Unlock()
+				return ev
+			}
+			timer = time.NewTimer(wait)
+			timeout = timer.C
+		}
+		q.lock.Unlock()
+
+		select {
+		case <-q.stopCh:
+		case <-q.wake:
+		case <-timeout:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+func (q *Queue) work() {
+	defer q.wg.Done()
+	for {
+		ev := q.next()
+		if ev == nil {
+			return
+		}
+		q.process(ev)
+	}
+}
+
+func (q *Queue) process(ev *Event) {
+	ev.Attempts++
+	res := q.handler(ev)
+	if res == nil {
+		res = &Result{Outcome: OutcomeSucceeded}
+	}
+	if res.Outcome == OutcomeDeferred {
+		ev.Attempts--
+		logs.Infof("event %s deferred, it stays on disk", ev.ID)
+		return
+	}
+	ev.LastResult = res
+
+	switch res.Outcome {
+	case OutcomeSucceeded:
+		q.remove(ev)
+	case OutcomeFailed:
+		q.deadLetter(ev, ReasonUnretryable)
+	default:
+		if !q.WillRetry(ev, res) {
+			q.deadLetter(ev, ReasonRetriesExhausted)
+			return
+		}
+		delay := q.backoff(ev.Attempts)
+		ev.NextAttemptTime = time.Now().Add(delay)
+		if err := q.store.Save(ev); err != nil {
+			logs.Errorf("save event %s failed: %s", ev.ID, err)
+		}
+		logs.Infof("event %s attempt %d failed (%s), retry in %s", ev.ID, ev.Attempts, res.ErrorType, delay)
+		q.schedule(ev)
+	}
+}
+
+// WillRetry tells whether the event is attempted again after the result
+func (q *Queue) WillRetry(ev *Event, res *Result) bool {
+	return res.Outcome == OutcomeRetryable && ev.Attempts <= q.options.MaxRetries
+}
+
+// backoff doubles the base delay for every attempt
+func (q *Queue) backoff(attempts int) time.Duration {
+	delay := q.options.RetryBaseDelay
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= q.options.RetryMaxDelay || delay <= 0 {
+			return q.options.RetryMaxDelay
+		}
+	}
+	if delay > q.options.RetryMaxDelay {
+		return q.options.RetryMaxDelay
+	}
+	return delay
+}
+
+func (q *Queue) deadLetter(ev *Event, reason string) {
+	letter := &DeadLetter{
+		Event:      ev,
+		Reason:     reason,
+		FailedTime: time.Now(),
+	}
+	if err := q.sink.Send(letter); err != nil {
+		logs.Errorf("send event %s to dead letter sink failed: %s", ev.ID, err)
+	}
+	logs.Warnf("event %s dead lettered after %d attempts: %s", ev.ID, ev.Attempts, reason)
+	q.remove(ev)
+}
+
+func (q *Queue) remove(ev *Event) {
+	if err := q.store.Delete(ev.ID); err != nil {
+		logs.Errorf("delete event %s failed: %s", ev.ID, err)
+	}
+}
+
+// eventHeap is a min heap of events by the next attempt time
+type eventHeap []*Event
+
+func (h eventHeap) Len() int { return len(h) }
+
+func (h eventHeap) Less(i, j int) bool {
+	return h[i].NextAttemptTime.Before(h[j].NextAttemptTime)
+}
+
+func (h eventHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *eventHeap) Push(x interface{}) {
+	*h = append(*h, x.(*Event))
+}
+
+func (h *eventHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	ev := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return ev
+}
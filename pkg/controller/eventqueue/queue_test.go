@@ -0,0 +1,259 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package eventqueue
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type memorySink struct {
+	mu      sync.Mutex
+	letters []*DeadLetter
+}
+
+func (s *memorySink) Send(letter *DeadLetter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.letters = append(s.letters, letter)
+	return nil
+}
+
+func (s *memorySink) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.letters)
+}
+
+func testOptions(t *testing.T) *Options {
+	dir, err := ioutil.TempDir("", "eventqueue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := NewOptions()
+	o.StoreDir = dir
+	o.Workers = 2
+	o.RetryBaseDelay = time.Millisecond
+	o.RetryMaxDelay = 4 * time.Millisecond
+	return o
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	deadline := time.Now().Add(3 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met before deadline")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func pendingFiles(dir string) int {
+	files, _ := filepath.Glob(filepath.Join(dir, "*"+eventFileSuffix))
+	return len(files)
+}
+
+func TestDiskStore(t *testing.T) {
+	o := testOptions(t)
+	defer os.RemoveAll(o.StoreDir)
+
+	store, err := newDiskStore(o.StoreDir)
+	assert.Nil(t, err)
+	ev := &Event{ID: "a", FunctionBRN: "brn", Body: []byte(`{"k":"v"}`), Headers: map[string]string{"h": "v"}}
+	assert.Nil(t, store.Save(ev))
+	ioutil.WriteFile(filepath.Join(o.StoreDir, "b"+eventFileSuffix+tmpFileSuffix), []byte("{"), 0600)
+	ioutil.WriteFile(filepath.Join(o.StoreDir, "c"+eventFileSuffix), []byte("{"), 0600)
+
+	events, err := store.Load()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(events))
+	assert.Equal(t, ev.Body, events[0].Body)
+	assert.Equal(t, "v", events[0].Headers["h"])
+
+	assert.Nil(t, store.Delete("a"))
+	assert.Nil(t, store.Delete("a"))
+}
+
+func TestQueueRetryAndDeadLetter(t *testing.T) {
+	o := testOptions(t)
+	defer os.RemoveAll(o.StoreDir)
+
+	var mu sync.Mutex
+	attempts := map[string]int{}
+	handler := func(ev *Event) *Result {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts[ev.ID]++
+		switch ev.ID {
+		case "flaky":
+			if attempts[ev.ID] < 2 {
+				return &Result{Outcome: OutcomeRetryable, StatusCode: 500}
+			}
+			return &Result{Outcome: OutcomeSucceeded, StatusCode: 200}
+		case "broken":
+			return &Result{Outcome: OutcomeRetryable, StatusCode: 200, ErrorType: "Unhandled"}
+		default:
+			return &Result{Outcome: OutcomeFailed, StatusCode: 404}
+		}
+	}
+	sink := &memorySink{}
+	q, err := NewQueueWithSink(o, handler, sink)
+	assert.Nil(t, err)
+	assert.Nil(t, q.Start())
+	defer q.Stop()
+
+	for _, id := range []string{"flaky", "broken", "missing"} {
+		assert.Nil(t, q.Push(&Event{ID: id}))
+	}
+	waitFor(t, func() bool { return sink.count() == 2 && pendingFiles(o.StoreDir) == 0 })
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 2, attempts["flaky"])
+	assert.Equal(t, o.MaxRetries+1, attempts["broken"])
+	assert.Equal(t, 1, attempts["missing"])
+	reasons := map[string]string{}
+	for _, l := range sink.letters {
+		reasons[l.Event.ID] = l.Reason
+	}
+	assert.Equal(t, ReasonRetriesExhausted, reasons["broken"])
+	assert.Equal(t, ReasonUnretryable, reasons["missing"])
+}
+
+func TestQueueReloadPending(t *testing.T) {
+	o := testOptions(t)
+	defer os.RemoveAll(o.StoreDir)
+
+	store, _ := newDiskStore(o.StoreDir)
+	store.Save(&Event{ID: "left", Attempts: 1})
+
+	done := make(chan *Event, 1)
+	q, err := NewQueueWithSink(o, func(ev *Event) *Result {
+		done <- ev
+		return nil
+	}, &memorySink{})
+	assert.Nil(t, err)
+	assert.Nil(t, q.Start())
+	defer q.Stop()
+
+	select {
+	case ev := <-done:
+		assert.Equal(t, "left", ev.ID)
+		assert.Equal(t, 2, ev.Attempts)
+	case <-time.After(3 * time.Second):
+		t.Fatal("pending event not reloaded")
+	}
+	waitFor(t, func() bool { return pendingFiles(o.StoreDir) == 0 })
+}
+
+func TestQueueDelayedOrder(t *testing.T) {
+	o := testOptions(t)
+	o.Workers = 1
+	defer os.RemoveAll(o.StoreDir)
+
+	now := time.Now()
+	store, _ := newDiskStore(o.StoreDir)
+	store.Save(&Event{ID: "later", NextAttemptTime: now.Add(200 * time.Millisecond)})
+	store.Save(&Event{ID: "sooner", NextAttemptTime: now.Add(50 * time.Millisecond)})
+	store.Save(&Event{ID: "due", NextAttemptTime: now.Add(-time.Second)})
+
+	done := make(chan string, 3)
+	q, err := NewQueueWithSink(o, func(ev *Event) *Result {
+		done <- ev.ID
+		return nil
+	}, &memorySink{})
+	assert.Nil(t, err)
+	assert.Nil(t, q.Start())
+	defer q.Stop()
+
+	// delayed events wait in the heap, no timer per event
+	for _, expect := range []string{"due", "sooner", "later"} {
+		select {
+		case id := <-done:
+			assert.Equal(t, expect, id)
+		case <-time.After(3 * time.Second):
+			t.Fatalf("event %s not attempted", expect)
+		}
+	}
+	assert.True(t, time.Since(now) >= 200*time.Millisecond)
+}
+
+func TestQueueDeferred(t *testing.T) {
+	o := testOptions(t)
+	defer os.RemoveAll(o.StoreDir)
+
+	handled := make(chan *Event, 1)
+	q, err := NewQueueWithSink(o, func(ev *Event) *Result {
+		handled <- ev
+		return &Result{Outcome: OutcomeDeferred}
+	}, &memorySink{})
+	assert.Nil(t, err)
+	assert.Nil(t, q.Start())
+	assert.Nil(t, q.Push(&Event{ID: "deferred"}))
+	select {
+	case <-handled:
+	case <-time.After(3 * time.Second):
+		t.Fatal("event not handled")
+	}
+	q.Stop()
+
+	// the deferred event stays on disk without using up an attempt
+	events, err := q.store.Load()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(events))
+	assert.Equal(t, 0, events[0].Attempts)
+}
+
+func TestBackoff(t *testing.T) {
+	q := &Queue{options: &Options{RetryBaseDelay: time.Second, RetryMaxDelay: 5 * time.Second}}
+	assert.Equal(t, time.Second, q.backoff(1))
+	assert.Equal(t, 2*time.Second, q.backoff(2))
+	assert.Equal(t, 4*time.Second, q.backoff(3))
+	assert.Equal(t, 5*time.Second, q.backoff(4))
+	assert.Equal(t, 5*time.Second, q.backoff(100))
+}
+
+func TestFileSink(t *testing.T) {
+	o := testOptions(t)
+	defer os.RemoveAll(o.StoreDir)
+
+	sink, err := NewFileSink(filepath.Join(o.StoreDir, "dead", "letters.log"))
+	assert.Nil(t, err)
+	assert.Nil(t, sink.Send(&DeadLetter{Event: &Event{ID: "a"}, Reason: ReasonUnretryable}))
+	assert.Nil(t, sink.Send(&DeadLetter{Event: &Event{ID: "b"}, Reason: ReasonUnretryable}))
+	data, err := ioutil.ReadFile(sink.path)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(splitLines(data)))
+}
+
+func splitLines(data []byte) [][]byte {
+	lines := make([][]byte, 0)
+	start := 0
+	for i, b := range data {
+		if b == '\n' {
+			lines = append(lines, data[start:i])
+			start = i + 1
+		}
+	}
+	return lines
+}
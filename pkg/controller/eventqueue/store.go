@@ -0,0 +1,114 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package eventqueue
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/baidu/easyfaas/pkg/util/logs"
+)
+
+const (
+	eventFileSuffix = ".event"
+	tmpFileSuffix   = ".tmp"
+)
+
+// diskStore keeps one file per pending event
+type diskStore struct {
+	dir string
+}
+
+func newDiskStore(dir string) (*diskStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &diskStore{dir: dir}, nil
+}
+
+func (s *diskStore) path(id string) string {
+	return filepath.Join(s.dir, id+eventFileSuffix)
+}
+
+// Save writes the event to a temporary file and renames it, so a crash never leaves a partial event
+func (s *diskStore) Save(ev *Event) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	tmp := s.path(ev.ID) + tmpFileSuffix
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(data); err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, s.path(ev.ID))
+}
+
+func (s *diskStore) Delete(id string) error {
+	err := os.Remove(s.path(id))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// Load returns all pending events; broken files are skipped
+func (s *diskStore) Load() ([]*Event, error) {
+	files, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	events := make([]*Event, 0, len(files))
+	for _, f := range files {
+		name := f.Name()
+		if f.IsDir() {
+			continue
+		}
+		if strings.HasSuffix(name, tmpFileSuffix) {
+			os.Remove(filepath.Join(s.dir, name))
+			continue
+		}
+		if !strings.HasSuffix(name, eventFileSuffix) {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(s.dir, name))
+		if err != nil {
+			logs.Warnf("read event file %s failed: %s", name, err)
+			continue
+		}
+		ev := &Event{}
+		if err := json.Unmarshal(data, ev); err != nil {
+			logs.Warnf("unmarshal event file %s failed: %s", name, err)
+			continue
+		}
+		events = append(events, ev)
+	}
+	return events, nil
+}
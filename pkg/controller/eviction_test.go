@@ -0,0 +1,75 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/baidu/easyfaas/pkg/controller/rtctrl"
+	"github.com/baidu/easyfaas/pkg/util/logs"
+)
+
+func TestEvictRuntime(t *testing.T) {
+	controller, funclet := newTestController(2, &fakeDataStorer{})
+	controller.runOptions.RuntimeConfigOptions.WaitRuntimeAliveTimeout = 1
+	funclet.onCoolDown = func(containerID string) {
+		// the runner reconnects before the cool down returns
+		rt, _ := controller.runtimeDispatcher.GetRuntime(containerID)
+		rt.SetState(rtctrl.RuntimeStateCold)
+	}
+
+	// fill the pool with idle warm runtimes of other functions
+	var oldest string
+	for i, commitID := range []string{"commit-old", "commit-recent"} {
+		input := &rtctrl.InvocationInput{Configuration: testFunction("", commitID).Configuration}
+		rt, _ := controller.runtimeDispatcher.OccupyColdRuntime(input)
+		rt.SetState(rtctrl.RuntimeStateWarm)
+		rt.Release()
+		rt.LastAccessTime = time.Now().Add(time.Duration(i-10) * time.Second)
+		if i == 0 {
+			oldest = rt.RuntimeID
+		}
+	}
+
+	ctx := &InvokeContext{
+		Input:  &rtctrl.InvocationInput{Configuration: testFunction("", "commit-new").Configuration},
+		Logger: logs.NewLogger(),
+	}
+	rt, _ := controller.evictRuntime(ctx)
+	if rt == nil {
+		t.Fatal("expect an evicted runtime")
+	}
+	if rt.RuntimeID != oldest || rt.CommitID != "commit-new" {
+		t.Errorf("least recently used runtime should be evicted, got %+v", rt)
+	}
+	if len(funclet.coolDowns) != 1 || funclet.coolDowns[0] != oldest {
+		t.Errorf("unexpected cool downs %v", funclet.coolDowns)
+	}
+
+	// busy runtimes are never evicted
+	busy := controller.runtimeDispatcher.FindWarmRuntime(&rtctrl.InvocationInput{Configuration: testFunction("", "commit-recent").Configuration})
+	if busy == nil {
+		t.Fatal("expect a warm runtime")
+	}
+	if rt, _ := controller.evictRuntime(ctx); rt != nil {
+		t.Errorf("busy runtime %s should not be evicted", rt.RuntimeID)
+	}
+	if _, _, err := controller.runtimeDispatcher.EvictRuntime(ctx.Input); err == nil {
+		t.Error("expect no runtime to evict")
+	}
+}
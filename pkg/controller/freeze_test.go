@@ -0,0 +1,117 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/baidu/easyfaas/pkg/controller/rtctrl"
+	"github.com/baidu/easyfaas/pkg/util/logs"
+)
+
+func TestFreezeAndThawRuntime(t *testing.T) {
+	controller, funclet := newTestController(1, &fakeDataStorer{})
+	controller.runOptions.RuntimeFreezeIdle = 1
+	controller.runtimeDispatcher.(*rtctrl.RuntimeManager).RuntimeFreezeIdle = 1
+	logger := logs.NewLogger()
+
+	input := &rtctrl.InvocationInput{Configuration: testFunction("", "commit-1").Configuration}
+	rt, _ := controller.runtimeDispatcher.OccupyColdRuntime(input)
+	rt.SetState(rtctrl.RuntimeStateWarm)
+
+	// busy runtimes are never frozen
+	controller.freeze(rt, logger)
+	if rt.State != rtctrl.RuntimeStateWarm {
+		t.Fatalf("busy runtime should not be frozen, got %s", rt.State)
+	}
+	rt.Release()
+	rt.LastAccessTime = time.Now().Add(-2 * time.Second)
+	controller.freeze(rt, logger)
+	if rt.State != rtctrl.RuntimeStateFrozen {
+		t.Fatalf("idle runtime should be frozen, got %s", rt.State)
+	}
+
+	// the request thaws the frozen runtime before dispatch
+	found := controller.runtimeDispatcher.FindWarmRuntime(input)
+	if found != rt || rt.State != rtctrl.RuntimeStateWarm || rt.Concurrency != 1 {
+		t.Fatalf("expect the thawed runtime, got %+v", found)
+	}
+	expect := []string{"FROZEN:" + rt.RuntimeID, "THAWED:" + rt.RuntimeID}
+	if fmt.Sprint(funclet.freezes) != fmt.Sprint(expect) {
+		t.Errorf("expect freezer calls %v, got %v", expect, funclet.freezes)
+	}
+	rt.Release()
+
+	// the runtime stays warm when funclet fails to freeze it
+	funclet.freezeErr = fmt.Errorf("freezer unsupported")
+	rt.LastAccessTime = time.Now().Add(-2 * time.Second)
+	controller.freeze(rt, logger)
+	if rt.State != rtctrl.RuntimeStateWarm {
+		t.Errorf("expect warm runtime, got %s", rt.State)
+	}
+}
+
+func TestCoolDownFrozenRuntime(t *testing.T) {
+	controller, _ := newTestController(1, &fakeDataStorer{})
+	controller.runtimeDispatcher.(*rtctrl.RuntimeManager).RuntimeFreezeIdle = 1
+
+	input := &rtctrl.InvocationInput{Configuration: testFunction("", "commit-1").Configuration}
+	rt, _ := controller.runtimeDispatcher.OccupyColdRuntime(input)
+	rt.SetState(rtctrl.RuntimeStateWarm)
+	rt.Release()
+	rt.LastAccessTime = time.Now().Add(-time.Hour)
+	if err := controller.runtimeDispatcher.FreezeRuntime(rt); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := controller.runtimeDispatcher.CoolDownRuntime(rt); err != nil {
+		t.Errorf("frozen runtime should cool down: %s", err)
+	}
+}
+
+func TestFreezeRacingThaw(t *testing.T) {
+	controller, _ := newTestController(1, &fakeDataStorer{})
+	controller.runtimeDispatcher.(*rtctrl.RuntimeManager).RuntimeFreezeIdle = 1
+	logger := logs.NewLogger()
+
+	input := &rtctrl.InvocationInput{Configuration: testFunction("", "commit-1").Configuration}
+	rt, _ := controller.runtimeDispatcher.OccupyColdRuntime(input)
+	rt.SetState(rtctrl.RuntimeStateWarm)
+	rt.Release()
+
+	// requests thaw the runtime while it is frozen, the state is only read under the runtime lock
+	for i := 0; i < 20; i++ {
+		rt.LastAccessTime = time.Now().Add(-2 * time.Second)
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			controller.freeze(rt, logger)
+		}()
+		found := controller.runtimeDispatcher.FindWarmRuntime(input)
+		wg.Wait()
+		if found == nil {
+			found = controller.runtimeDispatcher.FindWarmRuntime(input)
+		}
+		if found != rt {
+			t.Fatalf("expect the runtime found, got %+v", found)
+		}
+		rt.Release()
+	}
+}
@@ -28,6 +28,7 @@ type DataStorer interface {
 	GetFunction(input *api.GetFunctionInput) (*api.GetFunctionOutput, bool, error)
 	GetAlias(input *api.GetAliasInput) (*api.GetAliasOutput, bool, error)
 	GetRuntimeConfiguration(input *api.GetRuntimeConfigurationInput) (*api.RuntimeConfiguration, bool, error)
+	ListReservedConcurrency(input *api.ListReservedConcurrencyInput) (*api.ListReservedConcurrencyOutput, error)
 }
 
 // functionServerClient is used to get function and policy meta from apiserver
@@ -76,7 +77,7 @@ func (f *functionServerClient) GetAlias(input *api.GetAliasInput) (output *api.G
 		if ok {
 			output = v.(*api.GetAliasOutput)
 			hitCache = true
-			logs.Debugf("get function cache %s", input.FunctionBrn)
+			logs.Debugf("get alias cache %s", input.FunctionBrn)
 			return
 		}
 	}
@@ -108,3 +109,8 @@ func (f *functionServerClient) GetRuntimeConfiguration(input *api.GetRuntimeConf
 	f.cache.Set(CacheKey(CacheTypeRuntime, input.RuntimeName), conf, f.cache.CacheExpiration(CacheTypeRuntime))
 	return
 }
+
+// ListReservedConcurrency is never cached, it is only called to load the reservations up front
+func (f *functionServerClient) ListReservedConcurrency(input *api.ListReservedConcurrencyInput) (*api.ListReservedConcurrencyOutput, error) {
+	return f.rpcClient.ListReservedConcurrency(input)
+}
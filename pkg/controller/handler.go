@@ -35,12 +35,35 @@ import (
 )
 
 func (controller *Controller) HealthzHandler(c *routing.Context) error {
+	if controller.drain.isDraining() {
+		c.SetStatusCode(http.StatusServiceUnavailable)
+		c.WriteString("controller is draining")
+		return nil
+	}
 	c.SetStatusCode(http.StatusOK)
 	c.WriteString("hello controller")
 	return nil
 }
 
 func (controller *Controller) InvokeHandler(c *routing.Context) error {
+	ctx, err := controller.newInvokeContext(c)
+	if err != nil {
+		c.Response.SetStatusCode(http.StatusBadRequest)
+		bodyData, _ := json.Marshal(err)
+		c.Response.SetBody(bodyData)
+		return err
+	}
+
+	startTime := time.Now()
+	defer ctx.Logger.TimeTrack(startTime, "Invocation Total time")
+
+	controller.Invoke(c, ctx)
+
+	return nil
+}
+
+// newInvokeContext builds the invocation context from the invoke request
+func (controller *Controller) newInvokeContext(c *routing.Context) (InvokeContext, error) {
 	externalRequestID := string(c.Request.Header.Peek(api.HeaderXRequestID))
 	invokeType := strings.ToLower(string(c.Request.Header.Peek(api.HeaderInvokeType)))
 	triggerType := strings.ToLower(string(c.Request.Header.Peek(api.BceFaasTriggerKey)))
@@ -92,11 +115,7 @@ func (controller *Controller) InvokeHandler(c *routing.Context) error {
 	} else {
 		qualifier := string(c.QueryArgs().Peek("Qualifier"))
 		if qualifier == "" {
-			c.Response.SetStatusCode(http.StatusBadRequest)
-			err := innerErr.NewInvalidParameterValueException("missing query parameter Qualifier", nil)
-			bodyData, _ := json.Marshal(err)
-			c.Response.SetBody(bodyData)
-			return err
+			return ctx, innerErr.NewInvalidParameterValueException("missing query parameter Qualifier", nil)
 		}
 		ctx.FunctionName = funcName
 		ctx.Qualifier = qualifier
@@ -108,22 +127,35 @@ func (controller *Controller) InvokeHandler(c *routing.Context) error {
 		ctx.WithStreamMode = true
 	}
 
+	if query := controller.runOptions.CanaryStickyQuery; query != "" {
+		ctx.canaryKey = string(c.QueryArgs().Peek(query))
+	}
+
 	if controller.runOptions.RecommendedOptions.Features.EnableMetrics {
 		ctx.Metrics = NewInvokeMetrics(requestID)
 	}
-
-	startTime := time.Now()
-	defer ctx.Logger.TimeTrack(startTime, "Invocation Total time")
-
-	controller.Invoke(c, ctx)
-
-	return nil
+	return ctx, nil
 }
 
 func (controller *Controller) Invoke(c *routing.Context, ctx InvokeContext) {
+	if err := controller.drain.admit(); err != nil {
+		buildErrorResponse(&ctx, err)
+		makeHTTPResponse(c, &ctx)
+		return
+	}
+	defer controller.drain.done()
+
 	if ctx.InvokeType == api.InvokeTypeEvent {
-		go controller.Do(&ctx)
+		if err := controller.enqueueEvent(&ctx); err != nil {
+			ctx.Logger.Errorf("enqueue event failed: %s", err)
+			buildErrorResponse(&ctx, innerErr.NewServiceException("enqueue event failed", err))
+			makeHTTPResponse(c, &ctx)
+			return
+		}
+		body, _ := json.Marshal(&invocationAccepted{InvocationID: ctx.RequestID})
+		c.Response.Header.Set(api.HeaderInvocationID, ctx.RequestID)
 		c.SetStatusCode(http.StatusCreated)
+		c.Response.SetBody(body)
 	} else {
 		controller.Do(&ctx)
 		makeHTTPResponse(c, &ctx)
@@ -140,8 +172,17 @@ func (controller *Controller) ListRuntimesHandler(c *routing.Context) error {
 	return nil
 }
 
+// resourceStatistics: service resource with concurrency admission statistics
+type resourceStatistics struct {
+	*api.ServiceResource
+	Concurrency *ConcurrencyStatistics
+}
+
 func (controller *Controller) GetResourceHandler(c *routing.Context) error {
-	resource := controller.runtimeDispatcher.ResourceStatistics()
+	resource := resourceStatistics{
+		ServiceResource: controller.runtimeDispatcher.ResourceStatistics(),
+		Concurrency:     controller.concurrency.statistics(controller.idleWarmRuntimes),
+	}
 	body, err := json.Marshal(resource)
 	if err != nil {
 		return err
@@ -150,6 +191,111 @@ func (controller *Controller) GetResourceHandler(c *routing.Context) error {
 	return nil
 }
 
+func (controller *Controller) PutProvisionedConcurrencyHandler(c *routing.Context) error {
+	functionBRN := c.Param("functionName")
+	fb, err := parseProvisionedBrn(functionBRN)
+	if err != nil {
+		return writeErrorResponse(c, err)
+	}
+	input := &api.PutProvisionedConcurrencyInput{}
+	if err := json.Unmarshal(c.PostBody(), input); err != nil {
+		return writeErrorResponse(c, innerErr.NewInvalidRequestContentException(err.Error(), err))
+	}
+	callerID := string(c.Request.Header.Peek(api.HeaderXAccountID))
+	if err := controller.checkProvisioned(callerID, fb, input.ProvisionedConcurrentExecutions); err != nil {
+		return writeErrorResponse(c, err)
+	}
+	output := controller.provisioner.put(&provisionedTarget{
+		FunctionBRN: functionBRN,
+		Qualifier:   fb.Version,
+		AccountID:   fb.AccountID,
+		Target:      input.ProvisionedConcurrentExecutions,
+	})
+	body, err := json.Marshal(output)
+	if err != nil {
+		return err
+	}
+	c.SetStatusCode(http.StatusAccepted)
+	c.Response.SetBody(body)
+	return nil
+}
+
+func (controller *Controller) GetProvisionedConcurrencyHandler(c *routing.Context) error {
+	functionBRN := c.Param("functionName")
+	output, ok := controller.provisioner.get(functionBRN)
+	if !ok {
+		return writeErrorResponse(c, innerErr.NewResourceNotFoundException("no provisioned concurrency of "+functionBRN, nil))
+	}
+	body, err := json.Marshal(output)
+	if err != nil {
+		return err
+	}
+	c.Response.SetBody(body)
+	return nil
+}
+
+func (controller *Controller) DeleteProvisionedConcurrencyHandler(c *routing.Context) error {
+	functionBRN := c.Param("functionName")
+	if !controller.provisioner.delete(functionBRN) {
+		return writeErrorResponse(c, innerErr.NewResourceNotFoundException("no provisioned concurrency of "+functionBRN, nil))
+	}
+	c.SetStatusCode(http.StatusNoContent)
+	return nil
+}
+
+// ListPredictionsHandler shows the predictions of the pre-warming predictor
+func (controller *Controller) ListPredictionsHandler(c *routing.Context) error {
+	if controller.predictor == nil {
+		return writeErrorResponse(c, innerErr.NewResourceNotFoundException("predictor is disabled", nil))
+	}
+	body, err := json.Marshal(controller.predictor.Predictions())
+	if err != nil {
+		return err
+	}
+	c.Response.SetBody(body)
+	return nil
+}
+
+// GetInvocationHandler shows the status and the result of an event invocation
+func (controller *Controller) GetInvocationHandler(c *routing.Context) error {
+	requestID := c.Param("requestID")
+	if controller.results == nil {
+		return writeErrorResponse(c, innerErr.NewResourceNotFoundException("result store is disabled", nil))
+	}
+	// invocations of other accounts are not found
+	record, ok := controller.results.Get(requestID)
+	if !ok || record.AccountID != string(c.Request.Header.Peek(api.HeaderXAccountID)) {
+		return writeErrorResponse(c, innerErr.NewResourceNotFoundException("no invocation "+requestID, nil))
+	}
+	body, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	c.Response.SetBody(body)
+	return nil
+}
+
+// CancelInvocationHandler cancels an in-flight invocation
+func (controller *Controller) CancelInvocationHandler(c *routing.Context) error {
+	requestID := c.Param("requestID")
+	accountID := string(c.Request.Header.Peek(api.HeaderXAccountID))
+	runtime, err := controller.runtimeDispatcher.CancelRequest(requestID, accountID)
+	if err != nil {
+		return writeErrorResponse(c, innerErr.NewResourceNotFoundException(err.Error(), err))
+	}
+	logs.Infof("request %s on runtime %s cancelled", requestID, runtime.RuntimeID)
+	c.SetStatusCode(http.StatusNoContent)
+	return nil
+}
+
+func writeErrorResponse(c *routing.Context, err error) error {
+	finalErr := innerErr.GenericKunFinalError(err)
+	bodyData, _ := json.Marshal(finalErr)
+	c.Response.SetStatusCode(finalErr.Status)
+	c.Response.SetBody(bodyData)
+	return nil
+}
+
 func (controller *Controller) InvalidateRuntime(c *routing.Context) error {
 	runtimeID := c.Param("runtimeID")
 	runtime, err := controller.runtimeDispatcher.GetRuntime(runtimeID)
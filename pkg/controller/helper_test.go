@@ -0,0 +1,182 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/lambda"
+
+	"github.com/baidu/easyfaas/cmd/controller/options"
+	"github.com/baidu/easyfaas/pkg/api"
+	"github.com/baidu/easyfaas/pkg/controller/rtctrl"
+)
+
+type fakeDataStorer struct {
+	functions map[string]*api.GetFunctionOutput
+	aliases   map[string]*api.GetAliasOutput
+	reserved  []*api.ReservedConcurrency
+}
+
+func (f *fakeDataStorer) GetFunction(input *api.GetFunctionInput) (*api.GetFunctionOutput, bool, error) {
+	if fn, ok := f.functions[*input.FunctionName]; ok {
+		return fn, false, nil
+	}
+	return nil, false, fmt.Errorf("function %s not found", *input.FunctionName)
+}
+
+func (f *fakeDataStorer) GetAlias(input *api.GetAliasInput) (*api.GetAliasOutput, bool, error) {
+	if alias, ok := f.aliases[input.FunctionBrn]; ok {
+		return alias, false, nil
+	}
+	return nil, false, fmt.Errorf("alias %s not found", input.FunctionBrn)
+}
+
+func (f *fakeDataStorer) GetRuntimeConfiguration(input *api.GetRuntimeConfigurationInput) (*api.RuntimeConfiguration, bool, error) {
+	return &api.RuntimeConfiguration{Name: input.RuntimeName}, false, nil
+}
+
+func (f *fakeDataStorer) ListReservedConcurrency(input *api.ListReservedConcurrencyInput) (*api.ListReservedConcurrencyOutput, error) {
+	return &api.ListReservedConcurrencyOutput{Functions: f.reserved}, nil
+}
+
+type fakeFuncletClient struct {
+	lock       sync.Mutex
+	containers api.ListContainersResponse
+	warmUps    []string
+	coolDowns  []string
+	freezes    []string
+	freezeErr  error
+	warmUpErr  error
+	// onCoolDown simulates the runner of a reset container connecting back
+	onCoolDown func(containerID string)
+}
+
+func (f *fakeFuncletClient) List(*api.FuncletClientListContainersInput) (*api.ListContainersResponse, error) {
+	return &f.containers, nil
+}
+
+func (f *fakeFuncletClient) Info(*api.FuncletClientContainerInfoInput) (*api.ContainerInfoResponse, error) {
+	return nil, nil
+}
+
+func (f *fakeFuncletClient) IDEWarmUp(*api.FuncletClientWarmUpInput) (*api.WarmUpResponse, error) {
+	return nil, nil
+}
+
+func (f *fakeFuncletClient) WarmUp(input *api.FuncletClientWarmUpInput) (*api.WarmUpResponse, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.warmUps = append(f.warmUps, input.ContainerID)
+	if f.warmUpErr != nil {
+		return nil, f.warmUpErr
+	}
+	return &api.WarmUpResponse{}, nil
+}
+
+func (f *fakeFuncletClient) CoolDown(input *api.FuncletClientCoolDownInput) (*api.ResetResponse, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.coolDowns = append(f.coolDowns, input.ContainerID)
+	if f.onCoolDown != nil {
+		f.onCoolDown(input.ContainerID)
+	}
+	return &api.ResetResponse{}, nil
+}
+
+func (f *fakeFuncletClient) Freeze(input *api.FuncletClientFreezeInput) (*api.FreezeResponse, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.freezes = append(f.freezes, string(input.State)+":"+input.ContainerID)
+	if f.freezeErr != nil {
+		return nil, f.freezeErr
+	}
+	return &api.FreezeResponse{ContainerID: input.ContainerID, State: input.State}, nil
+}
+
+func (f *fakeFuncletClient) Reborn(*api.FuncletClientRebornInput) (*api.ResetResponse, error) {
+	return &api.ResetResponse{}, nil
+}
+
+func (f *fakeFuncletClient) NodeInfo(*api.FuncletClientListNodeInput) (*api.FuncletNodeInfo, error) {
+	return testNodeInfo(), nil
+}
+
+func testNodeInfo() *api.FuncletNodeInfo {
+	return &api.FuncletNodeInfo{
+		Resource: &api.FuncletResource{
+			Capacity:    &api.Resource{Memory: 1342177280000, MilliCPUs: 5000},
+			Allocatable: &api.Resource{Memory: 1342177280000, MilliCPUs: 5000},
+			Default:     &api.Resource{Memory: 134217728, MilliCPUs: 100},
+			BaseMemory:  134217728,
+		},
+	}
+}
+
+func testFunction(functionBRN, commitID string) *api.GetFunctionOutput {
+	memory := api.MinMemorySize
+	runtime := "nodejs12"
+	name := "hello"
+	return &api.GetFunctionOutput{
+		Code: &api.FunctionCodeLocation{},
+		Configuration: &api.FunctionConfiguration{
+			CommitID:           &commitID,
+			PodConcurrentQuota: 1,
+			FunctionConfiguration: lambda.FunctionConfiguration{
+				FunctionArn:  &functionBRN,
+				FunctionName: &name,
+				MemorySize:   &memory,
+				Runtime:      &runtime,
+			},
+		},
+	}
+}
+
+// newTestController creates a controller with num cold runtimes and fake clients
+func newTestController(num int, storer *fakeDataStorer) (*Controller, *fakeFuncletClient) {
+	funclet := &fakeFuncletClient{}
+	opts := options.NewOptions()
+	dispatcher := rtctrl.NewRuntimeManager(testNodeInfo(), &rtctrl.RuntimeManagerParameters{
+		MaxRuntimeIdle:   opts.MaxRuntimeIdle,
+		MaxRunnerDefunct: opts.MaxRunnerDefunct,
+		EvictionPolicy:   opts.EvictionPolicy,
+	})
+	dispatcher.SetFreezer(&runtimeFreezer{client: funclet})
+	for i := 0; i < num; i++ {
+		rt := dispatcher.NewRuntime(&rtctrl.NewRuntimeParameters{
+			RuntimeID:      "runtime-" + strconv.Itoa(i),
+			ConcurrentMode: true,
+			Resource:       &api.Resource{MilliCPUs: 100, Memory: 134217728},
+		})
+		rt.SetState(rtctrl.RuntimeStateCold)
+	}
+	controller := &Controller{
+		runOptions:        opts,
+		FuncletClient:     funclet,
+		runtimeDispatcher: dispatcher,
+		dataStorer:        storer,
+		insideDataStorer:  storer,
+		concurrency:       newConcurrencyLimiter(),
+		provisioner:       newProvisioner(),
+		drain:             newDrainer(),
+		breaker:           newWarmUpBreaker(opts.WarmUpBreakerThreshold, time.Duration(opts.WarmUpBreakerCoolOff)*time.Second),
+	}
+	return controller, funclet
+}
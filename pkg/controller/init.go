@@ -32,9 +32,20 @@ import (
 )
 
 func Init(options *options.ControllerOptions) (controller *Controller, err error) {
+	if err = checkPriorityReservedShare(options.PriorityReservedShare); err != nil {
+		return nil, err
+	}
+	if _, err = rtctrl.GetEvictionPolicy(options.EvictionPolicy); err != nil {
+		return nil, err
+	}
 	controller = &Controller{
 		runOptions:    options,
 		FuncletClient: client.NewFuncletClient(options.FuncletClientOptions),
+		concurrency:   newConcurrencyLimiter(),
+		provisioner:   newProvisioner(),
+		drain:         newDrainer(),
+		breaker: newWarmUpBreaker(options.WarmUpBreakerThreshold,
+			time.Duration(options.WarmUpBreakerCoolOff)*time.Second),
 	}
 
 	for {
@@ -68,7 +79,25 @@ func Init(options *options.ControllerOptions) (controller *Controller, err error
 	if err != nil {
 		return nil, err
 	}
+	if err := controller.loadReservations(); err != nil {
+		logs.Warnf("load reserved concurrency failed, reservations are learned on invocations: %s", err)
+	}
+	if err = controller.initRateLimiter(options.RateLimitOptions); err != nil {
+		return nil, err
+	}
+	if err = controller.initResultStore(options.ResultStoreOptions, options.EventQueueOptions); err != nil {
+		return nil, err
+	}
+	controller.initDestinations(options.DestinationOptions)
+	if err = controller.initEventQueue(options.EventQueueOptions); err != nil {
+		return nil, err
+	}
+	controller.recoverResults()
+	controller.initPredictor(options.PredictorOptions)
 	go controller.cronTask(options)
+	if options.RuntimeProbeInterval > 0 {
+		go controller.probeTask(options)
+	}
 	if options.RecommendedOptions.Features.EnableMetrics {
 		go controller.metricTask(options)
 	}
@@ -85,8 +114,15 @@ func (controller *Controller) initContainers() (err error) {
 		MaxRuntimeIdle:        controller.runOptions.MaxRuntimeIdle,
 		MaxRunnerDefunct:      controller.runOptions.MaxRunnerDefunct,
 		MaxRunnerResetTimeout: controller.runOptions.MaxRunnerResetTimeout,
+		MaxWaitQueueLength:    controller.runOptions.MaxWaitQueueLength,
+		MaxWaitTime:           controller.runOptions.MaxWaitTime,
+		EvictionPolicy:        controller.runOptions.EvictionPolicy,
+		RuntimeFreezeIdle:     controller.runOptions.RuntimeFreezeIdle,
+		ProbeTimeout:          controller.runOptions.RuntimeProbeTimeout,
+		ProbeFailureThreshold: controller.runOptions.RuntimeProbeFailureThreshold,
 	}
 	controller.runtimeDispatcher = rtctrl.NewRuntimeManager(nodeInfo, params)
+	controller.runtimeDispatcher.SetFreezer(&runtimeFreezer{client: controller.FuncletClient})
 
 	res, err := controller.FuncletClient.List(&api.FuncletClientListContainersInput{RequestID: id.GetRequestID()})
 	if err != nil {
@@ -96,16 +132,50 @@ func (controller *Controller) initContainers() (err error) {
 	logs.Infof("get container %+v", allContainers)
 
 	for _, container := range allContainers {
+		controller.thawRestoredContainer(container)
 		// set default runtime concurrent mode from service option
 		params := &rtctrl.NewRuntimeParameters{
 			RuntimeID:               container.ContainerID,
 			ConcurrentMode:          controller.runOptions.ConcurrentMode,
 			StreamMode:              container.WithStreamMode,
 			WaitRuntimeAliveTimeout: controller.runOptions.RuntimeConfigOptions.WaitRuntimeAliveTimeout,
-			IsFrozen:                container.IsFrozen,
+			Merged:                  container.IsFrozen,
 			Resource:                container.Resource,
 		}
-		controller.runtimeDispatcher.NewRuntime(params)
+		rt := controller.runtimeDispatcher.NewRuntime(params)
+		controller.restoreRuntime(rt, container)
 	}
 	return nil
 }
+
+// thawRestoredContainer thaws the frozen warm container so that its runtime reconnects,
+// frozen containers without function are merged into others and stay frozen
+func (controller *Controller) thawRestoredContainer(container *api.ContainerInfo) {
+	if !container.IsFrozen || container.Function == nil {
+		return
+	}
+	_, err := controller.FuncletClient.Freeze(&api.FuncletClientFreezeInput{
+		ContainerID: container.ContainerID,
+		RequestID:   id.GetRequestID(),
+		State:       api.Thawed,
+	})
+	if err != nil {
+		logs.Warnf("thaw container %s failed: %s", container.ContainerID, err)
+		return
+	}
+	container.IsFrozen = false
+}
+
+// restoreRuntime keeps the function of the container warmed before the controller restarted,
+// the runtime is reused when it reconnects instead of being reset
+func (controller *Controller) restoreRuntime(rt *rtctrl.RuntimeInfo, container *api.ContainerInfo) {
+	fn := container.Function
+	if rt == nil || fn == nil || fn.CommitID == "" || container.IsFrozen {
+		return
+	}
+	if err := controller.runtimeDispatcher.RestoreRuntime(rt, fn); err != nil {
+		logs.Warnf("restore runtime %s of function %s failed: %s", rt.RuntimeID, fn.FunctionBrn, err)
+		return
+	}
+	logs.Infof("restore runtime %s of function %s commit %s", rt.RuntimeID, fn.FunctionBrn, fn.CommitID)
+}
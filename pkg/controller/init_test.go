@@ -0,0 +1,76 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/baidu/easyfaas/cmd/controller/options"
+	"github.com/baidu/easyfaas/pkg/api"
+	"github.com/baidu/easyfaas/pkg/controller/rtctrl"
+)
+
+func TestInitContainersRestoreRuntimes(t *testing.T) {
+	fn := &api.ContainerFunction{
+		CommitID:       "commit-1",
+		FunctionBrn:    "brn:cloud:faas:bj:8f6e:function:hello:1",
+		MemorySize:     128,
+		ConcurrentMode: true,
+	}
+	funclet := &fakeFuncletClient{
+		containers: api.ListContainersResponse{
+			{ContainerID: "cold"},
+			{ContainerID: "warm", Function: fn},
+			{ContainerID: "frozen", Function: fn, IsFrozen: true},
+			{ContainerID: "merged", IsFrozen: true},
+		},
+	}
+	controller := &Controller{
+		runOptions:    options.NewOptions(),
+		FuncletClient: funclet,
+	}
+	if err := controller.initContainers(); err != nil {
+		t.Fatal(err)
+	}
+
+	expect := map[string]string{"cold": "", "warm": "commit-1", "frozen": "commit-1", "merged": ""}
+	for id, commitID := range expect {
+		rt, err := controller.runtimeDispatcher.GetRuntime(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rt.CommitID != commitID {
+			t.Errorf("runtime %s: expect commit %q, got %q", id, commitID, rt.CommitID)
+		}
+	}
+	merged, _ := controller.runtimeDispatcher.GetRuntime("merged")
+	if merged.State != rtctrl.RuntimeStateMerged {
+		t.Errorf("expect merged container stays frozen, got %s", merged.State)
+	}
+	if fmt.Sprint(funclet.freezes) != fmt.Sprint([]string{"THAWED:frozen"}) {
+		t.Errorf("expect frozen warm container thawed, got %v", funclet.freezes)
+	}
+}
+
+func TestInitUnknownEvictionPolicy(t *testing.T) {
+	opts := options.NewOptions()
+	opts.EvictionPolicy = "fifo"
+	if _, err := Init(opts); err == nil {
+		t.Error("expect unknown eviction policy rejected")
+	}
+}
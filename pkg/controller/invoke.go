@@ -18,8 +18,8 @@ package controller
 
 import (
 	"encoding/base64"
+	"errors"
 	"fmt"
-	"math/rand"
 	"net/http"
 	"runtime"
 	"strconv"
@@ -45,6 +45,9 @@ var (
 	NoOutputMsg = "no output"
 )
 
+// errRuntimeExhausted: no runtime is left to warm up, the request waits for a released one
+var errRuntimeExhausted = errors.New("empty runtime")
+
 func (controller *Controller) Do(ctx *InvokeContext) {
 	var err error
 	defer func() {
@@ -62,20 +65,42 @@ func (controller *Controller) Do(ctx *InvokeContext) {
 
 	ctx.Logger.V(3).Infof("start to invoke request %s", ctx.RequestID)
 
-	if _, err = controller.getFunction(ctx); err != nil {
+	if err = controller.checkAccountRateLimit(ctx); err != nil {
+		return
+	}
+
+	// function metadata may be resolved ahead, eg. once for a whole batch
+	if ctx.Function == nil {
+		if _, err = controller.getFunction(ctx); err != nil {
+			return
+		}
+	}
+	ctx.Priority = invocationPriority(ctx)
+
+	if ctx.Runtime == nil {
+		if _, err = controller.getRuntimeConfiguration(ctx); err != nil {
+			return
+		}
+	}
+
+	if err = controller.checkFunctionRateLimit(ctx); err != nil {
 		return
 	}
 
-	if _, err = controller.getRuntimeConfiguration(ctx); err != nil {
+	if err = controller.acquireConcurrency(ctx); err != nil {
 		return
 	}
+	defer controller.afterResponse(ctx, func() { controller.releaseConcurrency(ctx) })
+	defer controller.observeInvocation(ctx, time.Now())
+	defer controller.coldStartDone(ctx)
 
 	if err = controller.getRuntime(ctx); err != nil {
 		return
 	}
-	defer controller.putRuntime(ctx)
+	defer controller.afterResponse(ctx, func() { controller.putRuntime(ctx) })
 
 	controller.invocation(ctx)
+	controller.coldStartDone(ctx)
 	controller.buildResponse(ctx, ctx.Output.Output)
 }
 
@@ -179,10 +204,14 @@ func (controller *Controller) getFunction(ctx *InvokeContext) (hitCache bool, er
 
 func (controller *Controller) getFunctionByFunctionName(ctx *InvokeContext, input *api.GetFunctionInput) (hitCache bool, err error) {
 	hitCache = false
-	// do not support for alias qualifier
 	if !api.RegVersion.MatchString(ctx.Qualifier) {
-		err = innerErr.NewInvalidParameterValueException("invalid function qualifier", nil)
-		return false, err
+		if !api.RegfunctionName.MatchString(ctx.Qualifier) {
+			err = innerErr.NewInvalidParameterValueException("invalid function qualifier", nil)
+			return false, err
+		}
+		if err = controller.getAliasVersionByName(ctx); err != nil {
+			return false, err
+		}
 	}
 
 	// Do not use cache, when invoked by function name
@@ -259,20 +288,43 @@ func (controller *Controller) getCanaryFunctionBrn(ctx *InvokeContext) (err erro
 		ctx.Logger.Debugf("function store get failed, err(%+v)", err)
 		return err
 	}
-	additionalVersion := *alias.AdditionalVersion
-	weight := *alias.AdditionalVersionWeight
-
-	if additionalVersion != "" && weight != 0 {
-		r := rand.Float64()
-		if r > weight {
-			ctx.Qualifier = alias.FunctionVersion
-		} else {
-			ctx.Qualifier = additionalVersion
-		}
-		ctx.Brn.Resource = "function:" + alias.FunctionName + ":" + ctx.Qualifier
-		ctx.FunctionBRN = ctx.Brn.String()
+	version, err := controller.routeAlias(ctx, alias)
+	if err != nil {
+		return err
 	}
+	ctx.Qualifier = version
+	ctx.Brn.Resource = "function:" + alias.FunctionName + ":" + ctx.Qualifier
+	ctx.FunctionBRN = ctx.Brn.String()
+	return nil
+}
 
+// getAliasVersionByName resolves the alias qualifier of a function invoked by name to a version,
+// the alias is looked up by its brn within the account of the caller
+func (controller *Controller) getAliasVersionByName(ctx *InvokeContext) (err error) {
+	input := &api.GetAliasInput{
+		FunctionBrn:   brn.GenerateFuncBrnString("bj", ctx.AccountID, ctx.FunctionName, ctx.Qualifier),
+		Authorization: ctx.Authorization,
+		RequestID:     ctx.RequestID,
+		AccountID:     ctx.AccountID,
+		WithCache:     true,
+		SimpleAuth:    true,
+	}
+	// force to use inside api, the same as getCanaryFunctionBrn
+	alias, _, err := controller.insideDataStorer.GetAlias(input)
+	if err != nil {
+		ctx.Logger.Debugf("function store get failed, err(%+v)", err)
+		return err
+	}
+	// the inside api is called as root, the alias must belong to the caller
+	if alias.Uid != ctx.AccountID {
+		ctx.Logger.Warnf("invalid caller id, alias owner id %s caller id %s", alias.Uid, ctx.AccountID)
+		return innerErr.NewInvalidInvokeCallerException(fmt.Sprintf("owner id %s caller id %s", alias.Uid, ctx.AccountID), nil)
+	}
+	version, err := controller.routeAlias(ctx, alias)
+	if err != nil {
+		return err
+	}
+	ctx.Qualifier = version
 	return nil
 }
 
@@ -327,21 +379,27 @@ func (controller *Controller) getRuntime(ctx *InvokeContext) (err error) {
 			ctx.Metrics.SetLabel(podSourceLabel, runtimeT)
 		}()
 	}
+	defer func() {
+		ctx.runtimeVia = runtimeT
+	}()
 
 	ctx.Input = &rtctrl.InvocationInput{
 		ExternalRequestID: ctx.ExternalRequestID,
 		RequestID:         ctx.RequestID,
 		User:              ctx.OwnerUser,
+		AccountID:         ctx.AccountID,
 		Code:              ctx.Function.Code,
 		Configuration:     ctx.Function.Configuration,
 		LogConfig:         ctx.Function.LogConfig,
 		EnableMetrics:     ctx.RunOptions.RecommendedOptions.Features.EnableMetrics,
 		IsLogTail:         ctx.LogType.IsLogTypeTail(),
+		ClientContext:     clientContext(ctx.Request.Headers),
 		Request:           ctx.Request,
 		Response:          ctx.Response,
 		Logger:            ctx.Logger,
 		InvokeType:        ctx.InvokeType,
 		TriggerType:       ctx.TriggerType,
+		Priority:          ctx.Priority,
 	}
 
 	if ctx.WithStreamMode || strings.HasSuffix(ctx.Runtime.Name, "stream") {
@@ -356,7 +414,36 @@ func (controller *Controller) getRuntime(ctx *InvokeContext) (err error) {
 			break
 		}
 	}
+	if err == errRuntimeExhausted {
+		runtimeT, err = controller.waitRuntime(ctx)
+	}
+	return
+}
 
+// waitRuntime queues the request until a runtime of the function is released;
+// when a cold runtime is freed meanwhile the request tries to start it and queues again if it fails
+func (controller *Controller) waitRuntime(ctx *InvokeContext) (runtimeType string, err error) {
+	deadline := time.Now().Add(time.Duration(controller.runOptions.MaxWaitTime) * time.Millisecond)
+	for {
+		ctx.Logger.Infof("runtime exhausted, wait for a released one")
+		rt, waitErr := controller.runtimeDispatcher.WaitRuntime(ctx.Input)
+		if _, ok := waitErr.(rtctrl.ColdRuntimeFreed); ok {
+			runtimeType, err = controller.tryGetRuntime(ctx)
+			if err != errRuntimeExhausted || time.Now().After(deadline) {
+				break
+			}
+			continue
+		}
+		if waitErr != nil {
+			ctx.Logger.Warnf("wait runtime failed: %s", waitErr)
+			return api.RuntimeViaUnknown, innerErr.NewTooManyRequestsException("empty runtime", waitErr)
+		}
+		ctx.Input.Runtime = rt
+		return api.RuntimeViaWarm, nil
+	}
+	if err == errRuntimeExhausted {
+		err = innerErr.NewTooManyRequestsException("empty runtime", err)
+	}
 	return
 }
 
@@ -371,9 +458,23 @@ func (controller *Controller) tryGetRuntime(ctx *InvokeContext) (runtimeType str
 	}
 	ctx.Logger.Infof("get runtime failed, try to warm up one")
 
+	cold := controller.runtimeDispatcher.ColdRuntimeCount()
+	if err = controller.concurrency.admitCold(ctx.concurrencyKey, cold, controller.idleWarmRuntimes); err != nil {
+		return
+	}
+	if err = controller.admitPriority(ctx.Priority, cold); err != nil {
+		return
+	}
+	if err = controller.allowColdStart(ctx); err != nil {
+		return
+	}
 	rt, recommendation := controller.runtimeDispatcher.OccupyColdRuntime(ctx.Input)
 	if rt == nil {
-		err = innerErr.NewTooManyRequestsException("empty runtime", nil)
+		rt, recommendation = controller.evictRuntime(ctx)
+	}
+	if rt == nil {
+		controller.coldStartCanceled(ctx)
+		err = errRuntimeExhausted
 		ctx.Logger.V(9).Infof("found empty runtime, all runtime: %s", controller.runtimeDispatcher)
 		return
 	}
@@ -386,6 +487,7 @@ func (controller *Controller) tryGetRuntime(ctx *InvokeContext) (runtimeType str
 		Configuration:        ctx.Function.Configuration,
 		RuntimeConfiguration: ctx.Runtime,
 		WithStreamMode:       ctx.WithStreamMode,
+		RuntimeAPIToken:      rt.NewRuntimeAPIToken(),
 	}
 	if recommendation != nil {
 		input.NeedScaleUp = true
@@ -393,6 +495,7 @@ func (controller *Controller) tryGetRuntime(ctx *InvokeContext) (runtimeType str
 	}
 	_, err = ctx.Clients.FuncletClient.WarmUp(input)
 	if err != nil {
+		controller.coldStartFailed(ctx, err)
 		rt.Invalidate()
 		if err := rt.Release(); err != nil {
 			ctx.Logger.Errorf("release runtime %s failed: %s", rt.RuntimeID, err)
@@ -408,6 +511,31 @@ func (controller *Controller) tryGetRuntime(ctx *InvokeContext) (runtimeType str
 	return
 }
 
+// evictRuntime cools down an idle warm runtime of another function and occupies it as a cold one
+func (controller *Controller) evictRuntime(ctx *InvokeContext) (*rtctrl.RuntimeInfo, *api.ScaleUpRecommendation) {
+	victim, recommend, err := controller.runtimeDispatcher.EvictRuntime(ctx.Input)
+	if err != nil {
+		ctx.Logger.V(6).Infof("evict runtime failed: %s", err)
+		return nil, nil
+	}
+	ctx.Logger.Infof("evict runtime %s", victim.RuntimeID)
+	if err := controller.resetStoppedRuntime(victim, recommend, ctx.Logger); err != nil {
+		victim.CancelEviction()
+		return nil, nil
+	}
+	timeout := time.Duration(controller.runOptions.RuntimeConfigOptions.WaitRuntimeAliveTimeout) * time.Second
+	if !victim.WaitCold(timeout) {
+		ctx.Logger.Warnf("evicted runtime %s is not ready after %s", victim.RuntimeID, timeout)
+		victim.CancelEviction()
+		return nil, nil
+	}
+	rt, scaleUp := controller.runtimeDispatcher.OccupyEvictedRuntime(victim, ctx.Input)
+	if rt == nil {
+		victim.CancelEviction()
+	}
+	return rt, scaleUp
+}
+
 func (controller *Controller) buildResponse(ctx *InvokeContext, output *rtctrl.InvocationResponse) {
 	status := http.StatusOK
 	if ctx.RunOptions.RecommendedOptions.Features.EnableMetrics {
@@ -449,7 +577,8 @@ func (controller *Controller) buildResponse(ctx *InvokeContext, output *rtctrl.I
 		ctx.Metrics.rtCtrl = ctx.Statistic.Metric
 	}
 
-	if ctx.WithStreamMode || ctx.InvokeType == api.InvokeTypeEvent {
+	// the body of a streamed response is written by the runtime chunk by chunk
+	if ctx.WithStreamMode || ctx.InvokeType == api.InvokeTypeEvent || ctx.Response.BodyStream != nil {
 		return
 	}
 	if ctx.LogToBody {
@@ -504,6 +633,21 @@ func getFuncResult(output *rtctrl.InvocationResponse) string {
 	return output.ErrorInfo
 }
 
+// afterResponse runs fn once the runtime is done with the request;
+// a streamed or cancelled request may still be running after Do returns
+func (controller *Controller) afterResponse(ctx *InvokeContext, fn func()) {
+	if ctx.Output == nil || ctx.Output.Done == nil {
+		fn()
+		return
+	}
+	controller.drain.track()
+	go func() {
+		<-ctx.Output.Done
+		fn()
+		controller.drain.done()
+	}()
+}
+
 func (controller *Controller) putRuntime(ctx *InvokeContext) {
 	if ctx.RunOptions.RecommendedOptions.Features.EnableMetrics {
 		ctx.Metrics.StepStart(StagePutPod)
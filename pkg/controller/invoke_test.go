@@ -18,8 +18,13 @@
 package controller
 
 import (
+	"net/http"
 	"strings"
 	"testing"
+
+	"github.com/baidu/easyfaas/pkg/controller/rtctrl"
+	innerErr "github.com/baidu/easyfaas/pkg/error"
+	"github.com/baidu/easyfaas/pkg/util/logs"
 )
 
 func TestParseBrn(t *testing.T) {
@@ -33,3 +38,22 @@ func TestParseBrn(t *testing.T) {
 	}
 	t.Logf("err %s", err)
 }
+
+func TestRuntimeExhausted(t *testing.T) {
+	controller, _ := newTestController(0, &fakeDataStorer{})
+	function := testFunction("brn:cloud:faas:bj:8f6e:function:hello:1", "commit-1")
+	ctx := &InvokeContext{
+		Function: function,
+		Input:    &rtctrl.InvocationInput{Configuration: function.Configuration, Logger: logs.NewLogger()},
+		Logger:   logs.NewLogger(),
+	}
+	if _, err := controller.tryGetRuntime(ctx); err != errRuntimeExhausted {
+		t.Fatalf("expect runtime exhausted, got %v", err)
+	}
+
+	// the request is rejected once it can not wait for a released runtime
+	_, err := controller.waitRuntime(ctx)
+	if e, ok := err.(innerErr.FinalError); !ok || e.Status != http.StatusTooManyRequests {
+		t.Errorf("expect too many requests, got %v", err)
+	}
+}
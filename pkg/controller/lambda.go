@@ -0,0 +1,178 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"encoding/base64"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/service/lambda"
+	routing "github.com/qiangxue/fasthttp-routing"
+
+	"github.com/baidu/easyfaas/pkg/api"
+	innerErr "github.com/baidu/easyfaas/pkg/error"
+	"github.com/baidu/easyfaas/pkg/util/json"
+)
+
+// maxClientContextSize: max size of the base64-encoded client context in the Lambda Invoke API
+const maxClientContextSize = 3583
+
+// lambdaError: error body of the Lambda Invoke API
+type lambdaError struct {
+	Type    string `json:"Type,omitempty"`
+	Message string `json:"message"`
+}
+
+// LambdaInvokeHandler serves the AWS Lambda Invoke API,
+// so that AWS SDK clients can invoke functions unchanged
+func (controller *Controller) LambdaInvokeHandler(c *routing.Context) error {
+	invocationType, err := adaptLambdaRequest(c)
+	if err != nil {
+		return writeLambdaError(c, err)
+	}
+	ctx, err := controller.newInvokeContext(c)
+	if err != nil {
+		return writeLambdaError(c, err)
+	}
+	c.Response.Header.Set(api.HeaderAmznRequestID, ctx.ExternalRequestID)
+
+	if err := controller.drain.admit(); err != nil {
+		return writeLambdaError(c, err)
+	}
+	defer controller.drain.done()
+
+	switch invocationType {
+	case lambda.InvocationTypeDryRun:
+		// only verify the function could be invoked
+		if _, err := controller.getFunction(&ctx); err != nil {
+			return writeLambdaError(c, err)
+		}
+		if _, err := controller.getRuntimeConfiguration(&ctx); err != nil {
+			return writeLambdaError(c, err)
+		}
+		c.SetStatusCode(http.StatusNoContent)
+	case lambda.InvocationTypeEvent:
+		if err := controller.enqueueEvent(&ctx); err != nil {
+			ctx.Logger.Errorf("enqueue event failed: %s", err)
+			return writeLambdaError(c, innerErr.NewServiceException("enqueue event failed", err))
+		}
+		c.SetStatusCode(http.StatusAccepted)
+	default:
+		controller.Do(&ctx)
+		writeLambdaResponse(c, &ctx)
+	}
+	return nil
+}
+
+// adaptLambdaRequest translates the Lambda request headers into the controller ones
+func adaptLambdaRequest(c *routing.Context) (invocationType string, err error) {
+	invocationType = string(c.Request.Header.Peek(api.HeaderAmzInvocationType))
+	switch invocationType {
+	case "":
+		invocationType = lambda.InvocationTypeRequestResponse
+		c.Request.Header.Set(api.HeaderInvokeType, api.InvokeTypeCommon)
+	case lambda.InvocationTypeRequestResponse, lambda.InvocationTypeDryRun:
+		c.Request.Header.Set(api.HeaderInvokeType, api.InvokeTypeCommon)
+	case lambda.InvocationTypeEvent:
+		c.Request.Header.Set(api.HeaderInvokeType, api.InvokeTypeEvent)
+	default:
+		return "", innerErr.NewInvalidParameterValueException("invalid invocation type "+invocationType, nil)
+	}
+
+	logType := api.LogType(c.Request.Header.Peek(api.HeaderAmzLogType))
+	if logType == "" {
+		logType = api.LogTypeNone
+	}
+	if !logType.Valid() {
+		return "", innerErr.NewInvalidParameterValueException("invalid log type "+string(logType), nil)
+	}
+	c.Request.Header.Set(api.HeaderLogType, string(logType))
+	c.Request.Header.Del(api.HeaderLogToBody)
+
+	if cc := c.Request.Header.Peek(api.HeaderAmzClientContext); len(cc) > 0 {
+		if len(cc) > maxClientContextSize {
+			return "", innerErr.NewInvalidParameterValueException("client context is too large", nil)
+		}
+		if _, err := base64.StdEncoding.DecodeString(string(cc)); err != nil {
+			return "", innerErr.NewInvalidParameterValueException("client context is not base64 encoded", err)
+		}
+	}
+
+	// the Lambda Invoke API defaults to the unpublished version
+	if len(c.QueryArgs().Peek("Qualifier")) == 0 && api.RegfunctionName.MatchString(c.Param("functionName")) {
+		c.QueryArgs().Set("Qualifier", "$LATEST")
+	}
+	return invocationType, nil
+}
+
+// clientContext decodes the client context passed by the caller
+func clientContext(headers map[string]string) string {
+	cc, ok := headers[api.HeaderAmzClientContext]
+	if !ok || cc == "" {
+		return ""
+	}
+	data, err := base64.StdEncoding.DecodeString(cc)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// writeLambdaResponse: function errors are responded with 200 and X-Amz-Function-Error,
+// while errors of the service are responded as Lambda errors
+func writeLambdaResponse(c *routing.Context, ctx *InvokeContext) {
+	funcError := ctx.Output != nil && ctx.Output.Output != nil && ctx.Output.Output.FuncError != ""
+	if !funcError && ctx.Response.StatusCode >= http.StatusBadRequest {
+		var basic innerErr.BasicError
+		if err := json.Unmarshal(ctx.Response.Body, &basic); err != nil || basic.Code == "" {
+			basic = innerErr.NewServiceException(string(ctx.Response.Body), nil).BasicError
+			basic.Status = ctx.Response.StatusCode
+		}
+		writeLambdaError(c, innerErr.FinalError{BasicError: basic})
+		return
+	}
+
+	if v, ok := ctx.Response.Headers[api.XBceFunctionError]; ok {
+		ctx.Response.SetHeader(api.HeaderAmzFunctionError, v)
+	}
+	if v, ok := ctx.Response.Headers[api.HeaderLogResult]; ok {
+		ctx.Response.SetHeader(api.HeaderAmzLogResult, v)
+	}
+	if v, ok := ctx.Response.Headers[api.HeaderExecutedVersion]; ok {
+		ctx.Response.SetHeader(api.HeaderAmzExecutedVersion, v)
+	} else if ctx.Function != nil && ctx.Function.Configuration != nil && ctx.Function.Configuration.Version != nil {
+		ctx.Response.SetHeader(api.HeaderAmzExecutedVersion, *ctx.Function.Configuration.Version)
+	}
+	makeHTTPResponse(c, ctx)
+}
+
+func writeLambdaError(c *routing.Context, err error) error {
+	finalErr := innerErr.GenericKunFinalError(err)
+	body := lambdaError{Type: "User", Message: finalErr.Message}
+	if finalErr.Status >= http.StatusInternalServerError {
+		body.Type = "Service"
+	}
+	if finalErr.Cause != "" {
+		body.Message = finalErr.Cause
+	}
+	data, _ := json.Marshal(body)
+	c.Response.Header.Set(api.HeaderAmznErrorType, string(finalErr.Code))
+	c.Response.Header.SetContentType("application/json")
+	c.SetStatusCode(finalErr.Status)
+	c.Response.SetBody(data)
+	return nil
+}
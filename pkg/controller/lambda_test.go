@@ -0,0 +1,160 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"encoding/base64"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/lambda"
+	routing "github.com/qiangxue/fasthttp-routing"
+	"github.com/valyala/fasthttp"
+
+	"github.com/baidu/easyfaas/cmd/controller/options"
+	"github.com/baidu/easyfaas/pkg/api"
+	"github.com/baidu/easyfaas/pkg/controller/rtctrl"
+	innerErr "github.com/baidu/easyfaas/pkg/error"
+	"github.com/baidu/easyfaas/pkg/util/json"
+)
+
+func newLambdaRequest(uri string, headers map[string]string) *routing.Context {
+	c := &routing.Context{RequestCtx: &fasthttp.RequestCtx{}}
+	c.Request.SetRequestURI(uri)
+	for k, v := range headers {
+		c.Request.Header.Set(k, v)
+	}
+	return c
+}
+
+func TestAdaptLambdaRequest(t *testing.T) {
+	cases := []struct {
+		headers        map[string]string
+		invocationType string
+		invokeType     string
+		logType        string
+		err            bool
+	}{
+		{map[string]string{}, lambda.InvocationTypeRequestResponse, api.InvokeTypeCommon, api.LogTypeNone, false},
+		{map[string]string{api.HeaderAmzInvocationType: "Event"}, lambda.InvocationTypeEvent, api.InvokeTypeEvent, api.LogTypeNone, false},
+		{map[string]string{api.HeaderAmzInvocationType: "DryRun", api.HeaderAmzLogType: "Tail"}, lambda.InvocationTypeDryRun, api.InvokeTypeCommon, api.LogTypeTail, false},
+		{map[string]string{api.HeaderAmzInvocationType: "Async"}, "", "", "", true},
+		{map[string]string{api.HeaderAmzLogType: "Full"}, "", "", "", true},
+		{map[string]string{api.HeaderAmzClientContext: "not base64!"}, "", "", "", true},
+	}
+	for i, tc := range cases {
+		c := newLambdaRequest("/2015-03-31/functions/hello/invocations", tc.headers)
+		got, err := adaptLambdaRequest(c)
+		if (err != nil) != tc.err {
+			t.Errorf("case %d: unexpected error %v", i, err)
+			continue
+		}
+		if tc.err {
+			continue
+		}
+		if got != tc.invocationType {
+			t.Errorf("case %d: expect invocation type %s, got %s", i, tc.invocationType, got)
+		}
+		if v := string(c.Request.Header.Peek(api.HeaderInvokeType)); v != tc.invokeType {
+			t.Errorf("case %d: expect invoke type %s, got %s", i, tc.invokeType, v)
+		}
+		if v := string(c.Request.Header.Peek(api.HeaderLogType)); v != tc.logType {
+			t.Errorf("case %d: expect log type %s, got %s", i, tc.logType, v)
+		}
+	}
+}
+
+func TestAdaptLambdaRequestQualifier(t *testing.T) {
+	var qualifier string
+	router := routing.New()
+	router.Post("/2015-03-31/functions/<functionName>/invocations", func(c *routing.Context) error {
+		if _, err := adaptLambdaRequest(c); err != nil {
+			t.Fatal(err)
+		}
+		qualifier = string(c.QueryArgs().Peek("Qualifier"))
+		return nil
+	})
+	cases := map[string]string{
+		"/2015-03-31/functions/hello/invocations":                                   "$LATEST",
+		"/2015-03-31/functions/hello/invocations?Qualifier=prod":                    "prod",
+		"/2015-03-31/functions/brn:cloud:faas:bj:8f6e:function:hello:1/invocations": "",
+	}
+	for uri, want := range cases {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.Header.SetMethod("POST")
+		ctx.Request.SetRequestURI(uri)
+		router.HandleRequest(ctx)
+		if qualifier != want {
+			t.Errorf("%s: expect qualifier %q, got %q", uri, want, qualifier)
+		}
+	}
+}
+
+func TestClientContext(t *testing.T) {
+	cc := `{"custom":{"foo":"bar"}}`
+	headers := map[string]string{api.HeaderAmzClientContext: base64.StdEncoding.EncodeToString([]byte(cc))}
+	if got := clientContext(headers); got != cc {
+		t.Errorf("expect %s, got %s", cc, got)
+	}
+	if got := clientContext(map[string]string{}); got != "" {
+		t.Errorf("expect empty client context, got %s", got)
+	}
+}
+
+func TestWriteLambdaResponse(t *testing.T) {
+	newCtx := func() *InvokeContext {
+		return &InvokeContext{
+			RunOptions: options.NewOptions(),
+			Metrics:    NewInvokeMetrics("req"),
+			Response:   api.NewInvokeProxyResponseWithRequestID("req"),
+		}
+	}
+
+	// errors of the service
+	ctx := newCtx()
+	buildErrorResponse(ctx, innerErr.NewTooManyRequestsException("function concurrency exceeded", nil))
+	c := newLambdaRequest("/", nil)
+	writeLambdaResponse(c, ctx)
+	if c.Response.StatusCode() != http.StatusTooManyRequests {
+		t.Errorf("expect status 429, got %d", c.Response.StatusCode())
+	}
+	if v := string(c.Response.Header.Peek(api.HeaderAmznErrorType)); v != string(innerErr.TooManyRequestsException) {
+		t.Errorf("unexpected error type %s", v)
+	}
+	var body lambdaError
+	if err := json.Unmarshal(c.Response.Body(), &body); err != nil || body.Message != "function concurrency exceeded" || body.Type != "User" {
+		t.Errorf("unexpected error body %s", c.Response.Body())
+	}
+
+	// errors of the function
+	ctx = newCtx()
+	ctx.Output = &rtctrl.InvocationOutput{Output: &rtctrl.InvocationResponse{FuncError: "Unhandled"}}
+	ctx.Response.SetStatusCode(http.StatusOK)
+	ctx.Response.SetHeader(api.XBceFunctionError, "Unhandled")
+	ctx.Response.SetBody([]byte(`{"errorMessage":"boom"}`))
+	c = newLambdaRequest("/", nil)
+	writeLambdaResponse(c, ctx)
+	if c.Response.StatusCode() != http.StatusOK {
+		t.Errorf("expect status 200, got %d", c.Response.StatusCode())
+	}
+	if v := string(c.Response.Header.Peek(api.HeaderAmzFunctionError)); v != "Unhandled" {
+		t.Errorf("expect function error Unhandled, got %s", v)
+	}
+	if string(c.Response.Body()) != `{"errorMessage":"boom"}` {
+		t.Errorf("unexpected payload %s", c.Response.Body())
+	}
+}
@@ -25,6 +25,7 @@ import (
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 
+	"github.com/baidu/easyfaas/pkg/controller/ratelimit"
 	"github.com/baidu/easyfaas/pkg/controller/rtctrl"
 	"github.com/baidu/easyfaas/pkg/util/logs"
 	"github.com/baidu/easyfaas/pkg/util/logs/metric"
@@ -38,7 +39,7 @@ const (
 	responseCodeLabel             = "response_code"
 )
 
-var allCostLabelList = []string{getFunctionHitCacheLabel, getConfigurationHitCacheLabel, podSourceLabel, responseCodeLabel, getFunctionBrnLabel}
+var allCostLabelList = []string{getFunctionHitCacheLabel, getConfigurationHitCacheLabel, podSourceLabel, responseCodeLabel, getFunctionBrnLabel, functionVersionLabel}
 
 var (
 	stages = []metric.MetricConfig{
@@ -313,6 +314,7 @@ func NewInvokeMetrics(reqID string) *InvokeMetrics {
 			getConfigurationHitCacheLabel: "",
 			podSourceLabel:                "",
 			responseCodeLabel:             "",
+			functionVersionLabel:          "",
 		},
 	}
 	for i := 0; i < StageInvocation+1; i++ {
@@ -476,4 +478,7 @@ func init() {
 	if err := rtctrl.InitRtCtrlMetric(); err != nil {
 		panic(err)
 	}
+	if err := ratelimit.InitMetric(); err != nil {
+		panic(err)
+	}
 }
@@ -0,0 +1,76 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package predictor
+
+// history is a sliding window histogram of arrivals, one bucket per BucketSize
+type history struct {
+	counts []float64
+	// latest: number of the newest bucket
+	latest int64
+}
+
+func newHistory(size int, bucket int64) *history {
+	if size < 2 {
+		size = 2
+	}
+	return &history{
+		counts: make([]float64, size),
+		latest: bucket,
+	}
+}
+
+func (h *history) slot(bucket int64) int {
+	return int(bucket % int64(len(h.counts)))
+}
+
+// advance moves the window forward and clears the buckets left behind
+func (h *history) advance(bucket int64) {
+	if bucket <= h.latest {
+		return
+	}
+	n := bucket - h.latest
+	if n > int64(len(h.counts)) {
+		n = int64(len(h.counts))
+	}
+	for i := int64(1); i <= n; i++ {
+		h.counts[h.slot(bucket-n+i)] = 0
+	}
+	h.latest = bucket
+}
+
+func (h *history) add(bucket int64) {
+	h.advance(bucket)
+	if h.latest-bucket >= int64(len(h.counts)) {
+		return
+	}
+	h.counts[h.slot(bucket)]++
+}
+
+// complete returns the finished buckets before the given one, oldest first,
+// the buckets after the latest one or out of the window are empty
+func (h *history) complete(bucket int64) []float64 {
+	n := len(h.counts) - 1
+	series := make([]float64, n)
+	for i := 0; i < n; i++ {
+		b := bucket - int64(n-i)
+		if b > h.latest || h.latest-b >= int64(len(h.counts)) {
+			continue
+		}
+		series[i] = h.counts[h.slot(b)]
+	}
+	return series
+}
@@ -0,0 +1,78 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package predictor
+package predictor
+
+import (
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+const (
+	defaultBucketSize     = 10 * time.Second
+	defaultWindowBuckets  = 360
+	defaultMinIdleTimeout = 10 * time.Second
+	defaultMaxIdleTimeout = 10 * time.Minute
+	defaultMaxPrewarm     = 4
+	defaultMinCorrelation = 0.6
+)
+
+type Options struct {
+	// Predictive pre-warming switch
+	Enable bool
+
+	// Length of one bucket of the arrival histogram
+	BucketSize time.Duration
+
+	// Buckets kept in the sliding window of every function
+	WindowBuckets int
+
+	// Lower bound of the idle timeout chosen for a function
+	MinIdleTimeout time.Duration
+
+	// Upper bound of the idle timeout chosen for a function
+	MaxIdleTimeout time.Duration
+
+	// Max runtimes pre-warmed for one function
+	MaxPrewarm int
+
+	// Min autocorrelation for the traffic of a function to be seen as periodic
+	MinCorrelation float64
+}
+
+func NewOptions() *Options {
+	return &Options{
+		Enable:         false,
+		BucketSize:     defaultBucketSize,
+		WindowBuckets:  defaultWindowBuckets,
+		MinIdleTimeout: defaultMinIdleTimeout,
+		MaxIdleTimeout: defaultMaxIdleTimeout,
+		MaxPrewarm:     defaultMaxPrewarm,
+		MinCorrelation: defaultMinCorrelation,
+	}
+}
+
+func (s *Options) AddFlags(fs *pflag.FlagSet) {
+	fs.BoolVar(&s.Enable, "predictor-enable", s.Enable, "whether to pre-warm runtimes by invocation history")
+	fs.DurationVar(&s.BucketSize, "predictor-bucket-size", s.BucketSize, "length of one bucket of the arrival histogram")
+	fs.IntVar(&s.WindowBuckets, "predictor-window-buckets", s.WindowBuckets, "buckets kept in the sliding window")
+	fs.DurationVar(&s.MinIdleTimeout, "predictor-min-idle-timeout", s.MinIdleTimeout, "min idle timeout chosen for a function")
+	fs.DurationVar(&s.MaxIdleTimeout, "predictor-max-idle-timeout", s.MaxIdleTimeout, "max idle timeout chosen for a function")
+	fs.IntVar(&s.MaxPrewarm, "predictor-max-prewarm", s.MaxPrewarm, "max runtimes pre-warmed for one function")
+	fs.Float64Var(&s.MinCorrelation, "predictor-min-correlation", s.MinCorrelation, "min autocorrelation of periodic traffic")
+}
@@ -0,0 +1,350 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package predictor
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// trendBuckets: recent buckets used to fit the load trend
+	trendBuckets = 6
+	// durationWeight: weight of the latest duration in the moving average
+	durationWeight = 0.2
+	// minArrivals: expected arrivals for a function to deserve a warm runtime
+	minArrivals = 0.5
+)
+
+// Arrival is an invocation observed by the predictor
+type Arrival struct {
+	// Key identifies the runtimes able to serve the function, eg. commit id
+	Key      string
+	Function string
+	// Concurrency is the number of requests a runtime serves at the same time
+	Concurrency int
+	// Cold reports whether the invocation started a cold runtime
+	Cold bool
+	// Time of the arrival, now if it is zero
+	Time time.Time
+	// Payload is kept for the caller to warm up the function
+	Payload interface{}
+}
+
+// Statistics of the predictions of a function
+type Statistics struct {
+	Arrivals int64 `json:"arrivals"`
+	// Hits: warm invocations while the function was predicted to be busy
+	Hits int64 `json:"hits"`
+	// Misses: invocations which had to start a cold runtime
+	Misses    int64 `json:"misses"`
+	Prewarmed int64 `json:"prewarmed"`
+}
+
+// Prediction of the demand of a function in the current and next bucket
+type Prediction struct {
+	Key                string  `json:"key"`
+	Function           string  `json:"function"`
+	Arrivals           float64 `json:"arrivals"`
+	Trend              float64 `json:"trend"`
+	PeriodSeconds      float64 `json:"periodSeconds"`
+	AvgDurationMS      float64 `json:"avgDurationMs"`
+	Runtimes           int     `json:"runtimes"`
+	IdleTimeoutSeconds float64 `json:"idleTimeoutSeconds,omitempty"`
+	Statistics         `json:"statistics"`
+
+	payload     interface{}
+	idleTimeout time.Duration
+}
+
+// Payload returns the payload of the latest arrival of the function
+func (p *Prediction) Payload() interface{} {
+	return p.payload
+}
+
+type function struct {
+	key         string
+	name        string
+	concurrency int
+	payload     interface{}
+	history     *history
+	avgDuration time.Duration
+	lastArrival time.Time
+	stats       Statistics
+	// busy: the latest prediction expects arrivals
+	busy bool
+	// idleTimeout: idle timeout chosen by the latest prediction, 0 means default
+	idleTimeout time.Duration
+}
+
+// Predictor keeps a sliding window arrival histogram per function
+// and predicts the runtimes needed ahead of the demand
+type Predictor struct {
+	opts      *Options
+	lock      sync.Mutex
+	functions map[string]*function
+	now       func() time.Time
+}
+
+func New(o *Options) *Predictor {
+	return &Predictor{
+		opts:      o,
+		functions: make(map[string]*function),
+		now:       time.Now,
+	}
+}
+
+func (p *Predictor) bucket(t time.Time) int64 {
+	return t.UnixNano() / int64(p.opts.BucketSize)
+}
+
+// Record adds an arrival of the function
+func (p *Predictor) Record(a *Arrival) {
+	now := a.Time
+	if now.IsZero() {
+		now = p.now()
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	f, ok := p.functions[a.Key]
+	if !ok {
+		f = &function{
+			key:     a.Key,
+			history: newHistory(p.opts.WindowBuckets, p.bucket(now)),
+		}
+		p.functions[a.Key] = f
+	}
+	f.name = a.Function
+	f.concurrency = a.Concurrency
+	f.payload = a.Payload
+	if now.After(f.lastArrival) {
+		f.lastArrival = now
+	}
+	f.history.add(p.bucket(now))
+	f.stats.Arrivals++
+	if a.Cold {
+		f.stats.Misses++
+	} else if f.busy {
+		f.stats.Hits++
+	}
+}
+
+// Done records the duration of an invocation of the function
+func (p *Predictor) Done(key string, d time.Duration) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	f, ok := p.functions[key]
+	if !ok {
+		return
+	}
+	if f.avgDuration == 0 {
+		f.avgDuration = d
+		return
+	}
+	f.avgDuration = time.Duration(durationWeight*float64(d) + (1-durationWeight)*float64(f.avgDuration))
+}
+
+// AddPrewarmed counts the runtimes warmed for the function
+func (p *Predictor) AddPrewarmed(key string, n int) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if f, ok := p.functions[key]; ok {
+		f.stats.Prewarmed += int64(n)
+	}
+}
+
+// IdleTimeout returns the idle timeout chosen for the function
+func (p *Predictor) IdleTimeout(key string) (time.Duration, bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	f, ok := p.functions[key]
+	if !ok || f.idleTimeout == 0 {
+		return 0, false
+	}
+	return f.idleTimeout, true
+}
+
+// Predictions predicts the demand of all functions without changing the state of the predictor
+func (p *Predictor) Predictions() []*Prediction {
+	now := p.now()
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	predictions := make([]*Prediction, 0, len(p.functions))
+	for _, f := range p.functions {
+		if p.expired(f, now) {
+			continue
+		}
+		predictions = append(predictions, p.predict(f, now))
+	}
+	sortPredictions(predictions)
+	return predictions
+}
+
+// Update predicts the demand of all functions, applies the idle timeouts chosen
+// and forgets the functions idle for the whole window
+func (p *Predictor) Update() []*Prediction {
+	now := p.now()
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	predictions := make([]*Prediction, 0, len(p.functions))
+	for key, f := range p.functions {
+		if p.expired(f, now) {
+			delete(p.functions, key)
+			continue
+		}
+		pr := p.predict(f, now)
+		f.busy = pr.Runtimes > 0
+		f.idleTimeout = pr.idleTimeout
+		predictions = append(predictions, pr)
+	}
+	sortPredictions(predictions)
+	return predictions
+}
+
+func (p *Predictor) expired(f *function, now time.Time) bool {
+	return now.Sub(f.lastArrival) > time.Duration(p.opts.WindowBuckets)*p.opts.BucketSize
+}
+
+func sortPredictions(predictions []*Prediction) {
+	sort.Slice(predictions, func(i, j int) bool {
+		return predictions[i].Key < predictions[j].Key
+	})
+}
+
+func (p *Predictor) predict(f *function, now time.Time) *Prediction {
+	series := f.history.complete(p.bucket(now))
+	trend, slope := fitTrend(series)
+	period := p.findPeriod(series)
+	arrivals := trend
+	if period > 0 {
+		n := len(series)
+		periodic := math.Max(series[n-period], series[n+1-period])
+		arrivals = math.Max(arrivals, periodic)
+	}
+
+	pr := &Prediction{
+		Key:           f.key,
+		Function:      f.name,
+		Arrivals:      arrivals,
+		Trend:         slope,
+		PeriodSeconds: (time.Duration(period) * p.opts.BucketSize).Seconds(),
+		AvgDurationMS: float64(f.avgDuration) / float64(time.Millisecond),
+		Runtimes:      p.runtimes(f, arrivals),
+		Statistics:    f.stats,
+		payload:       f.payload,
+		idleTimeout:   p.chooseIdleTimeout(period, slope, arrivals),
+	}
+	pr.IdleTimeoutSeconds = pr.idleTimeout.Seconds()
+	return pr
+}
+
+// runtimes estimates the busy runtimes by Little's law
+func (p *Predictor) runtimes(f *function, arrivals float64) int {
+	if arrivals < minArrivals {
+		return 0
+	}
+	concurrency := f.concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	busy := arrivals / p.opts.BucketSize.Seconds() * f.avgDuration.Seconds()
+	n := int(math.Ceil(busy / float64(concurrency)))
+	if n < 1 {
+		n = 1
+	}
+	if n > p.opts.MaxPrewarm {
+		n = p.opts.MaxPrewarm
+	}
+	return n
+}
+
+// chooseIdleTimeout keeps runtimes warm across the gaps of periodic traffic
+// and cools them down early when the next burst will be pre-warmed anyway
+func (p *Predictor) chooseIdleTimeout(period int, slope, arrivals float64) time.Duration {
+	switch {
+	case period > 0:
+		idle := time.Duration(period+1) * p.opts.BucketSize
+		if idle > p.opts.MaxIdleTimeout {
+			return p.opts.MaxIdleTimeout
+		}
+		if idle < p.opts.MinIdleTimeout {
+			return p.opts.MinIdleTimeout
+		}
+		return idle
+	case slope < 0 && arrivals < minArrivals:
+		return p.opts.MinIdleTimeout
+	}
+	return 0
+}
+
+// fitTrend fits the recent buckets with least squares
+// and returns the highest arrivals expected in the current and next bucket
+func fitTrend(series []float64) (arrivals, slope float64) {
+	k := trendBuckets
+	if k > len(series) {
+		k = len(series)
+	}
+	recent := series[len(series)-k:]
+	var sumX, sumY, sumXY, sumXX float64
+	for i, y := range recent {
+		x := float64(i)
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+	n := float64(k)
+	denominator := n*sumXX - sumX*sumX
+	if denominator != 0 {
+		slope = (n*sumXY - sumX*sumY) / denominator
+	}
+	intercept := (sumY - slope*sumX) / n
+	arrivals = math.Max(intercept+slope*n, intercept+slope*(n+1))
+	return math.Max(arrivals, 0), slope
+}
+
+// findPeriod returns the lag in buckets with the highest autocorrelation, 0 if the traffic is not periodic
+func (p *Predictor) findPeriod(series []float64) int {
+	n := len(series)
+	var mean float64
+	for _, v := range series {
+		mean += v
+	}
+	mean /= float64(n)
+	var variance float64
+	for _, v := range series {
+		variance += (v - mean) * (v - mean)
+	}
+	if variance == 0 {
+		return 0
+	}
+	best, bestCorrelation := 0, p.opts.MinCorrelation
+	for lag := 2; lag <= n/2; lag++ {
+		var cov float64
+		for i := lag; i < n; i++ {
+			cov += (series[i] - mean) * (series[i-lag] - mean)
+		}
+		// normalize by the overlapping length so that long lags are not penalized
+		correlation := cov / variance * float64(n) / float64(n-lag)
+		if correlation > bestCorrelation {
+			best, bestCorrelation = lag, correlation
+		}
+	}
+	return best
+}
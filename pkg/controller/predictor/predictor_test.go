@@ -0,0 +1,138 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package predictor
+
+import (
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	return c.t
+}
+
+func newTestPredictor() (*Predictor, *fakeClock) {
+	o := NewOptions()
+	o.WindowBuckets = 60
+	p := New(o)
+	clock := &fakeClock{t: time.Unix(1600000000, 0)}
+	p.now = clock.now
+	return p, clock
+}
+
+func TestPredictPeriodicTraffic(t *testing.T) {
+	p, clock := newTestPredictor()
+	// one invocation every minute
+	for i := 0; i < 54; i++ {
+		if i%6 == 0 {
+			p.Record(&Arrival{Key: "commit-cron", Function: "cron", Concurrency: 1})
+			p.Done("commit-cron", 100*time.Millisecond)
+		}
+		clock.t = clock.t.Add(p.opts.BucketSize)
+	}
+	// the next invocation is due in the bucket after the current one
+	clock.t = clock.t.Add(-p.opts.BucketSize)
+	predictions := p.Update()
+	if len(predictions) != 1 {
+		t.Fatalf("expect 1 prediction, got %d", len(predictions))
+	}
+	pr := predictions[0]
+	if pr.PeriodSeconds != 60 {
+		t.Errorf("expect period 60s, got %v", pr.PeriodSeconds)
+	}
+	if pr.Runtimes != 1 {
+		t.Errorf("expect 1 runtime, got %+v", pr)
+	}
+	if idle, ok := p.IdleTimeout("commit-cron"); !ok || idle != 70*time.Second {
+		t.Errorf("unexpected idle timeout %s", idle)
+	}
+}
+
+func TestPredictRampingTraffic(t *testing.T) {
+	p, clock := newTestPredictor()
+	for i := 0; i < 10; i++ {
+		for j := 0; j < i*10; j++ {
+			p.Record(&Arrival{Key: "commit-ramp", Function: "ramp", Concurrency: 2})
+		}
+		clock.t = clock.t.Add(p.opts.BucketSize)
+	}
+	p.Done("commit-ramp", time.Second)
+	pr := p.Predictions()[0]
+	if pr.Trend < 9 || pr.Arrivals < 100 {
+		t.Errorf("expect rising arrivals, got %+v", pr)
+	}
+	// 110 arrivals in 10s lasting 1s need 11 busy slots, capped by max prewarm
+	if pr.Runtimes != p.opts.MaxPrewarm {
+		t.Errorf("expect %d runtimes, got %d", p.opts.MaxPrewarm, pr.Runtimes)
+	}
+}
+
+func TestPredictorStatistics(t *testing.T) {
+	p, clock := newTestPredictor()
+	p.Record(&Arrival{Key: "commit-1", Cold: true})
+	clock.t = clock.t.Add(p.opts.BucketSize)
+	p.Update()
+	p.Record(&Arrival{Key: "commit-1"})
+	p.AddPrewarmed("commit-1", 1)
+	pr := p.Predictions()[0]
+	expect := Statistics{Arrivals: 2, Hits: 1, Misses: 1, Prewarmed: 1}
+	if pr.Statistics != expect {
+		t.Errorf("expect statistics %+v, got %+v", expect, pr.Statistics)
+	}
+
+	// functions idle for the whole window are forgotten by the update only
+	clock.t = clock.t.Add(time.Duration(p.opts.WindowBuckets+1) * p.opts.BucketSize)
+	if len(p.Predictions()) != 0 || len(p.functions) != 1 {
+		t.Error("expect no prediction")
+	}
+	if len(p.Update()) != 0 || len(p.functions) != 0 {
+		t.Error("expect idle function forgotten")
+	}
+}
+
+func TestChooseIdleTimeout(t *testing.T) {
+	p, _ := newTestPredictor()
+	p.opts.MinIdleTimeout = 30 * time.Second
+	p.opts.MaxIdleTimeout = time.Minute
+	if idle := p.chooseIdleTimeout(20, 0, 1); idle != time.Minute {
+		t.Errorf("expect idle timeout clamped to max, got %s", idle)
+	}
+	if idle := p.chooseIdleTimeout(1, 0, 1); idle != 30*time.Second {
+		t.Errorf("expect idle timeout clamped to min, got %s", idle)
+	}
+}
+
+func TestHistoryWindow(t *testing.T) {
+	h := newHistory(4, 10)
+	h.add(10)
+	h.add(11)
+	h.add(11)
+	series := h.complete(12)
+	if series[0] != 0 || series[1] != 1 || series[2] != 2 {
+		t.Errorf("unexpected series %v", series)
+	}
+	series = h.complete(20)
+	for _, v := range series {
+		if v != 0 {
+			t.Errorf("expect cleared series, got %v", series)
+		}
+	}
+}
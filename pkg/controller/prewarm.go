@@ -0,0 +1,95 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"time"
+
+	"github.com/baidu/easyfaas/pkg/api"
+	"github.com/baidu/easyfaas/pkg/controller/predictor"
+	"github.com/baidu/easyfaas/pkg/controller/rtctrl"
+	"github.com/baidu/easyfaas/pkg/util/id"
+	"github.com/baidu/easyfaas/pkg/util/logs"
+)
+
+// prewarmTarget: what is needed to warm up a function without invocation
+type prewarmTarget struct {
+	function *api.GetFunctionOutput
+	runtime  *api.RuntimeConfiguration
+}
+
+func (controller *Controller) initPredictor(o *predictor.Options) {
+	if !o.Enable {
+		return
+	}
+	controller.predictor = predictor.New(o)
+	controller.runtimeDispatcher.SetIdleTimeoutPolicy(controller.predictor.IdleTimeout)
+}
+
+// observeInvocation feeds the invocation to the predictor
+func (controller *Controller) observeInvocation(ctx *InvokeContext, start time.Time) {
+	if controller.predictor == nil || ctx.Function == nil || ctx.Function.Configuration == nil ||
+		ctx.Function.Configuration.CommitID == nil {
+		return
+	}
+	commitID := *ctx.Function.Configuration.CommitID
+	controller.predictor.Record(&predictor.Arrival{
+		Key:         commitID,
+		Function:    ctx.FunctionBRN,
+		Concurrency: int(ctx.Function.Configuration.PodConcurrentQuota),
+		Cold:        ctx.runtimeVia == api.RuntimeViaCold,
+		Time:        start,
+		Payload:     &prewarmTarget{function: ctx.Function, runtime: ctx.Runtime},
+	})
+	if ctx.runtimeVia == api.RuntimeViaWarm || ctx.runtimeVia == api.RuntimeViaCold {
+		controller.predictor.Done(commitID, time.Since(start))
+	}
+}
+
+// prewarmTask warms runtimes ahead of the predicted demand
+func (controller *Controller) prewarmTask(logger *logs.Logger) {
+	if controller.predictor == nil {
+		return
+	}
+	alive := make(map[string]int)
+	for _, rt := range controller.runtimeDispatcher.RuntimeList() {
+		if commitID, ok, _ := rt.AliveState(); ok {
+			alive[commitID]++
+		}
+	}
+	for _, prediction := range controller.predictor.Update() {
+		target, ok := prediction.Payload().(*prewarmTarget)
+		if !ok || target.runtime == nil {
+			continue
+		}
+		var warmed int
+		for n := prediction.Runtimes - alive[prediction.Key]; warmed < n; warmed++ {
+			rt, err := controller.warmUpRuntime(target.function, target.runtime, rtctrl.PriorityLow, id.GetRequestID(), logger)
+			if err != nil {
+				logger.Warnf("pre-warm function %s failed: %s", prediction.Function, err)
+				break
+			}
+			if err := rt.Release(); err != nil {
+				logger.Errorf("release runtime %s failed: %s", rt.RuntimeID, err)
+			}
+		}
+		if warmed > 0 {
+			logger.Infof("pre-warm %d runtimes of function %s for %.1f arrivals", warmed, prediction.Function, prediction.Arrivals)
+			controller.predictor.AddPrewarmed(prediction.Key, warmed)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/baidu/easyfaas/pkg/api"
+	"github.com/baidu/easyfaas/pkg/controller/predictor"
+	"github.com/baidu/easyfaas/pkg/util/logs"
+)
+
+func TestPrewarmTask(t *testing.T) {
+	functionBRN := "brn:cloud:faas:bj:8f6e6a4c4aa0f6a5a4d3fe2d3d3c5e39:function:hello:1"
+	controller, funclet := newTestController(3, &fakeDataStorer{})
+	o := predictor.NewOptions()
+	o.Enable = true
+	controller.initPredictor(o)
+
+	// steady load of one request per second in the recent buckets
+	ctx := &InvokeContext{
+		FunctionBRN: functionBRN,
+		Function:    testFunction(functionBRN, "commit-1"),
+		Runtime:     &api.RuntimeConfiguration{Name: "nodejs12"},
+	}
+	now := time.Now()
+	for i := 60; i > 0; i-- {
+		controller.observeInvocation(ctx, now.Add(-time.Duration(i)*time.Second))
+	}
+	controller.predictor.Done("commit-1", 500*time.Millisecond)
+
+	controller.prewarmTask(logs.NewLogger())
+	if len(funclet.warmUps) != 1 {
+		t.Fatalf("expect 1 pre-warmed runtime, got %d", len(funclet.warmUps))
+	}
+	rt, _ := controller.runtimeDispatcher.GetRuntime(funclet.warmUps[0])
+	if rt.CommitID != "commit-1" || rt.Concurrency != 0 {
+		t.Errorf("unexpected pre-warmed runtime %+v", rt)
+	}
+
+	// the pre-warmed runtime meets the demand
+	ctx.runtimeVia = api.RuntimeViaWarm
+	controller.observeInvocation(ctx, time.Now())
+	controller.prewarmTask(logs.NewLogger())
+	if len(funclet.warmUps) != 1 {
+		t.Errorf("expect no more pre-warm, got %d", len(funclet.warmUps))
+	}
+	predictions := controller.predictor.Predictions()
+	if len(predictions) != 1 || predictions[0].Prewarmed != 1 || predictions[0].Hits == 0 {
+		t.Errorf("unexpected predictions %+v", predictions)
+	}
+}
+
+func TestPrewarmTaskBreakerOpen(t *testing.T) {
+	functionBRN := "brn:cloud:faas:bj:8f6e6a4c4aa0f6a5a4d3fe2d3d3c5e39:function:hello:1"
+	controller, funclet := newTestController(3, &fakeDataStorer{})
+	controller.breaker = newWarmUpBreaker(1, time.Minute)
+	o := predictor.NewOptions()
+	o.Enable = true
+	controller.initPredictor(o)
+
+	ctx := &InvokeContext{
+		FunctionBRN: functionBRN,
+		Function:    testFunction(functionBRN, "commit-1"),
+		Runtime:     &api.RuntimeConfiguration{Name: "nodejs12"},
+	}
+	now := time.Now()
+	for i := 60; i > 0; i-- {
+		controller.observeInvocation(ctx, now.Add(-time.Duration(i)*time.Second))
+	}
+	controller.predictor.Done("commit-1", 500*time.Millisecond)
+
+	// functions failing to warm up are not pre-warmed
+	controller.breaker.failure("commit-1", functionBRN, fmt.Errorf("init failed"))
+	controller.prewarmTask(logs.NewLogger())
+	if len(funclet.warmUps) != 0 {
+		t.Errorf("expect no pre-warm while the breaker is open, got %v", funclet.warmUps)
+	}
+}
@@ -0,0 +1,92 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/baidu/easyfaas/pkg/api"
+	"github.com/baidu/easyfaas/pkg/controller/rtctrl"
+	innerErr "github.com/baidu/easyfaas/pkg/error"
+)
+
+// invocationPriority: the priority of the function configuration first, then by invoke type and trigger type;
+// synchronous http traffic is high, bulk event and message traffic is low.
+// The priority header comes from any caller, it is only honored to lower the priority
+func invocationPriority(ctx *InvokeContext) rtctrl.Priority {
+	priority := defaultPriority(ctx)
+	if ctx.Function != nil && ctx.Function.Configuration != nil && ctx.Function.Configuration.Priority != "" {
+		if p, ok := rtctrl.ParsePriority(strings.ToLower(ctx.Function.Configuration.Priority)); ok {
+			priority = p
+		}
+	}
+	if ctx.Request != nil {
+		for k, v := range ctx.Request.Headers {
+			if !strings.EqualFold(k, api.HeaderPriority) {
+				continue
+			}
+			if p, ok := rtctrl.ParsePriority(strings.ToLower(v)); ok && p < priority {
+				priority = p
+			}
+		}
+	}
+	return priority
+}
+
+func defaultPriority(ctx *InvokeContext) rtctrl.Priority {
+	switch ctx.InvokeType {
+	case api.InvokeTypeEvent, api.InvokeTypeMqhub:
+		return rtctrl.PriorityLow
+	case api.InvokeTypeHttpTrigger, api.InvokeTypeStream:
+		return rtctrl.PriorityHigh
+	}
+	switch ctx.TriggerType {
+	case api.TriggerTypeCrontab, api.TriggerTypeKafka:
+		return rtctrl.PriorityLow
+	case api.TriggerTypeHTTP:
+		return rtctrl.PriorityHigh
+	}
+	return rtctrl.PriorityNormal
+}
+
+// checkPriorityReservedShare: the lowest class leaves one share for every class above it,
+// so that the shares must leave some runtimes to it
+func checkPriorityReservedShare(share float64) error {
+	levels := float64(rtctrl.PriorityHigh - rtctrl.PriorityLow)
+	if share < 0 || share >= 1/levels {
+		return fmt.Errorf("priority reserved share %v is out of range [0, %v)", share, 1/levels)
+	}
+	return nil
+}
+
+// admitPriority keeps a share of the runtimes for every class above the invocation's one:
+// normal invocations leave one share of cold runtimes, low invocations leave two
+func (controller *Controller) admitPriority(priority rtctrl.Priority, cold int) error {
+	share := controller.runOptions.PriorityReservedShare
+	if share <= 0 || priority >= rtctrl.PriorityHigh {
+		return nil
+	}
+	total := controller.runtimeDispatcher.RuntimeCount()
+	reserved := int(math.Ceil(share*float64(total))) * int(rtctrl.PriorityHigh-priority)
+	if cold > reserved {
+		return nil
+	}
+	return innerErr.NewTooManyRequestsException(
+		fmt.Sprintf("%d cold runtimes are reserved for invocations above %s priority", reserved, priority), nil)
+}
@@ -0,0 +1,95 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/baidu/easyfaas/pkg/api"
+	"github.com/baidu/easyfaas/pkg/controller/rtctrl"
+)
+
+func TestInvocationPriority(t *testing.T) {
+	cases := []struct {
+		ctx  *InvokeContext
+		want rtctrl.Priority
+	}{
+		{&InvokeContext{InvokeType: string(api.InvokeTypeCommon)}, rtctrl.PriorityNormal},
+		{&InvokeContext{InvokeType: api.InvokeTypeEvent}, rtctrl.PriorityLow},
+		{&InvokeContext{InvokeType: api.InvokeTypeHttpTrigger}, rtctrl.PriorityHigh},
+		{&InvokeContext{InvokeType: string(api.InvokeTypeCommon), TriggerType: api.TriggerTypeCrontab}, rtctrl.PriorityLow},
+		// callers can not raise the priority with the header, only lower it
+		{&InvokeContext{
+			InvokeType: api.InvokeTypeEvent,
+			Request:    &api.InvokeProxyRequest{Headers: map[string]string{"x-easyfaas-priority": "High"}},
+		}, rtctrl.PriorityLow},
+		{&InvokeContext{
+			InvokeType: api.InvokeTypeHttpTrigger,
+			Request:    &api.InvokeProxyRequest{Headers: map[string]string{"x-easyfaas-priority": "low"}},
+		}, rtctrl.PriorityLow},
+		{&InvokeContext{
+			InvokeType: api.InvokeTypeEvent,
+			Function: &api.GetFunctionOutput{
+				Configuration: &api.FunctionConfiguration{Priority: "High"},
+			},
+			Request: &api.InvokeProxyRequest{Headers: map[string]string{api.HeaderPriority: "normal"}},
+		}, rtctrl.PriorityNormal},
+		{&InvokeContext{
+			InvokeType: api.InvokeTypeEvent,
+			Request:    &api.InvokeProxyRequest{Headers: map[string]string{api.HeaderPriority: "urgent"}},
+		}, rtctrl.PriorityLow},
+	}
+	for i, c := range cases {
+		if got := invocationPriority(c.ctx); got != c.want {
+			t.Errorf("case %d: priority %s, want %s", i, got, c.want)
+		}
+	}
+}
+
+func TestAdmitPriority(t *testing.T) {
+	controller, _ := newTestController(10, &fakeDataStorer{})
+	if err := controller.admitPriority(rtctrl.PriorityLow, 1); err != nil {
+		t.Errorf("nothing is reserved by default: %v", err)
+	}
+
+	controller.runOptions.PriorityReservedShare = 0.2
+	cases := []struct {
+		priority rtctrl.Priority
+		cold     int
+		admitted bool
+	}{
+		{rtctrl.PriorityHigh, 1, true},
+		{rtctrl.PriorityNormal, 2, false},
+		{rtctrl.PriorityNormal, 3, true},
+		{rtctrl.PriorityLow, 4, false},
+		{rtctrl.PriorityLow, 5, true},
+	}
+	for _, c := range cases {
+		err := controller.admitPriority(c.priority, c.cold)
+		if (err == nil) != c.admitted {
+			t.Errorf("priority %s with %d cold runtimes: admitted %v, want %v", c.priority, c.cold, err == nil, c.admitted)
+		}
+	}
+}
+
+func TestCheckPriorityReservedShare(t *testing.T) {
+	for share, valid := range map[float64]bool{0: true, 0.2: true, 0.49: true, 0.5: false, 1: false, -0.1: false} {
+		if err := checkPriorityReservedShare(share); (err == nil) != valid {
+			t.Errorf("share %v: valid %v, want %v", share, err == nil, valid)
+		}
+	}
+}
@@ -0,0 +1,323 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/baidu/easyfaas/pkg/api"
+	"github.com/baidu/easyfaas/pkg/brn"
+	"github.com/baidu/easyfaas/pkg/controller/rtctrl"
+	innerErr "github.com/baidu/easyfaas/pkg/error"
+	"github.com/baidu/easyfaas/pkg/util/id"
+	"github.com/baidu/easyfaas/pkg/util/logs"
+)
+
+// provisionedTarget: provisioned concurrency declared for a function version
+type provisionedTarget struct {
+	FunctionBRN string
+	Qualifier   string
+	AccountID   string
+	Target      int
+
+	available int
+	status    api.ProvisionedConcurrencyStatus
+	reason    string
+}
+
+func (t *provisionedTarget) output() *api.ProvisionedConcurrencyOutput {
+	return &api.ProvisionedConcurrencyOutput{
+		FunctionBrn:                              t.FunctionBRN,
+		RequestedProvisionedConcurrentExecutions: t.Target,
+		AvailableProvisionedConcurrentExecutions: t.available,
+		Status:                                   t.status,
+		StatusReason:                             t.reason,
+	}
+}
+
+type provisioner struct {
+	lock    sync.RWMutex
+	targets map[string]*provisionedTarget
+}
+
+func newProvisioner() *provisioner {
+	return &provisioner{
+		targets: make(map[string]*provisionedTarget),
+	}
+}
+
+func (p *provisioner) put(t *provisionedTarget) *api.ProvisionedConcurrencyOutput {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if old, ok := p.targets[t.FunctionBRN]; ok {
+		t.available = old.available
+	}
+	t.status = api.ProvisionedConcurrencyInProgress
+	p.targets[t.FunctionBRN] = t
+	return t.output()
+}
+
+func (p *provisioner) get(functionBRN string) (*api.ProvisionedConcurrencyOutput, bool) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	t, ok := p.targets[functionBRN]
+	if !ok {
+		return nil, false
+	}
+	return t.output(), true
+}
+
+func (p *provisioner) delete(functionBRN string) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	_, ok := p.targets[functionBRN]
+	delete(p.targets, functionBRN)
+	return ok
+}
+
+func (p *provisioner) list() []*provisionedTarget {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	targets := make([]*provisionedTarget, 0, len(p.targets))
+	for _, t := range p.targets {
+		c := *t
+		targets = append(targets, &c)
+	}
+	return targets
+}
+
+func (p *provisioner) setStatus(functionBRN string, available int, err error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	t, ok := p.targets[functionBRN]
+	if !ok {
+		return
+	}
+	t.available = available
+	t.reason = ""
+	switch {
+	case err != nil:
+		t.status = api.ProvisionedConcurrencyFailed
+		t.reason = err.Error()
+	case available >= t.Target:
+		t.status = api.ProvisionedConcurrencyReady
+	default:
+		t.status = api.ProvisionedConcurrencyInProgress
+	}
+}
+
+// parseProvisionedBrn only accepts the brn of a published function version
+func parseProvisionedBrn(functionBRN string) (brn.FunctionBRN, error) {
+	fb, err := brn.ParseFunction(functionBRN)
+	if err != nil {
+		return fb, innerErr.NewInvalidParameterValueException("invalid function brn", err)
+	}
+	if fb.Version == "" || fb.Version == "$LATEST" {
+		return fb, innerErr.NewInvalidParameterValueException("provisioned concurrency requires a published version", nil)
+	}
+	return fb, nil
+}
+
+// checkProvisioned only lets the owner of the function provision it,
+// and no more runtimes than the pool or the reserved concurrency of the account
+func (controller *Controller) checkProvisioned(callerID string, fb brn.FunctionBRN, target int) error {
+	if callerID != fb.AccountID {
+		return innerErr.NewInvalidInvokeCallerException(fmt.Sprintf("owner id %s caller id %s", fb.AccountID, callerID), nil)
+	}
+	if target < 1 {
+		return innerErr.NewInvalidParameterValueException("ProvisionedConcurrentExecutions should be positive", nil)
+	}
+	limit := int64(controller.runtimeDispatcher.RuntimeCount())
+	if reserved := controller.concurrency.accountReserved(fb.AccountID); reserved > 0 && reserved < limit {
+		limit = reserved
+	}
+	if int64(target) > limit {
+		return innerErr.NewInvalidParameterValueException(fmt.Sprintf("ProvisionedConcurrentExecutions should not exceed %d", limit), nil)
+	}
+	return nil
+}
+
+// provisionTask warms runtimes up to the provisioned targets
+// and returns the runtimes beyond the targets to the idle cool down
+func (controller *Controller) provisionTask(logger *logs.Logger) {
+	targets := controller.provisioner.list()
+	provisionedCommits := make(map[string]bool, len(targets))
+	for _, t := range targets {
+		commitID, available, err := controller.reconcileProvisioned(t, logger)
+		if commitID != "" {
+			provisionedCommits[commitID] = true
+		}
+		if err != nil {
+			logger.Errorf("provision function %s failed: %s", t.FunctionBRN, err)
+		}
+		controller.provisioner.setStatus(t.FunctionBRN, available, err)
+	}
+	for _, rt := range controller.runtimeDispatcher.RuntimeList() {
+		if commitID, _, provisioned := rt.AliveState(); provisioned && !provisionedCommits[commitID] {
+			rt.SetProvisioned(false)
+		}
+	}
+}
+
+func (controller *Controller) reconcileProvisioned(t *provisionedTarget, logger *logs.Logger) (commitID string, available int, err error) {
+	requestID := id.GetRequestID()
+	// the tasks run long after the request, the inside data storer signs them with the service credentials
+	input := api.GetFunctionInput{
+		RequestID:  requestID,
+		AccountID:  t.AccountID,
+		WithCache:  true,
+		SimpleAuth: true,
+	}
+	input.SetFunctionName(t.FunctionBRN).SetQualifier(t.Qualifier)
+	function, _, err := controller.insideDataStorer.GetFunction(&input)
+	if err != nil {
+		return "", 0, err
+	}
+	if function.Configuration == nil || function.Configuration.CommitID == nil || function.Configuration.Runtime == nil {
+		return "", 0, fmt.Errorf("function configuration is incomplete")
+	}
+	commitID = *function.Configuration.CommitID
+	runtimeConf, _, err := controller.insideDataStorer.GetRuntimeConfiguration(&api.GetRuntimeConfigurationInput{
+		RuntimeName: *function.Configuration.Runtime,
+		RequestID:   requestID,
+	})
+	if err != nil {
+		return commitID, 0, err
+	}
+
+	// count the provisioned runtimes and adopt idle warm ones of the version
+	var provisioned, adoptable []*rtctrl.RuntimeInfo
+	for _, rt := range controller.runtimeDispatcher.RuntimeList() {
+		rtCommitID, alive, isProvisioned := rt.AliveState()
+		if rtCommitID != commitID || !alive {
+			continue
+		}
+		if isProvisioned {
+			provisioned = append(provisioned, rt)
+		} else {
+			adoptable = append(adoptable, rt)
+		}
+	}
+	for len(provisioned) > t.Target {
+		last := provisioned[len(provisioned)-1]
+		last.SetProvisioned(false)
+		provisioned = provisioned[:len(provisioned)-1]
+	}
+	for _, rt := range adoptable {
+		if len(provisioned) >= t.Target {
+			break
+		}
+		// the runtime may be stopped or taken by another function since it was listed
+		if rt.Provision(commitID) {
+			provisioned = append(provisioned, rt)
+		}
+	}
+
+	for len(provisioned) < t.Target {
+		rt, err := controller.provisionRuntime(function, runtimeConf, requestID, logger)
+		if err != nil {
+			return commitID, len(provisioned), err
+		}
+		provisioned = append(provisioned, rt)
+	}
+	return commitID, len(provisioned), nil
+}
+
+// provisionRuntime warms up a runtime and keeps it provisioned
+func (controller *Controller) provisionRuntime(function *api.GetFunctionOutput, runtimeConf *api.RuntimeConfiguration,
+	requestID string, logger *logs.Logger) (*rtctrl.RuntimeInfo, error) {
+	rt, err := controller.warmUpRuntime(function, runtimeConf, rtctrl.PriorityNormal, requestID, logger)
+	if err != nil {
+		return nil, err
+	}
+	rt.SetProvisioned(true)
+	if err := rt.Release(); err != nil {
+		logger.Errorf("release runtime %s failed: %s", rt.RuntimeID, err)
+	}
+	return rt, nil
+}
+
+// admitWarmUp applies the admission checks and the breaker of cold starts to a runtime warmed up without invocation
+func (controller *Controller) admitWarmUp(function *api.GetFunctionOutput, priority rtctrl.Priority) error {
+	cold := controller.runtimeDispatcher.ColdRuntimeCount()
+	if err := controller.concurrency.admitCold(concurrencyKey(&InvokeContext{Function: function}), cold, controller.idleWarmRuntimes); err != nil {
+		return err
+	}
+	if err := controller.admitPriority(priority, cold); err != nil {
+		return err
+	}
+	if _, err := controller.breaker.allow(*function.Configuration.CommitID); err != nil {
+		return innerErr.NewServiceUnavailableException(err.Error(), nil)
+	}
+	return nil
+}
+
+// warmUpRuntime occupies a cold runtime and warms it up without invocation,
+// the runtime is still occupied when it returns
+func (controller *Controller) warmUpRuntime(function *api.GetFunctionOutput, runtimeConf *api.RuntimeConfiguration,
+	priority rtctrl.Priority, requestID string, logger *logs.Logger) (*rtctrl.RuntimeInfo, error) {
+	if err := controller.admitWarmUp(function, priority); err != nil {
+		return nil, err
+	}
+	commitID := *function.Configuration.CommitID
+	// no invocation tells whether the function inits, the probe of the breaker is given back
+	defer controller.breaker.cancel(commitID)
+	streamMode := strings.HasSuffix(runtimeConf.Name, "stream")
+	input := &rtctrl.InvocationInput{
+		RequestID:      requestID,
+		Code:           function.Code,
+		Configuration:  function.Configuration,
+		WithStreamMode: streamMode,
+		Logger:         logger,
+	}
+	rt, recommendation := controller.runtimeDispatcher.OccupyColdRuntime(input)
+	if rt == nil {
+		return nil, innerErr.NewTooManyRequestsException("empty runtime", nil)
+	}
+	warmUpInput := &api.FuncletClientWarmUpInput{
+		ContainerID:          rt.RuntimeID,
+		RequestID:            requestID,
+		Code:                 function.Code,
+		Configuration:        function.Configuration,
+		RuntimeConfiguration: runtimeConf,
+		WithStreamMode:       streamMode,
+		RuntimeAPIToken:      rt.NewRuntimeAPIToken(),
+	}
+	if recommendation != nil {
+		warmUpInput.NeedScaleUp = true
+		warmUpInput.ScaleUpRecommendation = recommendation
+	}
+	logger.Infof("warm up container %s commit id %s", rt.RuntimeID, commitID)
+	if _, err := controller.FuncletClient.WarmUp(warmUpInput); err != nil {
+		functionBRN := ""
+		if function.Configuration.FunctionArn != nil {
+			functionBRN = *function.Configuration.FunctionArn
+		}
+		controller.breaker.failure(commitID, functionBRN, err)
+		rt.Invalidate()
+		if err := rt.Release(); err != nil {
+			logger.Errorf("release runtime %s failed: %s", rt.RuntimeID, err)
+		}
+		return nil, fmt.Errorf("warm up runtime %s failed: %s", rt.RuntimeID, err.Error())
+	}
+	if function.Configuration.PodConcurrentQuota == 0 {
+		rt.ConcurrentMode = false
+	}
+	return rt, nil
+}
@@ -0,0 +1,115 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/baidu/easyfaas/pkg/api"
+	"github.com/baidu/easyfaas/pkg/controller/rtctrl"
+	"github.com/baidu/easyfaas/pkg/util/logs"
+)
+
+func TestProvisionTask(t *testing.T) {
+	functionBRN := "brn:cloud:faas:bj:8f6e6a4c4aa0f6a5a4d3fe2d3d3c5e39:function:hello:1"
+	storer := &fakeDataStorer{functions: map[string]*api.GetFunctionOutput{
+		functionBRN: testFunction(functionBRN, "commit-1"),
+	}}
+	controller, funclet := newTestController(3, storer)
+	logger := logs.NewLogger()
+
+	if _, err := parseProvisionedBrn(functionBRN); err != nil {
+		t.Fatal(err)
+	}
+	controller.provisioner.put(&provisionedTarget{FunctionBRN: functionBRN, Qualifier: "1", Target: 2})
+	controller.provisionTask(logger)
+
+	output, _ := controller.provisioner.get(functionBRN)
+	if output.Status != api.ProvisionedConcurrencyReady || output.AvailableProvisionedConcurrentExecutions != 2 {
+		t.Fatalf("unexpected provisioned status %+v", output)
+	}
+	if len(funclet.warmUps) != 2 {
+		t.Fatalf("expect 2 warm ups, got %d", len(funclet.warmUps))
+	}
+	var provisioned int
+	for _, rt := range controller.runtimeDispatcher.RuntimeList() {
+		if rt.Provisioned {
+			provisioned++
+			if rt.Concurrency != 0 || rt.CommitID != "commit-1" {
+				t.Errorf("unexpected provisioned runtime %+v", rt)
+			}
+			rt.SetState(rtctrl.RuntimeStateWarm)
+			rt.LastAccessTime = time.Time{}
+			if _, err := controller.runtimeDispatcher.CoolDownRuntime(rt); err == nil {
+				t.Errorf("provisioned runtime %s should not cool down", rt.RuntimeID)
+			}
+		}
+	}
+	if provisioned != 2 {
+		t.Fatalf("expect 2 provisioned runtimes, got %d", provisioned)
+	}
+
+	// nothing to do when the target is met
+	controller.provisionTask(logger)
+	if len(funclet.warmUps) != 2 {
+		t.Fatalf("expect no more warm up, got %d", len(funclet.warmUps))
+	}
+
+	// runtimes return to idle cool down after the target is deleted
+	controller.provisioner.delete(functionBRN)
+	controller.provisionTask(logger)
+	for _, rt := range controller.runtimeDispatcher.RuntimeList() {
+		if rt.Provisioned {
+			t.Errorf("runtime %s should not be provisioned", rt.RuntimeID)
+		}
+	}
+}
+
+func TestParseProvisionedBrn(t *testing.T) {
+	invalid := []string{
+		"hello",
+		"brn:cloud:faas:bj:8f6e6a4c4aa0f6a5a4d3fe2d3d3c5e39:function:hello",
+		"brn:cloud:faas:bj:8f6e6a4c4aa0f6a5a4d3fe2d3d3c5e39:function:hello:$LATEST",
+	}
+	for _, b := range invalid {
+		if _, err := parseProvisionedBrn(b); err == nil {
+			t.Errorf("brn %s should be invalid", b)
+		}
+	}
+}
+
+func TestCheckProvisioned(t *testing.T) {
+	controller, _ := newTestController(3, &fakeDataStorer{})
+	fb, err := parseProvisionedBrn("brn:cloud:faas:bj:8f6e:function:hello:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := controller.checkProvisioned("8f6e", fb, 3); err != nil {
+		t.Errorf("expect the pool size allowed: %s", err)
+	}
+	if err := controller.checkProvisioned("other", fb, 1); err == nil {
+		t.Errorf("expect the function of another account rejected")
+	}
+	if err := controller.checkProvisioned("8f6e", fb, 4); err == nil {
+		t.Errorf("expect a target beyond the pool rejected")
+	}
+	controller.concurrency.reserveAccount("8f6e", 2)
+	if err := controller.checkProvisioned("8f6e", fb, 3); err == nil {
+		t.Errorf("expect a target beyond the reserved concurrency of the account rejected")
+	}
+}
@@ -0,0 +1,76 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/baidu/easyfaas/pkg/controller/ratelimit"
+	innerErr "github.com/baidu/easyfaas/pkg/error"
+	"github.com/baidu/easyfaas/pkg/util/logs"
+)
+
+const headerRetryAfter = "Retry-After"
+
+func (controller *Controller) initRateLimiter(o *ratelimit.Options) (err error) {
+	controller.rateLimiter, err = ratelimit.New(o)
+	return err
+}
+
+// checkAccountRateLimit throttles the invocation by the rate limit of its account,
+// it runs before the function metadata is fetched so throttled callers cost no lookups
+func (controller *Controller) checkAccountRateLimit(ctx *InvokeContext) error {
+	return controller.checkRateLimit(ctx, ctx.AccountID, "")
+}
+
+// checkFunctionRateLimit throttles the invocation by the rate limit of its function
+func (controller *Controller) checkFunctionRateLimit(ctx *InvokeContext) error {
+	return controller.checkRateLimit(ctx, "", concurrencyKey(ctx))
+}
+
+func (controller *Controller) checkRateLimit(ctx *InvokeContext, account, function string) error {
+	if controller.rateLimiter == nil {
+		return nil
+	}
+	ok, scope, retryAfter := controller.rateLimiter.Allow(account, function)
+	if ok {
+		return nil
+	}
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	ctx.Response.SetHeader(headerRetryAfter, strconv.Itoa(seconds))
+	return innerErr.NewTooManyRequestsException(fmt.Sprintf("%s rate limit exceeded", scope), nil)
+}
+
+// rateLimitTask reloads the rate limit config when it is modified
+func (controller *Controller) rateLimitTask(logger *logs.Logger) {
+	if controller.rateLimiter == nil {
+		return
+	}
+	reloaded, err := controller.rateLimiter.Reload()
+	if err != nil {
+		logger.Errorf("reload rate limit config failed: %s", err)
+		return
+	}
+	if reloaded {
+		logger.Info("rate limit config reloaded")
+	}
+}
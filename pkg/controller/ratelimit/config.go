@@ -0,0 +1,69 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ratelimit
+
+import (
+	"encoding/json"
+	"io/ioutil"
+)
+
+// Limit of a token bucket; rps 0 means unlimited
+type Limit struct {
+	RPS   float64 `json:"rps"`
+	Burst int     `json:"burst"`
+}
+
+// Config is the content of the rate limit config file;
+// accounts and functions without their own limit take the default one
+type Config struct {
+	DefaultAccount  *Limit            `json:"defaultAccount,omitempty"`
+	DefaultFunction *Limit            `json:"defaultFunction,omitempty"`
+	Accounts        map[string]*Limit `json:"accounts,omitempty"`
+	Functions       map[string]*Limit `json:"functions,omitempty"`
+}
+
+func LoadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config := &Config{}
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func (c *Config) limit(scope Scope, key string) *Limit {
+	var (
+		limits   map[string]*Limit
+		fallback *Limit
+	)
+	switch scope {
+	case ScopeAccount:
+		limits, fallback = c.Accounts, c.DefaultAccount
+	case ScopeFunction:
+		limits, fallback = c.Functions, c.DefaultFunction
+	}
+	if l, ok := limits[key]; ok {
+		fallback = l
+	}
+	if fallback == nil || fallback.RPS <= 0 {
+		return nil
+	}
+	return fallback
+}
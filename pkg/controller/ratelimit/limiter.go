@@ -0,0 +1,205 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ratelimit
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/baidu/easyfaas/pkg/util/logs/metric"
+)
+
+type Scope string
+
+const (
+	ScopeAccount  Scope = "account"
+	ScopeFunction Scope = "function"
+)
+
+// bucket refills rps tokens every second up to burst
+type bucket struct {
+	limit  Limit
+	tokens float64
+	last   time.Time
+}
+
+func newBucket(limit Limit, now time.Time) *bucket {
+	return &bucket{limit: limit, tokens: limit.capacity(), last: now}
+}
+
+func (l Limit) capacity() float64 {
+	if l.Burst < 1 {
+		return 1
+	}
+	return float64(l.Burst)
+}
+
+func (b *bucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens += elapsed * b.limit.RPS
+		if c := b.limit.capacity(); b.tokens > c {
+			b.tokens = c
+		}
+	}
+	b.last = now
+}
+
+// wait: time until the bucket has a token
+func (b *bucket) wait() time.Duration {
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.limit.RPS * float64(time.Second))
+}
+
+type bucketKey struct {
+	scope Scope
+	key   string
+}
+
+// Limiter throttles invocations by the token buckets of their account and function
+type Limiter struct {
+	path    string
+	lock    sync.Mutex
+	config  *Config
+	modTime time.Time
+	buckets map[bucketKey]*bucket
+	now     func() time.Time
+}
+
+// New loads the config file; the limiter is nil when no config file is set
+func New(o *Options) (*Limiter, error) {
+	if o.ConfigFile == "" {
+		return nil, nil
+	}
+	l := NewLimiter(nil)
+	l.path = o.ConfigFile
+	if _, err := l.Reload(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// NewLimiter creates a limiter with fixed limits
+func NewLimiter(config *Config) *Limiter {
+	return &Limiter{
+		config:  config,
+		buckets: make(map[bucketKey]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Reload reads the config file again if it was modified
+func (l *Limiter) Reload() (bool, error) {
+	if l.path == "" {
+		return false, nil
+	}
+	info, err := os.Stat(l.path)
+	if err != nil {
+		return false, err
+	}
+	l.lock.Lock()
+	modTime := l.modTime
+	l.lock.Unlock()
+	if info.ModTime().Equal(modTime) {
+		l.gc()
+		return false, nil
+	}
+	config, err := LoadConfig(l.path)
+	if err != nil {
+		return false, err
+	}
+	l.SetConfig(config)
+	l.lock.Lock()
+	l.modTime = info.ModTime()
+	l.lock.Unlock()
+	return true, nil
+}
+
+// SetConfig replaces the limits; buckets of changed limits start full again,
+// the others keep their tokens
+func (l *Limiter) SetConfig(config *Config) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.config = config
+	for k, b := range l.buckets {
+		if config == nil {
+			delete(l.buckets, k)
+			continue
+		}
+		if limit := config.limit(k.scope, k.key); limit == nil || *limit != b.limit {
+			delete(l.buckets, k)
+		}
+	}
+}
+
+// Allow takes a token from both the account bucket and the function bucket;
+// if either is empty nothing is taken and the time to retry is returned
+func (l *Limiter) Allow(account, function string) (ok bool, scope Scope, retryAfter time.Duration) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	now := l.now()
+	buckets := map[Scope]*bucket{
+		ScopeAccount:  l.bucketLocked(ScopeAccount, account, now),
+		ScopeFunction: l.bucketLocked(ScopeFunction, function, now),
+	}
+	for _, scope := range []Scope{ScopeAccount, ScopeFunction} {
+		if b := buckets[scope]; b != nil && b.wait() > 0 {
+			metric.Inc(throttledIndex, string(scope))
+			return false, scope, b.wait()
+		}
+	}
+	for _, b := range buckets {
+		if b != nil {
+			b.tokens--
+		}
+	}
+	return true, "", 0
+}
+
+func (l *Limiter) bucketLocked(scope Scope, key string, now time.Time) *bucket {
+	if key == "" || l.config == nil {
+		return nil
+	}
+	limit := l.config.limit(scope, key)
+	if limit == nil {
+		return nil
+	}
+	k := bucketKey{scope: scope, key: key}
+	b, ok := l.buckets[k]
+	if !ok {
+		b = newBucket(*limit, now)
+		l.buckets[k] = b
+		return b
+	}
+	b.refill(now)
+	return b
+}
+
+// gc drops buckets which are full again, they are the same as new ones
+func (l *Limiter) gc() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	now := l.now()
+	for k, b := range l.buckets {
+		b.refill(now)
+		if b.tokens >= b.limit.capacity() {
+			delete(l.buckets, k)
+		}
+	}
+}
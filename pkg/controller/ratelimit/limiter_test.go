@@ -0,0 +1,105 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ratelimit
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLimiterAllow(t *testing.T) {
+	now := time.Unix(1000, 0)
+	l := NewLimiter(&Config{
+		DefaultAccount: &Limit{RPS: 100, Burst: 100},
+		Functions:      map[string]*Limit{"fn": {RPS: 2, Burst: 2}},
+	})
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if ok, _, _ := l.Allow("account", "fn"); !ok {
+			t.Fatalf("expect request %d within burst allowed", i)
+		}
+	}
+	ok, scope, retryAfter := l.Allow("account", "fn")
+	if ok || scope != ScopeFunction || retryAfter != 500*time.Millisecond {
+		t.Errorf("expect function throttled for 500ms, got %v %s %s", ok, scope, retryAfter)
+	}
+	if ok, _, _ := l.Allow("account", "other"); !ok {
+		t.Errorf("expect function without limit allowed")
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if ok, _, _ := l.Allow("account", "fn"); !ok {
+		t.Errorf("expect a token refilled after 500ms")
+	}
+}
+
+func TestLimiterSetConfig(t *testing.T) {
+	now := time.Unix(1000, 0)
+	l := NewLimiter(&Config{Accounts: map[string]*Limit{"a": {RPS: 1, Burst: 1}, "b": {RPS: 1, Burst: 1}}})
+	l.now = func() time.Time { return now }
+	l.Allow("a", "")
+	l.Allow("b", "")
+
+	// only the bucket of the changed limit starts full again
+	l.SetConfig(&Config{Accounts: map[string]*Limit{"a": {RPS: 1, Burst: 1}, "b": {RPS: 2, Burst: 1}}})
+	if ok, _, _ := l.Allow("a", ""); ok {
+		t.Errorf("expect the unchanged limit keeping its empty bucket")
+	}
+	if ok, _, _ := l.Allow("b", ""); !ok {
+		t.Errorf("expect the changed limit starting with a full bucket")
+	}
+}
+
+func TestLimiterReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ratelimit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "ratelimit.json")
+	if err := ioutil.WriteFile(path, []byte(`{"accounts": {"a": {"rps": 1, "burst": 1}}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	l, err := New(&Options{ConfigFile: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Allow("a", "")
+	if ok, scope, _ := l.Allow("a", ""); ok || scope != ScopeAccount {
+		t.Errorf("expect account throttled")
+	}
+
+	if err := ioutil.WriteFile(path, []byte(`{"accounts": {"a": {"rps": 10, "burst": 10}}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(path, future, future)
+	if reloaded, err := l.Reload(); !reloaded || err != nil {
+		t.Fatalf("expect config reloaded, got %v %v", reloaded, err)
+	}
+	if ok, _, _ := l.Allow("a", ""); !ok {
+		t.Errorf("expect account allowed by the reloaded limit")
+	}
+
+	if l, err := New(NewOptions()); l != nil || err != nil {
+		t.Errorf("expect no limiter without config file")
+	}
+}
@@ -0,0 +1,37 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ratelimit
+
+import (
+	"github.com/baidu/easyfaas/pkg/util/logs/metric"
+)
+
+const throttledIndex = "rate_limited"
+
+var metrics = []metric.MetricConfig{
+	{
+		MetricType:   metric.MetricTypeCounter,
+		Index:        throttledIndex,
+		Name:         throttledIndex,
+		Labels:       []string{"scope"},
+		HelpTemplate: "invocations throttled by rate limits",
+	},
+}
+
+func InitMetric() error {
+	return metric.Register("invoke", metrics)
+}
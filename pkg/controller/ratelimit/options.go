@@ -0,0 +1,35 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package ratelimit
+package ratelimit
+
+import (
+	"github.com/spf13/pflag"
+)
+
+type Options struct {
+	// Rate limit config file, rate limiting is disabled when empty
+	ConfigFile string
+}
+
+func NewOptions() *Options {
+	return &Options{}
+}
+
+func (s *Options) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&s.ConfigFile, "rate-limit-config", s.ConfigFile, "rate limit config file, reloaded when changed; empty disables rate limiting")
+}
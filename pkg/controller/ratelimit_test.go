@@ -0,0 +1,80 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/baidu/easyfaas/pkg/api"
+	"github.com/baidu/easyfaas/pkg/controller/ratelimit"
+	innerErr "github.com/baidu/easyfaas/pkg/error"
+	"github.com/baidu/easyfaas/pkg/util/logs"
+)
+
+func TestCheckRateLimit(t *testing.T) {
+	controller, _ := newTestController(0, &fakeDataStorer{})
+	functionBRN := "brn:cloud:faas:bj:8f6e:function:hello:1"
+	ctx := &InvokeContext{
+		AccountID: "8f6e",
+		Function:  testFunction(functionBRN, "commit-1"),
+		Response:  api.NewInvokeProxyResponseWithRequestID("req"),
+	}
+	if err := controller.checkFunctionRateLimit(ctx); err != nil {
+		t.Fatalf("expect no throttling without limiter: %s", err)
+	}
+
+	controller.rateLimiter = ratelimit.NewLimiter(&ratelimit.Config{
+		Functions: map[string]*ratelimit.Limit{"brn:cloud:faas:bj:8f6e:function:hello": {RPS: 0.5, Burst: 1}},
+	})
+	if err := controller.checkFunctionRateLimit(ctx); err != nil {
+		t.Fatalf("expect the first invocation admitted: %s", err)
+	}
+	err := controller.checkFunctionRateLimit(ctx)
+	finalErr, ok := err.(innerErr.FinalError)
+	if !ok || finalErr.Code != innerErr.TooManyRequestsException {
+		t.Fatalf("expect TooManyRequestsException, got %v", err)
+	}
+	if retryAfter := ctx.Response.Headers[headerRetryAfter]; retryAfter != "2" {
+		t.Errorf("expect Retry-After 2, got %q", retryAfter)
+	}
+}
+
+func TestAccountRateLimitBeforeMetadata(t *testing.T) {
+	storer := &fakeDataStorer{}
+	controller, _ := newTestController(0, storer)
+	controller.rateLimiter = ratelimit.NewLimiter(&ratelimit.Config{
+		Accounts: map[string]*ratelimit.Limit{"8f6e": {RPS: 0.5, Burst: 1}},
+	})
+	controller.rateLimiter.Allow("8f6e", "")
+
+	// the function is unknown, only the account check answers with 429
+	ctx := &InvokeContext{
+		RunOptions:   controller.runOptions,
+		Clients:      &Clients{DataStorer: storer},
+		AccountID:    "8f6e",
+		FunctionName: "hello",
+		Qualifier:    "1",
+		Response:     api.NewInvokeProxyResponseWithRequestID("req"),
+		Logger:       logs.NewLogger(),
+		Metrics:      NewInvokeMetrics("req"),
+	}
+	controller.Do(ctx)
+	if ctx.Response.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expect the account throttled before the function lookup, got %d", ctx.Response.StatusCode)
+	}
+}
@@ -37,6 +37,7 @@ type Registry interface {
 	GetFunction(input *api.GetFunctionInput) (*api.GetFunctionOutput, error)
 	GetAlias(input *api.GetAliasInput) (*api.GetAliasOutput, error)
 	GetRuntimeConfiguration(input *api.GetRuntimeConfigurationInput) (*api.RuntimeConfiguration, error)
+	ListReservedConcurrency(input *api.ListReservedConcurrencyInput) (*api.ListReservedConcurrencyOutput, error)
 }
 
 func NewRegistry(o *Options) (r Registry, err error) {
@@ -146,3 +147,16 @@ func (c *RegistryClient) GetRuntimeConfiguration(input *api.GetRuntimeConfigurat
 	}
 	return &out, err
 }
+
+// ListReservedConcurrency lists the functions with reserved concurrency of all accounts
+func (c *RegistryClient) ListReservedConcurrency(input *api.ListReservedConcurrencyInput) (*api.ListReservedConcurrencyOutput, error) {
+	req := c.client.Get().
+		Resource("concurrencies")
+	req.SetHeader("Host", req.URL().Host)
+	req.SetHeader(api.HeaderXRequestID, input.RequestID)
+	req.SetHeader(api.HeaderAuthorization, c.signer.GetSignature(req))
+
+	var out api.ListReservedConcurrencyOutput
+	err := req.Do().Into(&out)
+	return &out, err
+}
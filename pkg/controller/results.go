@@ -0,0 +1,107 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"path/filepath"
+
+	"github.com/baidu/easyfaas/pkg/controller/eventqueue"
+	"github.com/baidu/easyfaas/pkg/controller/resultstore"
+)
+
+// resultStoreDir: sub directory of the event queue persisting the results
+const resultStoreDir = "results"
+
+// invocationAccepted is the response body of an accepted event invocation
+type invocationAccepted struct {
+	InvocationID string `json:"invocationId"`
+}
+
+// initResultStore creates the result store, persisted next to the event queue unless a directory is given
+func (controller *Controller) initResultStore(o *resultstore.Options, queue *eventqueue.Options) (err error) {
+	if o.Capacity <= 0 {
+		return nil
+	}
+	if o.StoreDir == "" && queue.Enable {
+		o.StoreDir = filepath.Join(queue.StoreDir, resultStoreDir)
+	}
+	controller.results, err = resultstore.New(o)
+	return err
+}
+
+// recoverResults reconciles the reloaded results with the events still queued
+func (controller *Controller) recoverResults() {
+	if controller.results == nil {
+		return
+	}
+	var queued map[string]struct{}
+	if controller.eventQueue != nil {
+		queued = controller.eventQueue.Reloaded()
+	}
+	controller.results.Recover(queued)
+}
+
+// recordPending records the accepted event invocation
+func (controller *Controller) recordPending(ctx *InvokeContext) {
+	if controller.results == nil {
+		return
+	}
+	function := ctx.FunctionBRN
+	if function == "" {
+		function = ctx.FunctionName
+	}
+	controller.results.Pending(ctx.RequestID, ctx.AccountID, function)
+}
+
+func (controller *Controller) recordRunning(invocationID string, attempts int) {
+	if controller.results == nil {
+		return
+	}
+	controller.results.Running(invocationID, attempts)
+}
+
+// recordResult saves the result of an event attempt; the invocation stays pending when it will be retried
+func (controller *Controller) recordResult(ctx *InvokeContext, res *eventqueue.Result, retry bool) {
+	if controller.results == nil {
+		return
+	}
+	record := &resultstore.Record{
+		Status:       resultstore.StatusSucceeded,
+		StatusCode:   res.StatusCode,
+		Payload:      string(ctx.Response.Body),
+		ErrorType:    res.ErrorType,
+		ErrorMessage: res.ErrorMessage,
+	}
+	if res.Outcome != eventqueue.OutcomeSucceeded {
+		record.Status = resultstore.StatusFailed
+		if retry {
+			record.Status = resultstore.StatusPending
+		}
+	}
+	if ctx.Output != nil && ctx.Output.Statistic != nil && ctx.Output.Statistic.Statistic != nil {
+		record.DurationMS = ctx.Output.Statistic.Statistic.Duration
+		record.MemoryUsedBytes = ctx.Output.Statistic.Statistic.MemoryUsed
+	}
+	controller.results.Finish(ctx.RequestID, record)
+}
+
+func (controller *Controller) forgetResult(invocationID string) {
+	if controller.results == nil {
+		return
+	}
+	controller.results.Delete(invocationID)
+}
@@ -0,0 +1,104 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resultstore
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/baidu/easyfaas/pkg/util/logs"
+)
+
+const (
+	recordFileSuffix = ".result"
+	tmpFileSuffix    = ".tmp"
+)
+
+// diskStore keeps one file per record
+type diskStore struct {
+	dir string
+}
+
+func newDiskStore(dir string) (*diskStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &diskStore{dir: dir}, nil
+}
+
+func (s *diskStore) path(id string) string {
+	return filepath.Join(s.dir, id+recordFileSuffix)
+}
+
+// Save writes the record to a temporary file and renames it, so a crash never leaves a partial record
+func (s *diskStore) Save(r *Record) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	tmp := s.path(r.InvocationID) + tmpFileSuffix
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, s.path(r.InvocationID))
+}
+
+func (s *diskStore) Delete(id string) error {
+	err := os.Remove(s.path(id))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// Load returns all records; broken files are skipped
+func (s *diskStore) Load() ([]*Record, error) {
+	files, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]*Record, 0, len(files))
+	for _, f := range files {
+		name := f.Name()
+		if f.IsDir() {
+			continue
+		}
+		if strings.HasSuffix(name, tmpFileSuffix) {
+			os.Remove(filepath.Join(s.dir, name))
+			continue
+		}
+		if !strings.HasSuffix(name, recordFileSuffix) {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(s.dir, name))
+		if err != nil {
+			logs.Warnf("read result file %s failed: %s", name, err)
+			continue
+		}
+		r := &Record{}
+		if err := json.Unmarshal(data, r); err != nil || r.InvocationID == "" {
+			logs.Warnf("unmarshal result file %s failed: %v", name, err)
+			continue
+		}
+		records = append(records, r)
+	}
+	return records, nil
+}
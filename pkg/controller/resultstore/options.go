@@ -0,0 +1,54 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package resultstore
+package resultstore
+
+import (
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+const (
+	defaultCapacity = 10000
+	defaultTTL      = time.Hour
+)
+
+type Options struct {
+	// Max results kept, 0 disables the result store
+	Capacity int
+
+	// Time a result is kept after its last update
+	TTL time.Duration
+
+	// Directory to persist results, next to the event queue if empty and the event queue is enabled
+	StoreDir string
+}
+
+func NewOptions() *Options {
+	return &Options{
+		Capacity: defaultCapacity,
+		TTL:      defaultTTL,
+	}
+}
+
+func (s *Options) AddFlags(fs *pflag.FlagSet) {
+	fs.IntVar(&s.Capacity, "result-store-capacity", s.Capacity, "max event invocation results kept, 0 disables the result store")
+	fs.DurationVar(&s.TTL, "result-store-ttl", s.TTL, "time an event invocation result is kept after its last update")
+	fs.StringVar(&s.StoreDir, "result-store-dir", s.StoreDir,
+		"event invocation result storage path, next to the event queue if empty")
+}
@@ -0,0 +1,261 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resultstore
+
+import (
+	"container/list"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/baidu/easyfaas/pkg/util/logs"
+)
+
+type Status string
+
+const (
+	StatusPending   Status = "pending"
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+)
+
+// ErrorTypeInvocationLost: the invocation was neither finished nor queued when the controller restarted
+const ErrorTypeInvocationLost = "InvocationLost"
+
+// Record is the state and the result of an event invocation
+type Record struct {
+	InvocationID    string    `json:"invocationId"`
+	AccountID       string    `json:"accountId,omitempty"`
+	Function        string    `json:"function,omitempty"`
+	Status          Status    `json:"status"`
+	Attempts        int       `json:"attempts"`
+	StatusCode      int       `json:"statusCode,omitempty"`
+	Payload         string    `json:"payload,omitempty"`
+	ErrorType       string    `json:"errorType,omitempty"`
+	ErrorMessage    string    `json:"errorMessage,omitempty"`
+	DurationMS      float64   `json:"durationMs,omitempty"`
+	MemoryUsedBytes int64     `json:"memoryUsedBytes,omitempty"`
+	CreateTime      time.Time `json:"createTime"`
+	UpdateTime      time.Time `json:"updateTime"`
+}
+
+// Finished tells whether the invocation will not be attempted any more
+func (s Status) Finished() bool {
+	return s == StatusSucceeded || s == StatusFailed
+}
+
+// Store keeps the records in memory and on disk if a directory is configured;
+// the least recently updated finished records are dropped first, unfinished ones are never dropped
+type Store struct {
+	options *Options
+	disk    *diskStore
+	lock    sync.Mutex
+	records map[string]*Record
+	// finished records, front is the most recently updated
+	finished *list.List
+	elements map[string]*list.Element
+	now      func() time.Time
+}
+
+func New(o *Options) (*Store, error) {
+	s := &Store{
+		options:  o,
+		records:  make(map[string]*Record),
+		finished: list.New(),
+		elements: make(map[string]*list.Element),
+		now:      time.Now,
+	}
+	if o.StoreDir == "" {
+		return s, nil
+	}
+	disk, err := newDiskStore(o.StoreDir)
+	if err != nil {
+		return nil, err
+	}
+	records, err := disk.Load()
+	if err != nil {
+		return nil, err
+	}
+	s.disk = disk
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].UpdateTime.Before(records[j].UpdateTime)
+	})
+	for _, r := range records {
+		s.records[r.InvocationID] = r
+		if r.Status.Finished() {
+			s.elements[r.InvocationID] = s.finished.PushFront(r)
+		}
+	}
+	logs.Infof("reload %d invocation results from %s", len(records), o.StoreDir)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.expire()
+	return s, nil
+}
+
+// Pending records an accepted invocation of the account
+func (s *Store) Pending(id, accountID, function string) {
+	s.update(id, true, func(r *Record) {
+		r.AccountID = accountID
+		r.Function = function
+		r.Status = StatusPending
+	})
+}
+
+// Running records that an attempt of the invocation started
+func (s *Store) Running(id string, attempts int) {
+	s.update(id, false, func(r *Record) {
+		r.Status = StatusRunning
+		r.Attempts = attempts
+	})
+}
+
+// Finish saves the result of the last attempt; status is pending if the invocation will be retried
+func (s *Store) Finish(id string, result *Record) {
+	s.update(id, false, func(r *Record) {
+		r.Status = result.Status
+		r.StatusCode = result.StatusCode
+		r.Payload = result.Payload
+		r.ErrorType = result.ErrorType
+		r.ErrorMessage = result.ErrorMessage
+		r.DurationMS = result.DurationMS
+		r.MemoryUsedBytes = result.MemoryUsedBytes
+	})
+}
+
+// Recover reconciles the records reloaded from disk with the invocations still queued:
+// the queued ones are pending again, the other unfinished ones were lost and are failed
+func (s *Store) Recover(queued map[string]struct{}) {
+	s.lock.Lock()
+	ids := make([]string, 0)
+	for id, r := range s.records {
+		if !r.Status.Finished() {
+			ids = append(ids, id)
+		}
+	}
+	s.lock.Unlock()
+
+	for _, id := range ids {
+		if _, ok := queued[id]; ok {
+			s.update(id, false, func(r *Record) {
+				r.Status = StatusPending
+			})
+			continue
+		}
+		s.update(id, false, func(r *Record) {
+			r.Status = StatusFailed
+			r.ErrorType = ErrorTypeInvocationLost
+			r.ErrorMessage = "the invocation was not queued when the controller restarted"
+		})
+	}
+}
+
+func (s *Store) Delete(id string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.remove(id)
+}
+
+// Get returns a copy of the record
+func (s *Store) Get(id string) (*Record, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.expire()
+	r, ok := s.records[id]
+	if !ok {
+		return nil, false
+	}
+	copied := *r
+	return &copied, true
+}
+
+func (s *Store) Len() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.expire()
+	return len(s.records)
+}
+
+// update applies fn to the record, the record is only created if create is true
+func (s *Store) update(id string, create bool, fn func(r *Record)) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	now := s.now()
+	r, ok := s.records[id]
+	if !ok {
+		if !create {
+			return
+		}
+		r = &Record{InvocationID: id, CreateTime: now}
+		s.records[id] = r
+	}
+	fn(r)
+	r.UpdateTime = now
+
+	if e, ok := s.elements[id]; ok {
+		s.finished.Remove(e)
+		delete(s.elements, id)
+	}
+	if r.Status.Finished() {
+		s.elements[id] = s.finished.PushFront(r)
+	}
+	s.save(r)
+
+	for len(s.records) > s.options.Capacity && s.finished.Len() > 0 {
+		s.remove(s.finished.Back().Value.(*Record).InvocationID)
+	}
+	s.expire()
+}
+
+// expire drops finished records not updated within the ttl
+func (s *Store) expire() {
+	deadline := s.now().Add(-s.options.TTL)
+	for e := s.finished.Back(); e != nil; e = s.finished.Back() {
+		r := e.Value.(*Record)
+		if r.UpdateTime.After(deadline) {
+			return
+		}
+		s.remove(r.InvocationID)
+	}
+}
+
+func (s *Store) remove(id string) {
+	if e, ok := s.elements[id]; ok {
+		s.finished.Remove(e)
+		delete(s.elements, id)
+	}
+	if _, ok := s.records[id]; !ok {
+		return
+	}
+	delete(s.records, id)
+	if s.disk == nil {
+		return
+	}
+	if err := s.disk.Delete(id); err != nil {
+		logs.Errorf("delete invocation result %s failed: %s", id, err)
+	}
+}
+
+func (s *Store) save(r *Record) {
+	if s.disk == nil {
+		return
+	}
+	if err := s.disk.Save(r); err != nil {
+		logs.Errorf("save invocation result %s failed: %s", r.InvocationID, err)
+	}
+}
@@ -0,0 +1,128 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resultstore
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func newTestStore(t *testing.T, o *Options) *Store {
+	s, err := New(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestStoreLifecycle(t *testing.T) {
+	s := newTestStore(t, &Options{Capacity: 10, TTL: time.Minute})
+	s.Running("unknown", 1)
+	s.Finish("unknown", &Record{Status: StatusSucceeded})
+	if _, ok := s.Get("unknown"); ok {
+		t.Errorf("expect no record created without the invocation accepted")
+	}
+	s.Pending("id", "8f6e", "fn")
+	s.Running("id", 1)
+	s.Finish("id", &Record{Status: StatusPending, ErrorType: "Unhandled"})
+	r, ok := s.Get("id")
+	if !ok || r.Status != StatusPending || r.Attempts != 1 || r.ErrorType != "Unhandled" {
+		t.Errorf("unexpected record after a retryable attempt: %+v", r)
+	}
+	s.Running("id", 2)
+	s.Finish("id", &Record{Status: StatusSucceeded, StatusCode: 200, Payload: "ok"})
+	r, _ = s.Get("id")
+	if r.Status != StatusSucceeded || r.Attempts != 2 || r.Payload != "ok" || r.ErrorType != "" || r.Function != "fn" {
+		t.Errorf("unexpected record after success: %+v", r)
+	}
+	if _, ok := s.Get("unknown"); ok {
+		t.Errorf("expect unknown record not found")
+	}
+}
+
+func TestStoreBounded(t *testing.T) {
+	now := time.Unix(1000, 0)
+	s := newTestStore(t, &Options{Capacity: 2, TTL: time.Minute})
+	s.now = func() time.Time { return now }
+
+	s.Pending("a", "8f6e", "fn")
+	s.Pending("b", "8f6e", "fn")
+	s.Finish("a", &Record{Status: StatusSucceeded})
+	s.Finish("b", &Record{Status: StatusFailed})
+	s.Running("a", 1)
+	s.Finish("a", &Record{Status: StatusSucceeded})
+	s.Pending("c", "8f6e", "fn")
+	if _, ok := s.Get("b"); ok {
+		t.Errorf("expect the least recently updated finished record dropped")
+	}
+	if s.Len() != 2 {
+		t.Errorf("expect 2 records, got %d", s.Len())
+	}
+
+	// unfinished records are kept beyond the capacity
+	s.Pending("d", "8f6e", "fn")
+	s.Pending("e", "8f6e", "fn")
+	if s.Len() != 3 {
+		t.Errorf("expect 3 unfinished records, got %d", s.Len())
+	}
+
+	now = now.Add(time.Minute)
+	if _, ok := s.Get("a"); ok {
+		t.Errorf("expect expired record dropped")
+	}
+	for _, id := range []string{"c", "d", "e"} {
+		if _, ok := s.Get(id); !ok {
+			t.Errorf("expect unfinished record %s kept after ttl", id)
+		}
+	}
+}
+
+func TestStorePersisted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "resultstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	o := &Options{Capacity: 10, TTL: time.Minute, StoreDir: dir}
+
+	s := newTestStore(t, o)
+	s.Pending("queued", "8f6e", "fn")
+	s.Running("queued", 1)
+	s.Pending("lost", "8f6e", "fn")
+	s.Pending("done", "8f6e", "fn")
+	s.Finish("done", &Record{Status: StatusSucceeded, Payload: "ok"})
+	s.Pending("deleted", "8f6e", "fn")
+	s.Delete("deleted")
+
+	// the results survive a restart, unfinished ones are reconciled with the queued events
+	s = newTestStore(t, o)
+	s.Recover(map[string]struct{}{"queued": {}})
+	if r, ok := s.Get("queued"); !ok || r.Status != StatusPending || r.Attempts != 1 || r.Function != "fn" {
+		t.Errorf("unexpected queued record %+v", r)
+	}
+	if r, ok := s.Get("lost"); !ok || r.Status != StatusFailed || r.ErrorType != ErrorTypeInvocationLost {
+		t.Errorf("unexpected lost record %+v", r)
+	}
+	if r, ok := s.Get("done"); !ok || r.Status != StatusSucceeded || r.Payload != "ok" {
+		t.Errorf("unexpected finished record %+v", r)
+	}
+	if _, ok := s.Get("deleted"); ok {
+		t.Errorf("expect deleted record not reloaded")
+	}
+}
@@ -17,6 +17,7 @@
 package rtctrl
 
 import (
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"time"
@@ -68,6 +69,7 @@ func (s *RuntimeClient) createRequest(input *InvocationInput) *RequestInfo {
 
 	reqinfo := NewRequestInfo(input.RequestID, input.Runtime)
 	reqinfo.TriggerType = input.TriggerType
+	reqinfo.AccountID = input.AccountID
 	reqinfo.Input = input
 	reqinfo.Output = &InvocationOutput{
 		Output:    &InvocationResponse{Response: input.Response},
@@ -81,8 +83,9 @@ func (s *RuntimeClient) createRequest(input *InvocationInput) *RequestInfo {
 
 func (s *RuntimeClient) makeInvokeRequest(input *InvocationInput) *InvokeRequest {
 	invokeReq := &InvokeRequest{
-		RequestID: input.RequestID,
-		Version:   *input.Configuration.Version,
+		RequestID:     input.RequestID,
+		Version:       *input.Configuration.Version,
+		ClientContext: input.ClientContext,
 	}
 
 	if input.InvokeType != api.InvokeTypeEvent && input.Request.BodyStream != nil {
@@ -118,6 +121,7 @@ func (s *RuntimeClient) waitRuntime(reqinfo *RequestInfo) (err error) {
 	input := reqinfo.Input
 	ok := runtime.Wait(s.config.WaitRuntimeAliveTimeout)
 	if !ok {
+		reqinfo.Output.RuntimeNotReady = true
 		err = fmt.Errorf("runtime %s not ready", runtime.RuntimeID)
 		return
 	}
@@ -163,7 +167,11 @@ func (s *RuntimeClient) InvokeFunction(input *InvocationInput) (output *Invocati
 	}
 	reqInfo.StepDone(StageWaitRuntime)
 
+	detached := false
 	defer func() {
+		if detached {
+			return
+		}
 		s.invokeCleanup(reqInfo, input.Runtime)
 		reqInfo.StepDone(StageCleanup)
 	}()
@@ -192,6 +200,7 @@ func (s *RuntimeClient) InvokeFunction(input *InvocationInput) (output *Invocati
 		return
 	}
 
+	deadline := time.Now().Add(time.Duration(functionTimeout) * time.Second)
 	timer := time.NewTimer(time.Duration(functionTimeout) * time.Second)
 	select {
 	case <-reqInfo.SyncChannel:
@@ -205,6 +214,13 @@ func (s *RuntimeClient) InvokeFunction(input *InvocationInput) (output *Invocati
 	}
 	timer.Stop()
 
+	if !timeout && reqInfo.isDetached() {
+		// the caller gets the response while the function is still running
+		detached = true
+		go s.waitDetached(reqInfo, input, time.Until(deadline))
+		return
+	}
+
 	reqInfo.InvokeReportDone()
 	reqInfo.StepDone(StageInvokeReportDone)
 	s.dispatchServer.StopRecvLog(reqInfo.Runtime.RuntimeID, reqInfo.RequestID, reqInfo.store)
@@ -217,6 +233,27 @@ func (s *RuntimeClient) InvokeFunction(input *InvocationInput) (output *Invocati
 	return
 }
 
+// waitDetached finishes the request returned early after the runtime finishes it or it times out,
+// the runtime is released after the done channel of the request is closed
+func (s *RuntimeClient) waitDetached(reqInfo *RequestInfo, input *InvocationInput, timeout time.Duration) {
+	timer := time.NewTimer(timeout)
+	select {
+	case <-reqInfo.detached:
+	case <-timer.C:
+		errMsg := fmt.Sprintf("%s Task timed out after %d seconds", reqInfo.RequestID, *input.Configuration.Timeout)
+		reqInfo.InvokeResult(StatusTimeout, errMsg)
+		if stream := reqInfo.getStream(); stream != nil {
+			stream.close(errors.New(errMsg))
+		}
+	}
+	timer.Stop()
+
+	reqInfo.InvokeReportDone()
+	s.dispatchServer.StopRecvLog(reqInfo.Runtime.RuntimeID, reqInfo.RequestID, reqInfo.store)
+	s.invokeCleanup(reqInfo, input.Runtime)
+	close(reqInfo.done)
+}
+
 func (s *RuntimeClient) InvokeFunc(reqInfo *RequestInfo, input *InvocationInput) (err error) {
 	if input.WithStreamMode {
 		invokeRequest := s.makeInvokeHTTPRequest(reqInfo, input)
@@ -32,6 +32,7 @@ type DispatchServerV2 struct {
 	runtimeDispatcher RuntimeDispatcher
 	runtimeServer     *http.Server
 	runnerServer      *http.Server
+	runtimeAPIServer  *http.Server
 
 	storeMap storeMap
 	statsMap statsMap
@@ -66,6 +67,9 @@ func (s *DispatchServerV2) getRunnerRouteHandler() *mux.Router {
 func (s *DispatchServerV2) ListenAndServe() {
 	s.runtimeServer = s.serve(s.config.RuntimeServerAddress, s.getRuntimeRouteHandler())
 	s.runnerServer = s.serve(s.config.RunnerServerAddress, s.getRunnerRouteHandler())
+	if s.config.RuntimeAPIAddress != "" {
+		s.runtimeAPIServer = s.serve(s.config.RuntimeAPIAddress, s.getRuntimeAPIRouteHandler())
+	}
 }
 
 func (s *DispatchServerV2) serve(address string, router *mux.Router) *http.Server {
@@ -194,6 +198,11 @@ func (s *DispatchServerV2) invokeHandler(w http.ResponseWriter, r *http.Request)
 		logs.V(3).Errorf("runtime %s not found", runtimeid)
 		return
 	}
+	protocol, err := ParseRuntimeProtocol(r.Header.Get(HeaderRuntimeProtocol))
+	if err != nil {
+		logs.Errorf("runtime %s connect failed: %s", runtimeid, err)
+		return
+	}
 
 	warmNotify := make(chan struct{})
 	params := &startRuntimeParams{
@@ -202,6 +211,9 @@ func (s *DispatchServerV2) invokeHandler(w http.ResponseWriter, r *http.Request)
 		conn:       conn,
 		warmNotify: warmNotify,
 		urlParams:  r.URL.Query(),
+		protocol:   protocol,
+		probe:      r.Header.Get(HeaderRuntimeProbe) == "true",
+		abortable:  r.Header.Get(HeaderRuntimeAbort) == "true",
 	}
 	go func() {
 		select {
@@ -20,6 +20,7 @@ package rtctrl
 import (
 	"fmt"
 	"strings"
+	"time"
 )
 
 type RuntimeStateUnmatched struct {
@@ -87,3 +88,51 @@ type RuntimeNoNeedToReset struct {
 func (e RuntimeNoNeedToReset) Error() string {
 	return fmt.Sprintf("runtime is no need to reset: %s", e.RuntimeID)
 }
+
+// WaitQueueFull: too many requests are waiting for the function's runtimes
+type WaitQueueFull struct {
+	CommitID string
+	Length   int
+}
+
+func (e WaitQueueFull) Error() string {
+	return fmt.Sprintf("wait queue of %s is full: length %d", e.CommitID, e.Length)
+}
+
+// WaitRuntimeTimeout: no runtime was released before the max wait time
+type WaitRuntimeTimeout struct {
+	CommitID string
+	Timeout  time.Duration
+}
+
+func (e WaitRuntimeTimeout) Error() string {
+	return fmt.Sprintf("wait runtime of %s timeout after %s", e.CommitID, e.Timeout)
+}
+
+// ColdRuntimeFreed: a cold runtime was freed while waiting,
+// the request should try to start one before waiting again
+type ColdRuntimeFreed struct {
+	CommitID string
+}
+
+func (e ColdRuntimeFreed) Error() string {
+	return fmt.Sprintf("cold runtime freed for waiter of %s", e.CommitID)
+}
+
+// NoRuntimeToEvict: no idle warm runtime could be evicted
+type NoRuntimeToEvict struct {
+	CommitID string
+}
+
+func (e NoRuntimeToEvict) Error() string {
+	return fmt.Sprintf("no idle runtime could be evicted for commit id %s", e.CommitID)
+}
+
+// RequestNotExist: the request is not in flight on any runtime
+type RequestNotExist struct {
+	RequestID string
+}
+
+func (e RequestNotExist) Error() string {
+	return fmt.Sprintf("request %s does not exist", e.RequestID)
+}
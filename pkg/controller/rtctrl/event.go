@@ -30,9 +30,18 @@ type OccupyInput struct {
 	WithStreamMode bool
 	MemorySize     uint64
 	MilliCPUs      int64
+	// Evicted is the runtime evicted for the request, nil if the request did not evict any
+	Evicted *RuntimeInfo
 }
 
-func (info *RuntimeInfo) opOccupyCheck(interface{}) error {
+func (info *RuntimeInfo) opOccupyCheck(args interface{}) error {
+	params := args.(*OccupyInput)
+	if info.evicting != (params.Evicted == info) {
+		return &RuntimeMatchError{
+			Reason: "runtime is not evicted for the request",
+		}
+	}
+
 	if info.State != RuntimeStateCold {
 		return &RuntimeStateUnmatched{
 			RuntimeID:     info.RuntimeID,
@@ -58,6 +67,7 @@ func (info *RuntimeInfo) opOccupySet(args interface{}) error {
 	info.updateStreamMode(params.WithStreamMode)
 	info.SetResource(params.MemorySize, params.MilliCPUs)
 	info.Concurrency++
+	info.evicting = false
 	logs.V(5).Infof("occupy runtime %s concurrency %d status %s", info.RuntimeID, info.Concurrency, info.State)
 	info.SetCommitID(params.CommitID)
 	info.SetMarked(true)
@@ -79,6 +89,12 @@ func (info *RuntimeInfo) opMergedCheck(interface{}) error {
 		}
 	}
 
+	if info.evicting {
+		return &RuntimeMatchError{
+			Reason: "runtime is evicted for another request",
+		}
+	}
+
 	if !info.available() {
 		return &RuntimeMatchError{
 			Reason: "runner is not available",
@@ -127,10 +143,18 @@ func (info *RuntimeInfo) opRetrieveCheck(args interface{}) error {
 }
 
 func (info *RuntimeInfo) opRetrieveSet(interface{}) error {
-	info.CommitID = ""
+	info.SetCommitID("")
 	info.UserID = ""
 	info.Concurrency = 0
 	info.ConcurrentMode = info.DefaultConcurrentMode
+	info.Unresponsive = false
+	info.ProbeFailures = 0
+	info.runtimeAPIToken = ""
+	select {
+	case <-info.initFailChan:
+		info.initFailChan = make(chan struct{})
+	default:
+	}
 	info.updateLastResetTime()
 	info.SetState(RuntimeStateReclaiming)
 	return nil
@@ -165,9 +189,11 @@ func (info *RuntimeInfo) opRollbackSet(interface{}) error {
 		info.Concurrency--
 	}
 	info.SetState(RuntimeStateCold)
+	info.restoredAt = time.Time{}
 	info.updateStreamMode(false)
 	info.SetResource(0, 0)
 	info.SetCommitID("")
+	info.Provisioned = false
 	info.SetMarked(false)
 	return nil
 }
@@ -227,20 +253,32 @@ func (info *RuntimeInfo) opMarkSet(interface{}) error {
 
 type StopInput struct {
 	Deadline time.Time
+	// Force stops an idle runtime regardless of the deadline
+	Force bool
+	// Drain stops provisioned runtimes as well when the controller shuts down
+	Drain bool
+	// Evict keeps the runtime for the request evicting it
+	Evict bool
 }
 
 func (info *RuntimeInfo) opStopCheck(args interface{}) error {
-	if info.State != RuntimeStateWarm {
+	if info.State != RuntimeStateWarm && info.State != RuntimeStateFrozen {
 		return &RuntimeStateUnmatched{
 			RuntimeID:     info.RuntimeID,
 			CurrentState:  info.State,
-			ExpectedState: []RuntimeStateType{RuntimeStateWarm},
+			ExpectedState: []RuntimeStateType{RuntimeStateWarm, RuntimeStateFrozen},
 		}
 	}
 
 	params := args.(*StopInput)
 
-	if info.Concurrency == 0 && info.LastAccessTime.Before(params.Deadline) {
+	if info.Provisioned && !params.Drain {
+		return &RuntimeMatchError{
+			Reason: "runtime is provisioned",
+		}
+	}
+
+	if info.Concurrency == 0 && (params.Force || info.LastAccessTime.Before(params.Deadline)) {
 		return nil
 	}
 
@@ -250,11 +288,14 @@ func (info *RuntimeInfo) opStopCheck(args interface{}) error {
 }
 
 // opStopSet
-func (info *RuntimeInfo) opStopSet(interface{}) error {
-	info.CommitID = ""
+func (info *RuntimeInfo) opStopSet(args interface{}) error {
+	params := args.(*StopInput)
+	info.SetCommitID("")
+	info.Provisioned = false
 	info.UserID = ""
 	info.Concurrency = 0
 	info.ConcurrentMode = info.DefaultConcurrentMode
+	info.evicting = params.Evict
 	info.SetState(RuntimeStateStopping)
 	close(info.runtimeStoppingChan)
 	return nil
@@ -269,7 +310,8 @@ type ResetInput struct {
 func (info *RuntimeInfo) opResetCheck(args interface{}) error {
 	params := args.(*ResetInput)
 
-	if info.IsRunnerDefunct(params.Deadline) {
+	// the runner of an unresponsive runtime is still alive, it would never be defunct
+	if info.Unresponsive || info.IsRunnerDefunct(params.Deadline) || info.isRestoreExpired() {
 		return nil
 	}
 
@@ -280,22 +322,147 @@ func (info *RuntimeInfo) opResetCheck(args interface{}) error {
 
 // opStopSet
 func (info *RuntimeInfo) opResetSet(interface{}) error {
-	info.CommitID = ""
+	info.restore = nil
+	info.restoredAt = time.Time{}
+	info.SetCommitID("")
+	info.Provisioned = false
 	info.UserID = ""
 	info.Concurrency = 0
 	info.ConcurrentMode = info.DefaultConcurrentMode
+	info.Unresponsive = false
+	info.ProbeFailures = 0
+	info.runtimeAPIToken = ""
+	select {
+	case <-info.initFailChan:
+		info.initFailChan = make(chan struct{})
+	default:
+	}
 	info.updateLastResetTime()
 	return nil
 }
 
+///////////////////////////////freeze event
+
+type FreezeInput struct {
+	Deadline time.Time
+}
+
+func (info *RuntimeInfo) opFreezeCheck(args interface{}) error {
+	if info.State != RuntimeStateWarm {
+		return &RuntimeStateUnmatched{
+			RuntimeID:     info.RuntimeID,
+			CurrentState:  info.State,
+			ExpectedState: []RuntimeStateType{RuntimeStateWarm},
+		}
+	}
+
+	params := args.(*FreezeInput)
+
+	if !info.available() {
+		return &RuntimeMatchError{
+			Reason: "runner is not available",
+		}
+	}
+
+	if info.Provisioned {
+		return &RuntimeMatchError{
+			Reason: "runtime is provisioned",
+		}
+	}
+
+	if info.Concurrency == 0 && info.LastAccessTime.Before(params.Deadline) {
+		return nil
+	}
+
+	return &RuntimeMatchError{
+		Reason: "no need to freeze",
+	}
+}
+
+// opFreezeSet
+func (info *RuntimeInfo) opFreezeSet(interface{}) error {
+	info.SetState(RuntimeStateFrozen)
+	return nil
+}
+
+///////////////////////////////thaw event
+
+func (info *RuntimeInfo) opThawCheck(args interface{}) error {
+	if info.State != RuntimeStateFrozen {
+		return &RuntimeStateUnmatched{
+			RuntimeID:     info.RuntimeID,
+			CurrentState:  info.State,
+			ExpectedState: []RuntimeStateType{RuntimeStateFrozen},
+		}
+	}
+
+	params := args.(*MarkInput)
+
+	if !info.available() {
+		return &RuntimeMatchError{
+			Reason: "runner is not available",
+		}
+	}
+
+	if info.CommitID != params.CommitID {
+		return &RuntimeMatchError{
+			Reason: "commit id not match",
+		}
+	}
+	return nil
+}
+
+// opThawSet: the thawing request occupies the runtime
+func (info *RuntimeInfo) opThawSet(interface{}) error {
+	info.SetState(RuntimeStateWarm)
+	info.updateLastAccessTime()
+	info.Concurrency++
+	logs.V(6).Infof("thaw runtime %s concurrency %d", info.RuntimeID, info.Concurrency)
+	return nil
+}
+
+///////////////////////////////restore event
+
+type RestoreInput struct {
+	CommitID       string
+	MemorySize     uint64
+	MilliCPUs      int64
+	ConcurrentMode bool
+}
+
+func (info *RuntimeInfo) opRestoreCheck(interface{}) error {
+	if info.State != RuntimeStateClosed {
+		return &RuntimeStateUnmatched{
+			RuntimeID:     info.RuntimeID,
+			CurrentState:  info.State,
+			ExpectedState: []RuntimeStateType{RuntimeStateClosed},
+		}
+	}
+	if info.restore != nil {
+		return &RuntimeMatchError{
+			Reason: "runtime is restored",
+		}
+	}
+	return nil
+}
+
+// opRestoreSet: the runtime keeps closed until its runner reconnects
+func (info *RuntimeInfo) opRestoreSet(args interface{}) error {
+	params := args.(*RestoreInput)
+	info.restore = params
+	info.SetCommitID(params.CommitID)
+	info.SetResource(params.MemorySize, params.MilliCPUs)
+	info.ConcurrentMode = params.ConcurrentMode
+	info.updateLastAccessTime()
+	info.SetMarked(true)
+	return nil
+}
+
 // CAS check and set runtime info
 func (info *RuntimeInfo) CAS(opType CASOpType, args interface{}) (err error) {
 	op := casOps[opType]
 
-	if err = op.check(info, args); err != nil {
-		return
-	}
-
+	// the fields are checked under the lock only, they are updated by the other requests concurrently
 	info.invokeLock.Lock()
 	defer info.invokeLock.Unlock()
 
@@ -324,6 +491,9 @@ const (
 	OpStop
 	OpReset
 	OpClose
+	OpFreeze
+	OpThaw
+	OpRestore
 	OpEnd
 )
 
@@ -391,16 +561,47 @@ func init() {
 		check: nil,
 		set:   nil,
 	}
+
+	casOps[OpFreeze] = &runtimeEvent{
+		name:  "freeze",
+		check: (*RuntimeInfo).opFreezeCheck,
+		set:   (*RuntimeInfo).opFreezeSet,
+	}
+
+	casOps[OpThaw] = &runtimeEvent{
+		name:  "thaw",
+		check: (*RuntimeInfo).opThawCheck,
+		set:   (*RuntimeInfo).opThawSet,
+	}
+
+	casOps[OpRestore] = &runtimeEvent{
+		name:  "restore",
+		check: (*RuntimeInfo).opRestoreCheck,
+		set:   (*RuntimeInfo).opRestoreSet,
+	}
 }
 
 // Release: release the occupation of runtime
+// the released runtime is handed to the next request waiting for it
 func (info *RuntimeInfo) Release() error {
+	commitID, err := info.release()
+	if err != nil {
+		return err
+	}
+	if info.releaseHook != nil {
+		info.releaseHook(info, commitID)
+	}
+	return nil
+}
+
+// release returns the commit id of the runtime when it is released
+func (info *RuntimeInfo) release() (string, error) {
 	info.invokeLock.Lock()
 	defer info.invokeLock.Unlock()
 
 	if info.Concurrency == 0 {
 		logs.Errorf("can't release runtime %s", info.RuntimeID)
-		return &RuntimeReleaseError{
+		return "", &RuntimeReleaseError{
 			RuntimeID: info.RuntimeID,
 			Reason:    "runtime concurrency has been 0",
 		}
@@ -410,5 +611,5 @@ func (info *RuntimeInfo) Release() error {
 	//info.updateLastAccessTime()
 	info.Concurrency--
 	logs.V(5).Infof("release runtime %s concurrency %d", info.RuntimeID, info.Concurrency)
-	return nil
+	return info.CommitID, nil
 }
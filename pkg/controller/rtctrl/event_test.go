@@ -156,7 +156,7 @@ func TestEventStop(t *testing.T) {
 	expectedErr2 := &RuntimeStateUnmatched{
 		RuntimeID:     rt.RuntimeID,
 		CurrentState:  RuntimeStateClosed,
-		ExpectedState: []string{RuntimeStateWarm},
+		ExpectedState: []string{RuntimeStateWarm, RuntimeStateFrozen},
 	}
 	if err2.Error() != expectedErr2.Error() {
 		t.Errorf("stop runtime expected %s, but got %s", expectedErr2, err2)
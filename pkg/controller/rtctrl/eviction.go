@@ -0,0 +1,166 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rtctrl
+
+import (
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/baidu/easyfaas/pkg/api"
+	"github.com/baidu/easyfaas/pkg/util/logs/metric"
+)
+
+const (
+	EvictionPolicyNone = "none"
+	EvictionPolicyLRU  = "lru"
+	EvictionPolicyLFU  = "lfu"
+)
+
+// EvictionCandidate is a snapshot of a warm runtime taken under its lock,
+// so that the order of the candidates does not change while they are sorted
+type EvictionCandidate struct {
+	Runtime        *RuntimeInfo
+	LastAccessTime time.Time
+	AcceptReqCnt   int64
+}
+
+// EvictionPolicy decides which idle warm runtime is evicted first
+type EvictionPolicy interface {
+	Name() string
+	// Less reports whether candidate a should be evicted before candidate b
+	Less(a, b *EvictionCandidate) bool
+}
+
+// lruPolicy evicts the least recently used runtime
+type lruPolicy struct{}
+
+func (lruPolicy) Name() string {
+	return EvictionPolicyLRU
+}
+
+func (lruPolicy) Less(a, b *EvictionCandidate) bool {
+	return a.LastAccessTime.Before(b.LastAccessTime)
+}
+
+// lfuPolicy evicts the runtime which accepted the fewest requests
+type lfuPolicy struct{}
+
+func (lfuPolicy) Name() string {
+	return EvictionPolicyLFU
+}
+
+func (lfuPolicy) Less(a, b *EvictionCandidate) bool {
+	if a.AcceptReqCnt == b.AcceptReqCnt {
+		return a.LastAccessTime.Before(b.LastAccessTime)
+	}
+	return a.AcceptReqCnt < b.AcceptReqCnt
+}
+
+var evictionPolicies = map[string]EvictionPolicy{
+	EvictionPolicyLRU: lruPolicy{},
+	EvictionPolicyLFU: lfuPolicy{},
+}
+
+// RegisterEvictionPolicy makes a custom policy available by its name
+func RegisterEvictionPolicy(policy EvictionPolicy) {
+	evictionPolicies[policy.Name()] = policy
+}
+
+// GetEvictionPolicy returns the policy registered with the name, nil means eviction is disabled
+func GetEvictionPolicy(name string) (EvictionPolicy, error) {
+	if name == "" || name == EvictionPolicyNone {
+		return nil, nil
+	}
+	policy, ok := evictionPolicies[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown eviction policy %s", name)
+	}
+	return policy, nil
+}
+
+// EvictRuntime stops an idle warm runtime of another function and keeps it for the request,
+// the caller should cool it down and occupy it by OccupyEvictedRuntime, or give it up by CancelEviction
+func (m *RuntimeManager) EvictRuntime(req *InvocationInput) (victim *RuntimeInfo, recommend *api.ScaleDownRecommendation, err error) {
+	commitID := ""
+	if req.Configuration.CommitID != nil {
+		commitID = *req.Configuration.CommitID
+	}
+	if m.evictionPolicy == nil {
+		return nil, nil, NoRuntimeToEvict{CommitID: commitID}
+	}
+	runtimes := m.index.warmRuntimes(commitID)
+	candidates := make([]*EvictionCandidate, 0, len(runtimes))
+	for _, rt := range runtimes {
+		candidates = append(candidates, rt.evictionCandidate())
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return m.evictionPolicy.Less(candidates[i], candidates[j])
+	})
+	for _, c := range candidates {
+		rt := c.Runtime
+		if err := rt.CAS(OpStop, &StopInput{Force: true, Evict: true}); err != nil {
+			continue
+		}
+		metric.Inc(evictionsIndex, m.evictionPolicy.Name())
+		recommend, err = m.scaleDownRecommendation(rt)
+		return rt, recommend, err
+	}
+	return nil, nil, NoRuntimeToEvict{CommitID: commitID}
+}
+
+func (info *RuntimeInfo) evictionCandidate() *EvictionCandidate {
+	info.invokeLock.Lock()
+	defer info.invokeLock.Unlock()
+	return &EvictionCandidate{
+		Runtime:        info,
+		LastAccessTime: info.LastAccessTime,
+		AcceptReqCnt:   atomic.LoadInt64(&info.AcceptReqCnt),
+	}
+}
+
+// WaitCold waits for the runtime to come back as a cold one after it is reset
+func (info *RuntimeInfo) WaitCold(timeout time.Duration) bool {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		if info.isCold() {
+			return true
+		}
+		select {
+		case <-ticker.C:
+		case <-deadline.C:
+			return false
+		}
+	}
+}
+
+func (info *RuntimeInfo) isCold() bool {
+	info.invokeLock.Lock()
+	defer info.invokeLock.Unlock()
+	return info.State == RuntimeStateCold && !info.Abnormal
+}
+
+// CancelEviction gives the evicted runtime back to other requests
+func (info *RuntimeInfo) CancelEviction() {
+	info.invokeLock.Lock()
+	defer info.invokeLock.Unlock()
+	info.evicting = false
+}
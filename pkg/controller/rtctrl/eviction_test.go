@@ -0,0 +1,103 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rtctrl
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func warmRuntimes(rtMap *RuntimeManager, commitIDs ...string) []*RuntimeInfo {
+	runtimes := make([]*RuntimeInfo, 0, len(commitIDs))
+	for _, commitID := range commitIDs {
+		rt, _ := rtMap.OccupyColdRuntime(waitInput(commitID))
+		rt.SetState(RuntimeStateWarm)
+		rt.Release()
+		runtimes = append(runtimes, rt)
+	}
+	return runtimes
+}
+
+func TestEvictRuntimeLFU(t *testing.T) {
+	rtMap := initRuntimeList(3)
+	rtMap.evictionPolicy, _ = GetEvictionPolicy(EvictionPolicyLFU)
+	runtimes := warmRuntimes(rtMap, "commitID-a", "commitID-b", "commitID-c")
+	runtimes[0].AcceptReqCnt = 5
+	runtimes[1].AcceptReqCnt = 1
+	runtimes[2].AcceptReqCnt = 3
+
+	// runtimes of the requested function are never evicted
+	victim, _, err := rtMap.EvictRuntime(waitInput("commitID-b"))
+	assert.Nil(t, err)
+	assert.Equal(t, runtimes[2], victim)
+	assert.Equal(t, RuntimeStateStopping, victim.State)
+	assert.Nil(t, rtMap.index.warmCandidates("commitID-c"))
+
+	victim, _, err = rtMap.EvictRuntime(waitInput("commitID-new"))
+	assert.Nil(t, err)
+	assert.Equal(t, runtimes[1], victim)
+}
+
+func TestEvictRuntimeSkipsProvisioned(t *testing.T) {
+	rtMap := initRuntimeList(1)
+	rtMap.evictionPolicy, _ = GetEvictionPolicy(EvictionPolicyLRU)
+	rt := warmRuntimes(rtMap, "commitID-a")[0]
+	rt.SetProvisioned(true)
+	_, _, err := rtMap.EvictRuntime(waitInput("commitID-new"))
+	assert.Equal(t, NoRuntimeToEvict{CommitID: "commitID-new"}, err)
+}
+
+func TestOccupyEvictedRuntime(t *testing.T) {
+	rtMap := initRuntimeList(1)
+	rtMap.evictionPolicy, _ = GetEvictionPolicy(EvictionPolicyLRU)
+	warmRuntimes(rtMap, "commitID-a")
+	victim, _, err := rtMap.EvictRuntime(waitInput("commitID-new"))
+	assert.Nil(t, err)
+	victim.SetState(RuntimeStateCold)
+
+	// the evicted runtime is kept for the request evicting it
+	rt, _ := rtMap.OccupyColdRuntime(waitInput("commitID-other"))
+	assert.Nil(t, rt)
+	rt, _ = rtMap.OccupyEvictedRuntime(victim, waitInput("commitID-new"))
+	assert.Equal(t, victim, rt)
+	assert.Equal(t, "commitID-new", rt.CommitID)
+	assert.False(t, rt.evicting)
+}
+
+func TestCancelEviction(t *testing.T) {
+	rtMap := initRuntimeList(1)
+	rtMap.evictionPolicy, _ = GetEvictionPolicy(EvictionPolicyLRU)
+	warmRuntimes(rtMap, "commitID-a")
+	victim, _, _ := rtMap.EvictRuntime(waitInput("commitID-new"))
+	victim.SetState(RuntimeStateCold)
+	victim.CancelEviction()
+	rt, _ := rtMap.OccupyColdRuntime(waitInput("commitID-other"))
+	assert.Equal(t, victim, rt)
+}
+
+func TestGetEvictionPolicy(t *testing.T) {
+	policy, err := GetEvictionPolicy(EvictionPolicyNone)
+	assert.Nil(t, policy)
+	assert.Nil(t, err)
+	_, err = GetEvictionPolicy("fifo")
+	assert.NotNil(t, err)
+	policy, _ = GetEvictionPolicy(EvictionPolicyLRU)
+	older := &EvictionCandidate{LastAccessTime: time.Now().Add(-time.Minute)}
+	assert.True(t, policy.Less(older, &EvictionCandidate{LastAccessTime: time.Now()}))
+}
@@ -0,0 +1,98 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rtctrl
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/baidu/easyfaas/pkg/util/logs"
+)
+
+// Freezer freezes and thaws the processes of runtimes
+type Freezer interface {
+	Freeze(rt *RuntimeInfo) error
+	Thaw(rt *RuntimeInfo) error
+}
+
+// SetFreezer
+func (m *RuntimeManager) SetFreezer(freezer Freezer) {
+	m.freezer = freezer
+}
+
+// FreezeRuntime freezes the warm runtime idle longer than RuntimeFreezeIdle
+func (m *RuntimeManager) FreezeRuntime(rt *RuntimeInfo) error {
+	if m.freezer == nil || m.RuntimeFreezeIdle <= 0 {
+		return fmt.Errorf("runtime freezing is disabled")
+	}
+	deadline := time.Now().Add(-time.Duration(m.RuntimeFreezeIdle) * time.Second)
+	if err := rt.CAS(OpFreeze, &FreezeInput{Deadline: deadline}); err != nil {
+		return err
+	}
+
+	rt.freezeLock.Lock()
+	defer rt.freezeLock.Unlock()
+	// thawed by a request before its processes are frozen
+	if !rt.isFrozen() {
+		return nil
+	}
+	if err := m.freezer.Freeze(rt); err != nil {
+		rt.unfreeze()
+		return err
+	}
+	logs.V(5).Infof("runtime %s frozen", rt.RuntimeID)
+	return nil
+}
+
+// thawRuntime occupies the frozen runtime and thaws it before dispatch
+func (m *RuntimeManager) thawRuntime(rt *RuntimeInfo, input *MarkInput) bool {
+	if m.freezer == nil {
+		return false
+	}
+	if err := rt.CAS(OpThaw, input); err != nil {
+		return false
+	}
+
+	rt.freezeLock.Lock()
+	err := m.freezer.Thaw(rt)
+	rt.freezeLock.Unlock()
+	if err != nil {
+		logs.Errorf("thaw runtime %s failed: %s", rt.RuntimeID, err)
+		rt.Invalidate()
+		if err := rt.Release(); err != nil {
+			logs.Errorf("release runtime %s failed: %s", rt.RuntimeID, err)
+		}
+		return false
+	}
+	return true
+}
+
+// isFrozen reads the state of the runtime under its lock
+func (info *RuntimeInfo) isFrozen() bool {
+	info.invokeLock.Lock()
+	defer info.invokeLock.Unlock()
+	return info.State == RuntimeStateFrozen
+}
+
+// unfreeze restores the runtime failed to be frozen
+func (info *RuntimeInfo) unfreeze() {
+	info.invokeLock.Lock()
+	defer info.invokeLock.Unlock()
+	if info.State == RuntimeStateFrozen {
+		info.SetState(RuntimeStateWarm)
+	}
+}
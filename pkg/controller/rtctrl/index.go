@@ -0,0 +1,175 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package rtctrl
+package rtctrl
+
+import (
+	"sync"
+)
+
+const (
+	// coldBatchSize: number of cold runtimes tried in one round
+	coldBatchSize = 8
+)
+
+// runtimeSet keeps runtimes in insertion order with O(1) add and remove
+type runtimeSet struct {
+	items []*RuntimeInfo
+	pos   map[*RuntimeInfo]int
+}
+
+func newRuntimeSet() *runtimeSet {
+	return &runtimeSet{
+		items: make([]*RuntimeInfo, 0),
+		pos:   make(map[*RuntimeInfo]int),
+	}
+}
+
+func (s *runtimeSet) add(rt *RuntimeInfo) {
+	if _, ok := s.pos[rt]; ok {
+		return
+	}
+	s.pos[rt] = len(s.items)
+	s.items = append(s.items, rt)
+}
+
+func (s *runtimeSet) remove(rt *RuntimeInfo) {
+	i, ok := s.pos[rt]
+	if !ok {
+		return
+	}
+	last := len(s.items) - 1
+	if i != last {
+		s.items[i] = s.items[last]
+		s.pos[s.items[i]] = i
+	}
+	s.items[last] = nil
+	s.items = s.items[:last]
+	delete(s.pos, rt)
+}
+
+func (s *runtimeSet) len() int {
+	return len(s.items)
+}
+
+// runtimeIndex indexes the runtimes serving each function and the free cold runtimes,
+// it is updated by runtime state transitions while the runtime lock is held
+type runtimeIndex struct {
+	lock sync.RWMutex
+	warm map[string]*runtimeSet
+	cold *runtimeSet
+	// coldFreed is called when a runtime joins the free cold runtimes, it must not block
+	coldFreed func()
+}
+
+func newRuntimeIndex() *runtimeIndex {
+	return &runtimeIndex{
+		warm: make(map[string]*runtimeSet),
+		cold: newRuntimeSet(),
+	}
+}
+
+func isWarmIndexed(state RuntimeStateType, commitID string) bool {
+	return commitID != "" && (state == RuntimeStateWarmUp || state == RuntimeStateWarm || state == RuntimeStateFrozen)
+}
+
+func isColdIndexed(state RuntimeStateType, abnormal bool) bool {
+	return state == RuntimeStateCold && !abnormal
+}
+
+// update moves the runtime from the buckets of its old state and commit id to the current ones
+func (x *runtimeIndex) update(rt *RuntimeInfo, oldState RuntimeStateType, oldCommitID string) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+
+	if isWarmIndexed(oldState, oldCommitID) {
+		if set, ok := x.warm[oldCommitID]; ok {
+			set.remove(rt)
+			if set.len() == 0 {
+				delete(x.warm, oldCommitID)
+			}
+		}
+	}
+	_, wasCold := x.cold.pos[rt]
+	x.cold.remove(rt)
+
+	if isWarmIndexed(rt.State, rt.CommitID) {
+		set, ok := x.warm[rt.CommitID]
+		if !ok {
+			set = newRuntimeSet()
+			x.warm[rt.CommitID] = set
+		}
+		set.add(rt)
+	}
+	if isColdIndexed(rt.State, rt.Abnormal) {
+		x.cold.add(rt)
+		if !wasCold && x.coldFreed != nil {
+			x.coldFreed()
+		}
+	}
+}
+
+// warmCandidates returns the runtimes serving the function
+func (x *runtimeIndex) warmCandidates(commitID string) []*RuntimeInfo {
+	x.lock.RLock()
+	defer x.lock.RUnlock()
+	set, ok := x.warm[commitID]
+	if !ok {
+		return nil
+	}
+	candidates := make([]*RuntimeInfo, set.len())
+	copy(candidates, set.items)
+	return candidates
+}
+
+// warmRuntimes returns the runtimes serving functions other than the excluded one
+func (x *runtimeIndex) warmRuntimes(excludeCommitID string) []*RuntimeInfo {
+	x.lock.RLock()
+	defer x.lock.RUnlock()
+	candidates := make([]*RuntimeInfo, 0)
+	for commitID, set := range x.warm {
+		if commitID == excludeCommitID {
+			continue
+		}
+		candidates = append(candidates, set.items...)
+	}
+	return candidates
+}
+
+// coldCandidates returns the most recently freed cold runtimes, skipping the first offset of them
+func (x *runtimeIndex) coldCandidates(offset, limit int) []*RuntimeInfo {
+	x.lock.RLock()
+	defer x.lock.RUnlock()
+	n := x.cold.len() - offset
+	if n > limit {
+		n = limit
+	}
+	if n <= 0 {
+		return nil
+	}
+	candidates := make([]*RuntimeInfo, n)
+	for i := 0; i < n; i++ {
+		candidates[i] = x.cold.items[x.cold.len()-1-offset-i]
+	}
+	return candidates
+}
+
+func (x *runtimeIndex) coldCount() int {
+	x.lock.RLock()
+	defer x.lock.RUnlock()
+	return x.cold.len()
+}
@@ -0,0 +1,138 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rtctrl
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuntimeIndexTransitions(t *testing.T) {
+	rtMap := initRuntimeList(3)
+	assert.Equal(t, 3, rtMap.ColdRuntimeCount())
+
+	input := waitInput("commitID-index")
+	rt, _ := rtMap.OccupyColdRuntime(input)
+	assert.NotNil(t, rt)
+	assert.Equal(t, 2, rtMap.ColdRuntimeCount())
+	assert.Equal(t, []*RuntimeInfo{rt}, rtMap.index.warmCandidates("commitID-index"))
+
+	rt.SetState(RuntimeStateWarm)
+	rt.Release()
+	assert.Equal(t, rt, rtMap.FindWarmRuntime(input))
+	rt.Release()
+
+	// a stopped runtime leaves the warm bucket and returns to the free list once restarted
+	assert.Nil(t, rt.CAS(OpStop, &StopInput{Deadline: time.Now().Add(time.Second)}))
+	assert.Nil(t, rtMap.index.warmCandidates("commitID-index"))
+	assert.Equal(t, 2, rtMap.ColdRuntimeCount())
+	rt.SetState(RuntimeStateCold)
+	assert.Equal(t, 3, rtMap.ColdRuntimeCount())
+
+	// an invalidated runtime is not offered as a cold candidate
+	rt.Invalidate()
+	assert.Equal(t, 2, rtMap.ColdRuntimeCount())
+	for _, c := range rtMap.index.coldCandidates(0, coldBatchSize) {
+		assert.NotEqual(t, rt, c)
+	}
+}
+
+func TestRuntimeIndexRollback(t *testing.T) {
+	rtMap := initRuntimeList(1)
+	rt, _ := rtMap.OccupyColdRuntime(waitInput("commitID-rollback"))
+	assert.NotNil(t, rt)
+	rt.CAS(OpRollback, &RollbackInput{CommitID: "commitID-rollback"})
+	assert.Nil(t, rtMap.index.warmCandidates("commitID-rollback"))
+	assert.Equal(t, 1, rtMap.ColdRuntimeCount())
+}
+
+func TestClaimColdRuntimeBeyondFailures(t *testing.T) {
+	rtMap := initRuntimeList(2*coldBatchSize + 1)
+	// the most recently freed runtimes are kept for the requests evicting them
+	var free *RuntimeInfo
+	for _, rt := range rtMap.RuntimeList() {
+		if rt.RuntimeID == "runtime-0" {
+			free = rt
+			continue
+		}
+		rt.invokeLock.Lock()
+		rt.evicting = true
+		rt.invokeLock.Unlock()
+	}
+	rt, _ := rtMap.OccupyColdRuntime(waitInput("commitID-claim"))
+	assert.Equal(t, free, rt)
+}
+
+// benchmarkIndexedLookup warms one runtime of the target function among a pool
+// filled with other functions, so that lookup cost can be compared across pool sizes
+func benchmarkIndexedLookup(b *testing.B, poolSize int, cold bool) {
+	rtMap := initRuntimeList(poolSize)
+	for i := 0; i < poolSize-1; i++ {
+		rt, _ := rtMap.OccupyColdRuntime(waitInput("commitID-other-" + strconv.Itoa(i)))
+		rt.Release()
+	}
+	input := waitInput("commitID-target")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if cold {
+			rt, _ := rtMap.OccupyColdRuntime(input)
+			if rt == nil {
+				b.Fatal("empty runtime")
+			}
+			rt.CAS(OpRollback, &RollbackInput{CommitID: "commitID-target"})
+			continue
+		}
+		if i == 0 {
+			b.StopTimer()
+			rt, _ := rtMap.OccupyColdRuntime(input)
+			rt.Release()
+			b.StartTimer()
+		}
+		rt := rtMap.FindWarmRuntime(input)
+		if rt == nil {
+			b.Fatal("empty runtime")
+		}
+		rt.Release()
+	}
+}
+
+func BenchmarkFindWarmRuntime_100Rt(b *testing.B) {
+	benchmarkIndexedLookup(b, 100, false)
+}
+
+func BenchmarkFindWarmRuntime_1000Rt(b *testing.B) {
+	benchmarkIndexedLookup(b, 1000, false)
+}
+
+func BenchmarkFindWarmRuntime_5000Rt(b *testing.B) {
+	benchmarkIndexedLookup(b, 5000, false)
+}
+
+func BenchmarkOccupyColdRuntime_100Rt(b *testing.B) {
+	benchmarkIndexedLookup(b, 100, true)
+}
+
+func BenchmarkOccupyColdRuntime_1000Rt(b *testing.B) {
+	benchmarkIndexedLookup(b, 1000, true)
+}
+
+func BenchmarkOccupyColdRuntime_5000Rt(b *testing.B) {
+	benchmarkIndexedLookup(b, 5000, true)
+}
@@ -19,6 +19,7 @@ package rtctrl
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
 	"sync"
 
@@ -30,6 +31,10 @@ const (
 	TabByte  byte = '\t'
 	LineByte byte = '\n'
 
+	// FrameByte and FrameIDByte enclose the request id a framed log line is tagged with
+	FrameByte   byte = '\x1e'
+	FrameIDByte byte = '\x1f'
+
 	easyfaasSysLog = 0
 	StdoutLog      = 1
 	StderrLog      = 2
@@ -65,12 +70,18 @@ func (lsm *logStatStoreMap) NewStdLogDispatcher(runtimeID string, logFrom int, c
 	}
 }
 
+// Read dispatches the std log of the runtime to the log stores of the requests.
+// A framed line \x1e<request id>\x1f<line> goes to the store of the request it is tagged with;
+// lines without frame from legacy runtimes go to the store of the last request, or the one
+// named by the second tab separated field.
 func (d *stdLogDispatcher) Read() {
 	buf := make([]byte, d.bufferLength+1)
 	next := 0     // offset
 	lnTabCnt := 0 // tab count in recent line
 	var lnReqID string
 	var ls LogStatStore
+	var fs LogStatStore // log store the framed line in process is tagged with
+	framed := false     // whether the line in process is framed
 	for {
 
 		// bytes count from connection
@@ -82,22 +93,17 @@ func (d *stdLogDispatcher) Read() {
 		}
 
 		// if log store map is empty, discard the data
-		if len(d.logStoreMap.storeMap) == 0 {
+		if d.logStoreMap.empty() {
 			d.logger.Warn("can't find log store map")
 			next = 0
+			fs, framed = nil, false
 			continue
 		}
 
-		// if can't get the log store of the last request, discard the data
+		// if can't get the log store of the last request, unframed lines are discarded
 		ls, err = d.logStoreMap.getLast()
 		if err != nil {
 			d.logger.Warnf("get last log store of %s failed", d.logStoreMap.String())
-			next = 0
-			continue
-		}
-		if ls == nil {
-			next = 0
-			continue
 		}
 
 		next += read
@@ -108,50 +114,67 @@ func (d *stdLogDispatcher) Read() {
 
 		// parse data
 		for i := 0; i < next; i++ {
-			if buf[i] == ZeroByte { // \0 means the end of the request
+			cur := logStoreOf(ls, fs, framed)
+			if i == lastln+1 && buf[i] == FrameByte && !framed {
+				end := bytes.IndexByte(buf[i+1:next], FrameIDByte)
+				if end < 0 { // wait for the whole frame header
+					break
+				}
+				lnReqID = string(buf[i+1 : i+1+end])
+				d.logger.V(6).Infof("parse frame request id %s", lnReqID)
+				framed = true
+				if fs = d.logStoreMap.get(lnReqID); fs == nil {
+					d.logger.Errorf("map %s can't find log store of framed request %s", d.logStoreMap.String(), lnReqID)
+				}
+				copy(buf[i:], buf[i+end+2:next]) // remove frame header
+				next -= end + 2
+				i--
+			} else if buf[i] == ZeroByte { // \0 means the end of the request
 				d.logger.V(9).Infof("next %d lastln %d i %d nzero %d", next, lastln, i, nzero)
 				nzero++
 				copy(buf[i:], buf[i+1:next]) // remove \0
 				i--
 				next--
-				nwrite, err := ls.WriteStdLog(d.logFrom, buf[lastln+1:i+1], true)
+				nwrite, err := d.write(cur, buf[lastln+1:i+1], true)
 				// write error: discard the log data, start the new line process
 				// write all success: start the new line process
 				if err != nil || nwrite == i-lastln {
 					if err != nil {
-						d.logger.Errorf("write std log %s len %d with eof lastln %d i %d failed: %s", ls.String(), nwrite, lastln, i, err)
+						d.logger.Errorf("write std log %s len %d with eof lastln %d i %d failed: %s", cur, nwrite, lastln, i, err)
 					} else {
-						d.logger.V(6).Infof("write std log %s len %d with eof lastln %d i %d", ls.String(), nwrite, lastln, i)
+						d.logger.V(6).Infof("write std log %s len %d with eof lastln %d i %d", cur, nwrite, lastln, i)
 					}
 					lastln = i
 					lnTabCnt = 0
 					lastTab = -1
+					fs, framed = nil, false
 					continue
 				}
 				// write partial success: adjust the next pointer
-				d.logger.Warnf("write partial std log %s len %d with eof lastln %d i %d", ls.String(), nwrite, lastln, i)
+				d.logger.Warnf("write partial std log %s len %d with eof lastln %d i %d", cur, nwrite, lastln, i)
 				i = lastln + nwrite
 			} else if buf[i] == LineByte {
 				d.logger.V(9).Infof("next %d lastln %d i %d nzero %d", next, lastln, i, nzero)
-				nwrite, err := ls.WriteStdLog(d.logFrom, buf[lastln+1:i+1], false)
+				nwrite, err := d.write(cur, buf[lastln+1:i+1], false)
 				// write error: discard the log data, start the new line process
 				// write all success: start the new line process
 				if err != nil || nwrite == i-lastln {
 					if err != nil {
-						d.logger.Errorf("write std log %s len %d lastln %d i %d failed: %s", ls.String(), nwrite, lastln, i, err)
+						d.logger.Errorf("write std log %s len %d lastln %d i %d failed: %s", cur, nwrite, lastln, i, err)
 					} else {
-						d.logger.V(6).Infof("write std log %s len %d lastln %d i %d", ls.String(), nwrite, lastln, i)
+						d.logger.V(6).Infof("write std log %s len %d lastln %d i %d", cur, nwrite, lastln, i)
 					}
 					// mark as new line process
 					lastln = i
 					lnTabCnt = 0
 					lastTab = -1
+					fs, framed = nil, false
 					continue
 				}
 				// write partial success: adjust the next pointer
-				d.logger.Warnf("write partial std log %s len %d lastln %d i %d", ls.String(), nwrite, lastln, i)
+				d.logger.Warnf("write partial std log %s len %d lastln %d i %d", cur, nwrite, lastln, i)
 				i = lastln + nwrite
-			} else if buf[i] == TabByte {
+			} else if buf[i] == TabByte && !framed {
 				d.logger.V(9).Infof("next %d lastln %d i %d nzero %d", next, lastln, i, nzero)
 				lnTabCnt++
 				if lnTabCnt == 2 {
@@ -161,7 +184,7 @@ func (d *stdLogDispatcher) Read() {
 					if lls != nil {
 						ls = lls
 					} else {
-						d.logger.Errorf("map %s can't find log store of request %s", ls.String(), lnReqID)
+						d.logger.Errorf("map %s can't find log store of request %s", d.logStoreMap.String(), lnReqID)
 					}
 				}
 				lastTab = i
@@ -173,8 +196,9 @@ func (d *stdLogDispatcher) Read() {
 		// when buffer is full, add \n character; otherwise, continue read from buffer
 		if nzero == 0 && lastln < 0 {
 			if next == d.bufferLength {
+				cur := logStoreOf(ls, fs, framed)
 				buf[next] = LineByte
-				nwrite, err := ls.WriteStdLog(d.logFrom, buf[lastln+1:next+1], false)
+				nwrite, err := d.write(cur, buf[lastln+1:next+1], false)
 				if err != nil || nwrite == next-lastln {
 					if err != nil {
 						d.logger.Errorf("buffer full write std log len %d err %s with eof lastln %d next %d", nwrite, err, lastln, next, err)
@@ -182,8 +206,9 @@ func (d *stdLogDispatcher) Read() {
 						d.logger.V(6).Infof("buffer full write std log len %d err %s with eof lastln %d next %d", nwrite, err, lastln, next)
 					}
 					next = 0
+					fs, framed = nil, false
 				} else if nwrite > 0 {
-					d.logger.Warnf("buffer full write partial std log len %d err %s with eof lastln %d next %d", ls.String(), nwrite, lastln, next)
+					d.logger.Warnf("buffer full write partial std log len %d err %s with eof lastln %d next %d", cur, nwrite, lastln, next)
 					next -= nwrite
 					copy(buf[0:], buf[nwrite:next+1])
 				}
@@ -204,6 +229,23 @@ func (d *stdLogDispatcher) Read() {
 	}
 }
 
+// logStoreOf: framed lines only go to the log store of their request,
+// they are discarded when it is gone instead of being written to the last request
+func logStoreOf(last LogStatStore, framedStore LogStatStore, framed bool) LogStatStore {
+	if framed {
+		return framedStore
+	}
+	return last
+}
+
+// write writes the log line to the store, the line is discarded when there is no store
+func (d *stdLogDispatcher) write(store LogStatStore, data []byte, eof bool) (int, error) {
+	if store == nil {
+		return len(data), nil
+	}
+	return store.WriteStdLog(d.logFrom, data, eof)
+}
+
 type logStatStoreMap struct {
 	runtimeID string
 	lastReqID string
@@ -236,6 +278,12 @@ func (ls *logStatStoreMap) get(requestID string) (store LogStatStore) {
 	return store
 }
 
+func (ls *logStatStoreMap) empty() bool {
+	ls.lock.RLock()
+	defer ls.lock.RUnlock()
+	return len(ls.storeMap) == 0
+}
+
 func (ls *logStatStoreMap) getLast() (store LogStatStore, err error) {
 	ls.lock.RLock()
 	defer ls.lock.RUnlock()
@@ -260,7 +308,9 @@ func (ls *logStatStoreMap) del(requestID string, store LogStatStore) {
 	}
 	if old == store {
 		delete(ls.storeMap, requestID)
-		ls.lastReqID = ""
+		if ls.lastReqID == requestID {
+			ls.lastReqID = ""
+		}
 	}
 	return
 }
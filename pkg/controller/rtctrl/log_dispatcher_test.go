@@ -0,0 +1,110 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package rtctrl
+package rtctrl
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeLogStore struct {
+	LogStatStore
+	name string
+	logs bytes.Buffer
+	eof  int
+}
+
+func (s *fakeLogStore) String() string {
+	return s.name
+}
+
+func (s *fakeLogStore) WriteStdLog(from int, buf []byte, eof bool) (int, error) {
+	s.logs.Write(buf)
+	if eof {
+		s.eof++
+	}
+	return len(buf), nil
+}
+
+func readStdLog(m *logStatStoreMap, r io.Reader) {
+	conn := bufio.NewReadWriter(bufio.NewReader(r), nil)
+	m.NewStdLogDispatcher("runtime-1", StdoutLog, conn, 64).Read()
+}
+
+func frame(requestID, line string) string {
+	return string(FrameByte) + requestID + string(FrameIDByte) + line
+}
+
+func TestStdLogDispatcherFramed(t *testing.T) {
+	data := frame("req-1", "hello 1\n") +
+		frame("req-2", "hello 2\n") +
+		frame("req-1", "a long line of request 1 without new line in one read\n") +
+		frame("req-2", "\000") +
+		frame("req-1", "bye 1\n") +
+		frame("req-1", "\000")
+	for name, r := range map[string]io.Reader{
+		"whole":    strings.NewReader(data),
+		"one byte": iotest.OneByteReader(strings.NewReader(data)),
+	} {
+		m := newLogStatStoreMap("runtime-1")
+		s1, s2 := &fakeLogStore{name: "req-1"}, &fakeLogStore{name: "req-2"}
+		m.set("req-1", s1)
+		m.set("req-2", s2)
+		readStdLog(m, r)
+		assert.Equal(t, "hello 1\na long line of request 1 without new line in one read\nbye 1\n", s1.logs.String(), name)
+		assert.Equal(t, "hello 2\n", s2.logs.String(), name)
+		assert.Equal(t, 1, s1.eof, name)
+		assert.Equal(t, 1, s2.eof, name)
+	}
+}
+
+func TestStdLogDispatcherLegacy(t *testing.T) {
+	m := newLogStatStoreMap("runtime-1")
+	s1, s2 := &fakeLogStore{name: "req-1"}, &fakeLogStore{name: "req-2"}
+	m.set("req-1", s1)
+	m.set("req-2", s2)
+
+	// unframed lines go to the last request, framed ones are still routed by tag
+	readStdLog(m, strings.NewReader("legacy line\n"+frame("req-1", "framed line\n")+"\000"))
+	assert.Equal(t, "legacy line\n", s2.logs.String())
+	assert.Equal(t, "framed line\n", s1.logs.String())
+	assert.Equal(t, 1, s2.eof)
+
+	// the last request is kept when an earlier one is done
+	m.del("req-1", s1)
+	last, err := m.getLast()
+	assert.Nil(t, err)
+	assert.Equal(t, s2, last)
+}
+
+func TestStdLogDispatcherFramedStoreGone(t *testing.T) {
+	m := newLogStatStoreMap("runtime-1")
+	s1 := &fakeLogStore{name: "req-1"}
+	m.set("req-1", s1)
+
+	// lines of a request whose log store is gone never end up in another request
+	readStdLog(m, strings.NewReader(frame("req-gone", "lost line\n")+frame("req-gone", "\000")+frame("req-1", "line 1\n")))
+	assert.Equal(t, "line 1\n", s1.logs.String())
+	assert.Equal(t, 0, s1.eof)
+}
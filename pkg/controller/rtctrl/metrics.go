@@ -101,6 +101,56 @@ var (
 	}
 )
 
+const (
+	waitQueueDepthIndex   = "wait_queue_depth"
+	waitQueueLatencyIndex = "wait_queue_latency"
+
+	waitResultHandOff = "handoff"
+	waitResultTimeout = "timeout"
+	waitResultCold    = "cold"
+
+	evictionsIndex = "evictions"
+
+	probeLatencyIndex = "probe_latency"
+)
+
+var (
+	runtimeMetrics = []metric.MetricConfig{
+		{
+			MetricType:   metric.MetricTypeGauge,
+			Index:        waitQueueDepthIndex,
+			Name:         waitQueueDepthIndex,
+			Labels:       []string{},
+			HelpTemplate: "requests waiting for released runtimes",
+		},
+		{
+			MetricType:   metric.MetricTypeHistogram,
+			Index:        waitQueueLatencyIndex,
+			Name:         waitQueueLatencyIndex,
+			Labels:       []string{"result"},
+			HelpTemplate: "wait latency of requests in wait queue",
+			Buckets:      []float64{1, 5, 10, 50, 100, 500, 1000, 5000},
+			HasSummary:   true,
+		},
+		{
+			MetricType:   metric.MetricTypeCounter,
+			Index:        evictionsIndex,
+			Name:         evictionsIndex,
+			Labels:       []string{"policy"},
+			HelpTemplate: "warm runtimes evicted for other functions",
+		},
+		{
+			MetricType:   metric.MetricTypeHistogram,
+			Index:        probeLatencyIndex,
+			Name:         probeLatencyIndex,
+			Labels:       []string{"result"},
+			HelpTemplate: "latency of liveness probes to warm runtimes",
+			Buckets:      []float64{1, 5, 10, 50, 100, 500, 1000, 5000},
+			HasSummary:   true,
+		},
+	}
+)
+
 type rtCtrlInvokeStage = int
 
 const (
@@ -169,7 +219,10 @@ func (i *RtCtrlInvokeMetric) MarshalLogObject(enc zapcore.ObjectEncoder) error {
 }
 
 func InitRtCtrlMetric() error {
-	return metric.Register("rtInvoke", stages)
+	if err := metric.Register("rtInvoke", stages); err != nil {
+		return err
+	}
+	return metric.Register("runtime", runtimeMetrics)
 }
 
 func (r *RtCtrlInvokeMetric) StepDone(stage rtCtrlInvokeStage) {
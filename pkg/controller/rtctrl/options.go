@@ -31,6 +31,8 @@ type DispatcherV2Options struct {
 	RunnerServerAddress  string
 	UserLogFileDir       string
 	UserLogType          string
+	// address serving the runtime api only, eg. tcp://0.0.0.0:9001
+	RuntimeAPIAddress string
 }
 
 func NewDispatcherV2Options() *DispatcherV2Options {
@@ -46,6 +48,8 @@ func (s *DispatcherV2Options) AddFlags(fs *pflag.FlagSet) {
 		s.RuntimeServerAddress, "runtime server address (log and invoke)")
 	fs.StringVar(&s.RunnerServerAddress, "runner-dispatcher-address",
 		s.RunnerServerAddress, "runner server address")
+	fs.StringVar(&s.RuntimeAPIAddress, "runtime-api-address",
+		s.RuntimeAPIAddress, "address serving the lambda compatible runtime api to runtimes holding their token, disabled when empty")
 	fs.StringVar(&s.UserLogFileDir, "userlog-filepath",
 		s.UserLogFileDir, "user log storage path")
 	fs.StringVar(&s.UserLogType, "userlog-type",
@@ -0,0 +1,45 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rtctrl
+
+// Priority class of an invocation, runtimes go to higher classes first
+type Priority int
+
+const (
+	PriorityLow Priority = iota
+	PriorityNormal
+	PriorityHigh
+)
+
+var priorityNames = [PriorityHigh + 1]string{"low", "normal", "high"}
+
+func (p Priority) String() string {
+	if p < PriorityLow || p > PriorityHigh {
+		return "unknown"
+	}
+	return priorityNames[p]
+}
+
+// ParsePriority parses the name of a priority class
+func ParsePriority(name string) (Priority, bool) {
+	for p, n := range priorityNames {
+		if n == name {
+			return Priority(p), true
+		}
+	}
+	return PriorityNormal, false
+}
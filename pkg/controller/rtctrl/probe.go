@@ -0,0 +1,135 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package rtctrl
+package rtctrl
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/baidu/easyfaas/pkg/util/logs"
+	"github.com/baidu/easyfaas/pkg/util/logs/metric"
+)
+
+const (
+	probeResultOK      = "ok"
+	probeResultTimeout = "timeout"
+)
+
+// ProbeRuntime pings the warm runtime and waits for its pong within ProbeTimeout;
+// the runtime failing ProbeFailureThreshold probes in a row is marked unresponsive and invalidated
+func (m *RuntimeManager) ProbeRuntime(rt *RuntimeInfo) error {
+	if m.ProbeTimeout <= 0 || m.ProbeFailureThreshold <= 0 {
+		return fmt.Errorf("runtime probing is disabled")
+	}
+	pingID := fmt.Sprintf("probe-%s-%d", rt.RuntimeID, time.Now().UnixNano())
+	pong, stopChan, err := rt.sendPing(pingID)
+	if err != nil {
+		return err
+	}
+	defer rt.probeMap.Delete(pingID)
+
+	start := time.Now()
+	timer := time.NewTimer(time.Duration(m.ProbeTimeout) * time.Millisecond)
+	defer timer.Stop()
+	select {
+	case <-pong:
+		metric.Observe(probeLatencyIndex, float64(time.Since(start)/time.Millisecond), probeResultOK)
+		rt.probeDone(true, m.ProbeFailureThreshold)
+		return nil
+	case <-stopChan:
+		return fmt.Errorf("runtime %s stopped while probing", rt.RuntimeID)
+	case <-timer.C:
+	}
+	metric.Observe(probeLatencyIndex, float64(time.Since(start)/time.Millisecond), probeResultTimeout)
+	if rt.probeDone(false, m.ProbeFailureThreshold) {
+		rt.Invalidate()
+	}
+	return fmt.Errorf("runtime %s did not answer ping in %d ms", rt.RuntimeID, m.ProbeTimeout)
+}
+
+// sendPing queues a ping frame to the warm runtime answering pings
+func (info *RuntimeInfo) sendPing(pingID string) (pong chan struct{}, stopChan chan struct{}, err error) {
+	info.invokeLock.Lock()
+	defer info.invokeLock.Unlock()
+
+	if info.State != RuntimeStateWarm {
+		return nil, nil, &RuntimeStateUnmatched{
+			RuntimeID:     info.RuntimeID,
+			CurrentState:  info.State,
+			ExpectedState: []RuntimeStateType{RuntimeStateWarm},
+		}
+	}
+	if !info.available() {
+		return nil, nil, &RuntimeMatchError{Reason: "runner is not available"}
+	}
+	if !info.Probe || info.WithStreamMode || info.Protocol == RuntimeProtocolLambda {
+		return nil, nil, &RuntimeMatchError{Reason: "runtime does not answer pings"}
+	}
+	// a runtime serving one request at a time answers the ping only after the request
+	if info.Concurrency > 0 && !info.ConcurrentMode {
+		return nil, nil, &RuntimeMatchError{Reason: "runtime is busy"}
+	}
+
+	pong = make(chan struct{}, 1)
+	info.probeMap.Store(pingID, pong)
+	select {
+	case info.requestChan <- &InvokeRequest{RequestID: pingID, Ping: true}:
+	default:
+		info.probeMap.Delete(pingID)
+		return nil, nil, fmt.Errorf("request queue of runtime %s is full", info.RuntimeID)
+	}
+	return pong, info.runtimeStopChan, nil
+}
+
+// handlePong notifies the probe waiting for the pong
+func (info *RuntimeInfo) handlePong(pingID string) {
+	value, ok := info.probeMap.Load(pingID)
+	if !ok {
+		logs.V(5).Infof("runtime %s answered ping %s after its probe gave up", info.RuntimeID, pingID)
+		return
+	}
+	select {
+	case value.(chan struct{}) <- struct{}{}:
+	default:
+	}
+}
+
+// IsUnresponsive: the runtime failed too many probes in a row
+func (info *RuntimeInfo) IsUnresponsive() bool {
+	info.invokeLock.Lock()
+	defer info.invokeLock.Unlock()
+	return info.Unresponsive
+}
+
+// probeDone counts the consecutive probe failures, true if the runtime gets unresponsive
+func (info *RuntimeInfo) probeDone(success bool, threshold int) bool {
+	info.invokeLock.Lock()
+	defer info.invokeLock.Unlock()
+
+	if success {
+		info.ProbeFailures = 0
+		return false
+	}
+	info.ProbeFailures++
+	logs.Warnf("runtime %s failed %d probes in a row", info.RuntimeID, info.ProbeFailures)
+	if info.Unresponsive || info.ProbeFailures < uint(threshold) {
+		return false
+	}
+	info.Unresponsive = true
+	return true
+}
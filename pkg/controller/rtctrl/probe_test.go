@@ -0,0 +1,153 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package rtctrl
+package rtctrl
+
+import (
+	"net"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/baidu/easyfaas/pkg/api"
+	"github.com/baidu/easyfaas/pkg/util/json"
+)
+
+// startProbedRuntime warms up a runtime answering pings while answering is set
+func startProbedRuntime(t *testing.T, m *RuntimeManager, answering *int32) *RuntimeInfo {
+	rt := m.NewRuntime(&NewRuntimeParameters{
+		RuntimeID:               "runtime-probe",
+		WaitRuntimeAliveTimeout: 3,
+		Resource:                &api.Resource{},
+	})
+	rt.SetState(RuntimeStateCold)
+	server, client := net.Pipe()
+	params := &startRuntimeParams{
+		commitID:  "commit-1",
+		conn:      server,
+		urlParams: url.Values{},
+		protocol:  RuntimeProtocolJSON,
+		probe:     true,
+	}
+	go rt.startRuntimeLoop(params)
+	go func() {
+		decoder := json.NewDecoder(client)
+		encoder := json.NewEncoder(client)
+		for {
+			var req InvokeRequest
+			if err := decoder.Decode(&req); err != nil {
+				return
+			}
+			if req.Ping && atomic.LoadInt32(answering) == 1 {
+				encoder.Encode(&InvokeResponse{RequestID: req.RequestID, Pong: true})
+			}
+		}
+	}()
+	assert.True(t, rt.Wait(3))
+	return rt
+}
+
+func TestProbeRuntime(t *testing.T) {
+	m := NewRuntimeManager(getFuncletNode(), &RuntimeManagerParameters{
+		MaxRuntimeIdle:        10,
+		MaxRunnerDefunct:      30,
+		ProbeTimeout:          100,
+		ProbeFailureThreshold: 2,
+	})
+	answering := int32(1)
+	rt := startProbedRuntime(t, m, &answering)
+	assert.Nil(t, m.ProbeRuntime(rt))
+
+	// a wedged runtime is invalidated after consecutive failures
+	atomic.StoreInt32(&answering, 0)
+	assert.NotNil(t, m.ProbeRuntime(rt))
+	assert.Equal(t, uint(1), rt.ProbeFailures)
+	assert.False(t, rt.Abnormal)
+	atomic.StoreInt32(&answering, 1)
+	assert.Nil(t, m.ProbeRuntime(rt))
+	assert.Equal(t, uint(0), rt.ProbeFailures)
+
+	atomic.StoreInt32(&answering, 0)
+	assert.NotNil(t, m.ProbeRuntime(rt))
+	assert.NotNil(t, m.ProbeRuntime(rt))
+	assert.True(t, rt.Unresponsive)
+	assert.True(t, rt.Abnormal)
+	// the abnormal runtime is not probed any more
+	assert.NotNil(t, m.ProbeRuntime(rt))
+	assert.Equal(t, uint(2), rt.ProbeFailures)
+
+	// the runner of the runtime is alive, the runtime is reset anyway
+	rt.updateLastLivenessTime()
+	_, err := m.ResetRuntime(rt)
+	assert.Nil(t, err)
+	assert.False(t, rt.Unresponsive)
+	assert.Equal(t, "", rt.CommitID)
+}
+
+func TestProbeRuntimeSkipped(t *testing.T) {
+	m := NewRuntimeManager(getFuncletNode(), &RuntimeManagerParameters{MaxRuntimeIdle: 10, MaxRunnerDefunct: 30})
+	answering := int32(1)
+	rt := startProbedRuntime(t, m, &answering)
+	assert.NotNil(t, m.ProbeRuntime(rt))
+
+	m.ProbeTimeout = 100
+	m.ProbeFailureThreshold = 1
+	assert.Nil(t, m.ProbeRuntime(rt))
+	// runtimes not answering pings are never probed
+	rt.Probe = false
+	assert.NotNil(t, m.ProbeRuntime(rt))
+	assert.Equal(t, uint(0), rt.ProbeFailures)
+}
+
+func TestLatePong(t *testing.T) {
+	rt := NewRuntimeInfo(&NewRuntimeParameters{
+		RuntimeID:               "runtime-probe",
+		WaitRuntimeAliveTimeout: 3,
+		Resource:                &api.Resource{},
+	})
+	rt.handlePong("probe-gone")
+	pong := make(chan struct{}, 1)
+	rt.probeMap.Store("probe-1", pong)
+	rt.handlePong("probe-1")
+	rt.handlePong("probe-1")
+	select {
+	case <-pong:
+	case <-time.After(time.Second):
+		t.Fatal("pong is not delivered")
+	}
+}
+
+func TestProbeBusyRuntime(t *testing.T) {
+	m := NewRuntimeManager(getFuncletNode(), &RuntimeManagerParameters{
+		MaxRuntimeIdle:        10,
+		MaxRunnerDefunct:      30,
+		ProbeTimeout:          100,
+		ProbeFailureThreshold: 1,
+	})
+	answering := int32(0)
+	rt := startProbedRuntime(t, m, &answering)
+	rt.invokeLock.Lock()
+	rt.Concurrency = 1
+	rt.invokeLock.Unlock()
+	// the ping of a runtime running a request waits for the request, it is not probed
+	assert.NotNil(t, m.ProbeRuntime(rt))
+	assert.False(t, rt.IsUnresponsive())
+	assert.Equal(t, uint(0), rt.ProbeFailures)
+}
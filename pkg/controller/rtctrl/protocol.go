@@ -0,0 +1,187 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package rtctrl
+package rtctrl
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/baidu/easyfaas/pkg/util/json"
+)
+
+// RuntimeProtocol: wire protocol of invocations between the controller and a generic runtime
+type RuntimeProtocol string
+
+const (
+	// RuntimeProtocolJSON: newline delimited InvokeRequest/InvokeResponse json, the default
+	RuntimeProtocolJSON RuntimeProtocol = "json"
+	// RuntimeProtocolBinary: length prefixed frames carrying the metadata and the raw payload separately
+	RuntimeProtocolBinary RuntimeProtocol = "binary"
+	// RuntimeProtocolLambda: invocations pulled through the runtime api, not negotiated on the handshake
+	RuntimeProtocolLambda RuntimeProtocol = "lambda"
+
+	// HeaderRuntimeProtocol: header of the invoke handshake a runtime negotiates the protocol with
+	HeaderRuntimeProtocol = "x-cfc-protocol"
+	// HeaderRuntimeProbe: header of the invoke handshake a runtime answering ping frames sets to true
+	HeaderRuntimeProbe = "x-cfc-probe"
+	// HeaderRuntimeAbort: header of the invoke handshake a runtime aborting requests on abort frames sets to true
+	HeaderRuntimeAbort = "x-cfc-abort"
+
+	// frameHeaderSize: metadata length uint32 and payload length uint64, big endian
+	frameHeaderSize = 12
+	// maxFrameMetaSize: max metadata size of a binary frame
+	maxFrameMetaSize = 1 << 20
+	// maxFramePayloadSize: max payload size of a binary frame, the same as the max response of a function
+	maxFramePayloadSize = 6 << 20
+)
+
+// ParseRuntimeProtocol parses the protocol negotiated by a runtime, json by default
+func ParseRuntimeProtocol(s string) (RuntimeProtocol, error) {
+	switch RuntimeProtocol(s) {
+	case "", RuntimeProtocolJSON:
+		return RuntimeProtocolJSON, nil
+	case RuntimeProtocolBinary:
+		return RuntimeProtocolBinary, nil
+	}
+	return "", fmt.Errorf("unsupported runtime protocol %s", s)
+}
+
+type requestEncoder interface {
+	Encode(req *InvokeRequest) error
+}
+
+type responseDecoder interface {
+	Decode(resp *InvokeResponse) error
+}
+
+func newRequestEncoder(protocol RuntimeProtocol, w io.Writer) requestEncoder {
+	if protocol == RuntimeProtocolBinary {
+		return &binaryRequestEncoder{w: bufio.NewWriter(w)}
+	}
+	return &jsonRequestEncoder{encoder: json.NewEncoder(w)}
+}
+
+func newResponseDecoder(protocol RuntimeProtocol, r io.Reader) responseDecoder {
+	if protocol == RuntimeProtocolBinary {
+		return &binaryResponseDecoder{r: bufio.NewReader(r)}
+	}
+	return &jsonResponseDecoder{decoder: json.NewDecoder(r)}
+}
+
+type jsonRequestEncoder struct {
+	encoder interface{ Encode(v interface{}) error }
+}
+
+func (e *jsonRequestEncoder) Encode(req *InvokeRequest) error {
+	return e.encoder.Encode(req)
+}
+
+type jsonResponseDecoder struct {
+	decoder interface{ Decode(v interface{}) error }
+}
+
+func (d *jsonResponseDecoder) Decode(resp *InvokeResponse) error {
+	return d.decoder.Decode(resp)
+}
+
+// binaryRequestEncoder: the event object is the payload of the frame
+type binaryRequestEncoder struct {
+	w *bufio.Writer
+}
+
+func (e *binaryRequestEncoder) Encode(req *InvokeRequest) error {
+	meta := *req
+	meta.EventObject = ""
+	return writeFrame(e.w, &meta, req.EventObject)
+}
+
+// binaryResponseDecoder: the payload of the frame is the function result or chunk, or the error when not success
+type binaryResponseDecoder struct {
+	r *bufio.Reader
+}
+
+func (d *binaryResponseDecoder) Decode(resp *InvokeResponse) error {
+	payload, err := readFrame(d.r, resp)
+	if err != nil {
+		return err
+	}
+	if resp.Success || resp.Chunk {
+		resp.FuncResult = string(payload)
+	} else {
+		resp.FuncError = string(payload)
+	}
+	return nil
+}
+
+// writeFrame writes one binary frame:
+// | meta length uint32 | payload length uint64 | meta json | payload |
+func writeFrame(w *bufio.Writer, meta interface{}, payload string) error {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	var header [frameHeaderSize]byte
+	binary.BigEndian.PutUint32(header[0:4], uint32(len(data)))
+	binary.BigEndian.PutUint64(header[4:12], uint64(len(payload)))
+	w.Write(header[:])
+	w.Write(data)
+	w.WriteString(payload)
+	return w.Flush()
+}
+
+// readFrame reads one binary frame, decodes the metadata into meta and returns the payload;
+// io.EOF is returned only when the connection is closed between frames,
+// the connection can not be read any more after a frame is rejected for its size
+func readFrame(r io.Reader, meta interface{}) ([]byte, error) {
+	var header [frameHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	metaSize := binary.BigEndian.Uint32(header[0:4])
+	payloadSize := binary.BigEndian.Uint64(header[4:12])
+	if metaSize > maxFrameMetaSize {
+		return nil, fmt.Errorf("frame meta size %d exceeds %d", metaSize, maxFrameMetaSize)
+	}
+	// checked before any conversion, a declared size beyond int64 would wrap around
+	if payloadSize > maxFramePayloadSize {
+		return nil, fmt.Errorf("frame payload size %d exceeds %d", payloadSize, maxFramePayloadSize)
+	}
+	data := make([]byte, metaSize)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	if err := json.Unmarshal(data, meta); err != nil {
+		return nil, err
+	}
+	// the payload buffer grows with the data read rather than the declared size
+	var payload bytes.Buffer
+	if _, err := io.CopyN(&payload, r, int64(payloadSize)); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	return payload.Bytes(), nil
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,97 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package rtctrl
+package rtctrl
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRuntimeProtocol(t *testing.T) {
+	p, err := ParseRuntimeProtocol("")
+	assert.Nil(t, err)
+	assert.Equal(t, RuntimeProtocolJSON, p)
+	p, err = ParseRuntimeProtocol("binary")
+	assert.Nil(t, err)
+	assert.Equal(t, RuntimeProtocolBinary, p)
+	_, err = ParseRuntimeProtocol("protobuf")
+	assert.NotNil(t, err)
+}
+
+func TestBinaryProtocol(t *testing.T) {
+	payload := "line 1\nline 2\x00\xff\xfe"
+	var conn bytes.Buffer
+	encoder := newRequestEncoder(RuntimeProtocolBinary, &conn)
+	assert.Nil(t, encoder.Encode(&InvokeRequest{RequestID: "req-1", Version: "1", EventObject: payload}))
+	assert.Nil(t, encoder.Encode(&InvokeRequest{RequestID: "req-1", Abort: true}))
+
+	// the runtime reads the metadata and the raw payload separately
+	var req InvokeRequest
+	got, err := readFrame(&conn, &req)
+	assert.Nil(t, err)
+	assert.Equal(t, "req-1", req.RequestID)
+	assert.Equal(t, "", req.EventObject)
+	assert.Equal(t, payload, string(got))
+	req = InvokeRequest{}
+	got, err = readFrame(&conn, &req)
+	assert.Nil(t, err)
+	assert.True(t, req.Abort)
+	assert.Empty(t, got)
+	_, err = readFrame(&conn, &req)
+	assert.Equal(t, io.EOF, err)
+
+	// the runtime responds the result, or the error when not success
+	w := bufio.NewWriter(&conn)
+	assert.Nil(t, writeFrame(w, &InvokeResponse{RequestID: "req-1", Success: true}, payload))
+	assert.Nil(t, writeFrame(w, &InvokeResponse{RequestID: "req-2"}, `{"errorMessage":"boom"}`))
+	decoder := newResponseDecoder(RuntimeProtocolBinary, &conn)
+	var resp InvokeResponse
+	assert.Nil(t, decoder.Decode(&resp))
+	assert.Equal(t, InvokeResponse{RequestID: "req-1", Success: true, FuncResult: payload}, resp)
+	resp = InvokeResponse{}
+	assert.Nil(t, decoder.Decode(&resp))
+	assert.Equal(t, InvokeResponse{RequestID: "req-2", FuncError: `{"errorMessage":"boom"}`}, resp)
+}
+
+func TestBinaryProtocolBrokenFrame(t *testing.T) {
+	var conn bytes.Buffer
+	assert.Nil(t, writeFrame(bufio.NewWriter(&conn), &InvokeResponse{RequestID: "req-1", Success: true}, "result"))
+	truncated := conn.Bytes()[:conn.Len()-1]
+	_, err := readFrame(bytes.NewReader(truncated), &InvokeResponse{})
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+
+	oversized := []byte{0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0, 0, 0, 0, 0}
+	_, err = readFrame(bytes.NewReader(oversized), &InvokeResponse{})
+	assert.NotNil(t, err)
+
+	// payload sizes beyond the limit, or wrapping around int64, are rejected before reading
+	for _, size := range []uint64{maxFramePayloadSize + 1, 1 << 63, math.MaxUint64} {
+		header := make([]byte, frameHeaderSize)
+		binary.BigEndian.PutUint32(header[0:4], 2)
+		binary.BigEndian.PutUint64(header[4:12], size)
+		_, err = readFrame(bytes.NewReader(append(header, "{}"...)), &InvokeResponse{})
+		assert.EqualError(t, err, fmt.Sprintf("frame payload size %d exceeds %d", size, maxFramePayloadSize))
+	}
+}
@@ -17,8 +17,10 @@
 package rtctrl
 
 import (
+	"bytes"
 	"fmt"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/baidu/easyfaas/pkg/util/bytefmt"
@@ -38,8 +40,12 @@ const (
 	StatusSuccess
 	StatusFailed
 	StatusTimeout
+	StatusCancelled
 )
 
+// StatusClientClosedRequest is reported for cancelled requests
+const StatusClientClosedRequest = 499
+
 func ceilBillDuration(d time.Duration) time.Duration {
 	r := d % baseDuration
 	if r == 0 {
@@ -64,16 +70,32 @@ type RequestInfo struct {
 	MaxMemUsedBytes   int64
 	MemorySpecSize    int64
 	TriggerType       string
-	enableUserLog     bool
+	// AccountID: the account invoking the function, only it may cancel the request
+	AccountID     string
+	enableUserLog bool
+
+	// lock: guards the status of the request, which is finished by the runtime, a timeout or a cancel
+	lock     sync.Mutex
+	Status   RequestStatus
+	finished bool
+	// detached: closed when the runtime finishes the request its caller does not wait for
+	detached chan struct{}
+	// done: closed after the detached request is cleaned up, the runtime is occupied until then
+	done chan struct{}
 
-	Status RequestStatus
 	Input  *InvocationInput
 	Output *InvocationOutput
 
 	store LogStatStore
+	// chunks: partial results buffered when the response can not be streamed
+	chunks bytes.Buffer
+	// stream: the response streamed to the caller since the first chunk
+	stream *responseStream
 
 	SyncChannel    chan struct{}
 	TimeoutChannel chan struct{} // timeout notification
+
+	cancel func() // cancels the http request in stream mode
 }
 
 func NewRequestInfo(requestID string, runtime *RuntimeInfo) *RequestInfo {
@@ -114,9 +136,12 @@ func (info *RequestInfo) CleanOutput() {
 	info.Output.Output.FuncError = ""
 }
 
-func (info *RequestInfo) InvokeResult(status RequestStatus, result string) {
+// InvokeResult sets the result of the running request, false if it is already finished
+func (info *RequestInfo) InvokeResult(status RequestStatus, result string) bool {
+	info.lock.Lock()
+	defer info.lock.Unlock()
 	if info.Status != StatusRunning {
-		return
+		return false
 	}
 	info.Status = status
 	if status == StatusSuccess {
@@ -125,6 +150,61 @@ func (info *RequestInfo) InvokeResult(status RequestStatus, result string) {
 		info.Output.Output.FuncError = "Unhandled"
 		info.Output.Output.ErrorInfo = result
 	}
+	return true
+}
+
+// GetStatus
+func (info *RequestInfo) GetStatus() RequestStatus {
+	info.lock.Lock()
+	defer info.lock.Unlock()
+	return info.Status
+}
+
+// IsRunning: the request is not finished yet
+func (info *RequestInfo) IsRunning() bool {
+	return info.GetStatus() == StatusRunning
+}
+
+// finish marks the request finished on its runtime, false if it is already finished
+func (info *RequestInfo) finish() bool {
+	info.lock.Lock()
+	defer info.lock.Unlock()
+	if info.finished {
+		return false
+	}
+	info.finished = true
+	if info.detached != nil {
+		close(info.detached)
+	}
+	return true
+}
+
+// detach hands the rest of the request over from its caller, who gets the response early,
+// e.g. the response is streamed or the request is cancelled;
+// false if the request is already detached or finished
+func (info *RequestInfo) detach(stream *responseStream) bool {
+	info.lock.Lock()
+	defer info.lock.Unlock()
+	if info.detached != nil || info.finished {
+		return false
+	}
+	info.stream = stream
+	info.detached = make(chan struct{})
+	info.done = make(chan struct{})
+	info.Output.Done = info.done
+	return true
+}
+
+func (info *RequestInfo) getStream() *responseStream {
+	info.lock.Lock()
+	defer info.lock.Unlock()
+	return info.stream
+}
+
+func (info *RequestInfo) isDetached() bool {
+	info.lock.Lock()
+	defer info.lock.Unlock()
+	return info.detached != nil
 }
 
 func (info *RequestInfo) InvokeDone() {
@@ -145,6 +225,9 @@ func (info *RequestInfo) InvokeReportDone() {
 	} else {
 		info.store.LogDone(true)
 	}
+	if info.GetStatus() == StatusCancelled {
+		info.store.WriteFunctionLog(fmt.Sprintf("CANCELLED RequestID: %s\n", info.RequestID))
+	}
 	info.store.WriteFunctionLog(fmt.Sprintf("END RequestID: %s\tMemory Spec Size: %dMB\n", info.RequestID, info.MemorySpecSize))
 	if info.Runtime != nil && info.Runtime.ConcurrentMode {
 		info.InvokeDurationMS = 0
@@ -177,8 +260,11 @@ func (info *RequestInfo) getInvokeMode() string {
 }
 
 func (info *RequestInfo) getResponseStatus() int {
-	if info.Status == StatusSuccess {
+	switch info.GetStatus() {
+	case StatusSuccess:
 		return http.StatusOK
+	case StatusCancelled:
+		return StatusClientClosedRequest
 	}
 	return http.StatusInternalServerError
 }
@@ -204,8 +290,9 @@ func (info *RequestInfo) Notify() {
 	}
 }
 
+// StepDone: the metrics of a streamed request are reported once its stream starts
 func (info *RequestInfo) StepDone(state rtCtrlInvokeStage) {
-	if info.Input != nil && info.Input.EnableMetrics {
+	if info.getStream() == nil && info.Input != nil && info.Input.EnableMetrics {
 		info.Output.Statistic.Metric.StepDone(state)
 	}
 }
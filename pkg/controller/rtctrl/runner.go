@@ -20,6 +20,7 @@ package rtctrl
 import (
 	"fmt"
 	"io"
+	"time"
 
 	"github.com/baidu/easyfaas/pkg/util/json"
 	"github.com/baidu/easyfaas/pkg/util/logs"
@@ -74,9 +75,17 @@ func (info *RuntimeInfo) initRunner(params *startRunnerParams) error {
 		return fmt.Errorf("duplicate runner")
 	}
 
-	info.SetState(RuntimeStateCold)
 	info.runnerConn = params.conn
-	info.Abnormal = false
+	info.SetAbnormal(false)
+	info.restoredAt = time.Time{}
+	if info.restore != nil {
+		// the restored runtime waits for its function to reconnect, or it is reset
+		info.restore = nil
+		info.restoredAt = time.Now()
+		info.SetState(RuntimeStateWarmUp)
+		return nil
+	}
+	info.SetState(RuntimeStateCold)
 	return nil
 }
 
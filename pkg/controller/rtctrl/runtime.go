@@ -28,10 +28,10 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	innerErr "github.com/baidu/easyfaas/pkg/error"
-	"github.com/baidu/easyfaas/pkg/util/json"
 	"github.com/baidu/easyfaas/pkg/util/logs"
 	"go.uber.org/zap"
 )
@@ -57,7 +57,7 @@ func (info *RuntimeInfo) initRuntime(params *startRuntimeParams) error {
 		return fmt.Errorf("duplicate runtime")
 	}
 
-	// TODO: CommitID could be missing, when controller restarted
+	// CommitID is missing when the controller restarted without restoring the function
 	if len(info.CommitID) == 0 {
 		info.SetCommitID(params.commitID)
 	}
@@ -65,6 +65,11 @@ func (info *RuntimeInfo) initRuntime(params *startRuntimeParams) error {
 		return fmt.Errorf("commit id %s is not equal to %s", info.CommitID, params.commitID)
 	}
 
+	info.Protocol = params.protocol
+	info.Probe = params.probe
+	info.Abortable = params.abortable
+	info.ProbeFailures = 0
+
 	cm := params.urlParams.Get("concurrentmode")
 	// When service's concurrent mode is true, the value of runtime concurrent mode makes sense
 	if info.ConcurrentMode == true && cm == "false" {
@@ -73,6 +78,7 @@ func (info *RuntimeInfo) initRuntime(params *startRuntimeParams) error {
 	logs.V(9).Infof("runtime[%s] concurrentMode is %t", info.RuntimeID, info.ConcurrentMode)
 
 	info.SetInitTime(preInit, postInit)
+	info.restoredAt = time.Time{}
 	info.SetState(RuntimeStateWarm)
 	info.SetUsed(true)
 	if params.warmNotify != nil {
@@ -107,6 +113,11 @@ func (info *RuntimeInfo) startRuntimeLoop(params *startRuntimeParams) error {
 
 // sendRequestLoop
 func (info *RuntimeInfo) sendRequestLoop(params *startRuntimeParams) {
+	if info.Protocol == RuntimeProtocolLambda {
+		// invocations are pulled through the runtime api
+		info.runtimeConn = nil
+		return
+	}
 	if !info.WithStreamMode {
 		info.runtimeConn = params.conn
 		info.runtimeWaitGroup.Add(1)
@@ -122,12 +133,24 @@ func (info *RuntimeInfo) sendRequestLoop(params *startRuntimeParams) {
 
 // sendGenericRequestLoop
 func (info *RuntimeInfo) sendGenericRequestLoop() {
-	encoder := json.NewEncoder(info.runtimeConn)
+	encoder := newRequestEncoder(info.Protocol, info.runtimeConn)
 loop:
 	for {
 		select {
 		case request := <-info.requestChan:
 			err := encoder.Encode(request)
+			if request.Abort {
+				if err != nil {
+					logs.Warnf("send abort of request %s to runtime %s failed: %s", request.RequestID, info.RuntimeID, err)
+				}
+				continue
+			}
+			if request.Ping {
+				if err != nil {
+					logs.Warnf("send ping %s to runtime %s failed: %s", request.RequestID, info.RuntimeID, err)
+				}
+				continue
+			}
 			requestInfo := info.loadRequest(request.RequestID)
 			if err != nil {
 				logs.Errorf("marshal invokeReq failed. %s", err.Error())
@@ -189,6 +212,10 @@ loop:
 					invokeStart := time.Now().UnixNano()
 					httprsp, err := info.httpClient.Do(httpreq)
 					invokeEnd := time.Now().UnixNano()
+					if err != nil && requestInfo.GetStatus() == StatusCancelled {
+						reqLogger.Infof("request cancelled: %s", err.Error())
+						break RequestLoop
+					}
 					if err != nil {
 						reqLogger.Infof("request failed: %s retry after %s", err.Error(), retryDuration.String())
 						<-time.After(retryDuration)
@@ -238,7 +265,7 @@ func (info *RuntimeInfo) recvGenericResponseLoop() {
 	defer info.stopRuntime()
 
 	urlParams := make(url.Values)
-	decoder := json.NewDecoder(info.runtimeConn)
+	decoder := newResponseDecoder(info.Protocol, info.runtimeConn)
 	for {
 		var output InvokeResponse
 		err := decoder.Decode(&output)
@@ -250,27 +277,51 @@ func (info *RuntimeInfo) recvGenericResponseLoop() {
 			}
 			break
 		}
+		if output.Pong {
+			info.handlePong(output.RequestID)
+			continue
+		}
 		logs.V(6).Info("request done.",
 			zap.String("runtimeID", info.RuntimeID),
 			zap.String("request_id", output.RequestID),
 			zap.Bool("success", output.Success))
-		requestInfo := info.loadRequest(output.RequestID)
-		if requestInfo != nil {
-			requestInfo.StepDone(StageRecvResponse)
-		}
-		if output.Success {
-			urlParams.Set("success", "true")
-			info.handleInvokeDone(output.RequestID, &urlParams, output.FuncResult)
-		} else {
-			urlParams.Set("success", "false")
-			info.handleInvokeDone(output.RequestID, &urlParams, output.FuncError)
+		if output.Chunk {
+			info.handleInvokeChunk(output.RequestID, output.FuncResult)
+			continue
 		}
+		info.handleInvokeResponse(&output, urlParams)
 	}
 
 	info.runtimeWaitGroup.Done()
 	logs.Infof("runtime %s stop receiving response", info.RuntimeID)
 }
 
+// handleInvokeResponse finishes the request with the final response of the runtime
+func (info *RuntimeInfo) handleInvokeResponse(output *InvokeResponse, urlParams url.Values) {
+	requestInfo := info.loadRequest(output.RequestID)
+	if requestInfo != nil {
+		requestInfo.StepDone(StageRecvResponse)
+	}
+	streamed := requestInfo != nil && requestInfo.stream != nil
+	if output.Success {
+		urlParams.Set("success", "true")
+		result := output.FuncResult
+		if streamed {
+			// the result is written to the stream instead
+			result = ""
+		} else if requestInfo != nil && requestInfo.chunks.Len() > 0 {
+			result = requestInfo.chunks.String() + result
+		}
+		info.handleInvokeDone(output.RequestID, &urlParams, result)
+	} else {
+		urlParams.Set("success", "false")
+		info.handleInvokeDone(output.RequestID, &urlParams, output.FuncError)
+	}
+	if streamed {
+		requestInfo.finishStream(output)
+	}
+}
+
 func (info *RuntimeInfo) recvHTTPResponseLoop() {
 L:
 	for {
@@ -278,6 +329,10 @@ L:
 		case response := <-info.httpResponseChan:
 			httpResponse := response.Response
 			requestInfo := info.loadRequest(response.RequestID)
+			if requestInfo == nil {
+				httpResponse.Body.Close()
+				continue
+			}
 			ConvertHTTPResponseToProxy(httpResponse, requestInfo)
 			info.InvokeDone(requestInfo, true)
 		case <-info.runtimeStopChan:
@@ -303,9 +358,10 @@ func (info *RuntimeInfo) handleInvokeDone(requestID string, params *url.Values,
 		if strings.Compare(params.Get("success"), "true") == 0 {
 			status = StatusSuccess
 		}
-		request.InvokeResult(status, data)
-		request.InvokeDone()
-		request.StepDone(StageInvokeDone)
+		if request.InvokeResult(status, data) {
+			request.InvokeDone()
+			request.StepDone(StageInvokeDone)
+		}
 		info.InvokeDone(request, true)
 		return true
 	}
@@ -317,38 +373,92 @@ func (info *RuntimeInfo) InvokeDone(request *RequestInfo, signal bool) {
 	if _, load := info.requestMap.Load(request.RequestID); !load {
 		return
 	}
+	// finished by the runtime, a timeout or a cancel at the same time
+	if !request.finish() {
+		return
+	}
 
 	if signal {
 		request.Notify()
 	}
 
-	if request.Status == StatusSuccess {
-		info.AcceptReqCnt++
-	} else if request.Status == StatusFailed {
-		info.RejectReqCnt++
+	switch request.GetStatus() {
+	case StatusSuccess:
+		atomic.AddInt64(&info.AcceptReqCnt, 1)
+	case StatusFailed:
+		atomic.AddInt64(&info.RejectReqCnt, 1)
 	}
 
 	info.requestMap.Delete(request.RequestID)
 }
 
+// CancelRequest aborts an in-flight request of the account and finishes it as cancelled;
+// the request is only detached from its caller when the runtime can not abort it,
+// so that the runtime is not released while the function is still running
+func (info *RuntimeInfo) CancelRequest(requestID, accountID string) bool {
+	value, ok := info.requestMap.Load(requestID)
+	if !ok {
+		return false
+	}
+	request := value.(*RequestInfo)
+	if request.AccountID != accountID {
+		return false
+	}
+	m := innerErr.AwsErrorMessage{
+		ErrorMessage: fmt.Sprintf("RequestID: %s Request cancelled", requestID),
+	}
+	if !request.InvokeResult(StatusCancelled, m.String()) {
+		return false
+	}
+	if stream := request.getStream(); stream != nil {
+		stream.close(errors.New(m.ErrorMessage))
+	}
+
+	aborted := false
+	if info.WithStreamMode {
+		if request.cancel != nil {
+			request.cancel()
+			aborted = true
+		}
+	} else if info.Abortable {
+		select {
+		case info.requestChan <- &InvokeRequest{RequestID: requestID, Abort: true}:
+			aborted = true
+		default:
+			logs.Warnf("request queue of runtime %s is full, abort of %s is dropped", info.RuntimeID, requestID)
+		}
+	}
+	request.InvokeDone()
+	if !aborted {
+		logs.Warnf("runtime %s can not abort request %s, it is occupied until the function returns", info.RuntimeID, requestID)
+		request.detach(nil)
+		request.Notify()
+		return true
+	}
+	info.InvokeDone(request, true)
+	return true
+}
+
 // Wait
 func (info *RuntimeInfo) Wait(timeout int) bool {
-	if info.State == RuntimeStateWarm {
+	info.invokeLock.Lock()
+	warm, runChan, failChan := info.State == RuntimeStateWarm, info.runtimeRunChan, info.initFailChan
+	info.invokeLock.Unlock()
+	if warm {
 		return true
 	}
 
 	timer := time.NewTimer(time.Duration(timeout) * time.Second)
 	select {
-	case <-info.runtimeRunChan:
+	case <-runChan:
+	case <-failChan:
 	case <-timer.C:
 	}
 	timer.Stop()
 
-	if info.State == RuntimeStateWarm {
-		return true
-	}
-
-	return false
+	info.invokeLock.Lock()
+	defer info.invokeLock.Unlock()
+	return info.State == RuntimeStateWarm
 }
 
 // stopRuntime
@@ -369,14 +479,21 @@ func (info *RuntimeInfo) stopRuntime() {
 	info.requestMap.Range(func(key, value interface{}) bool {
 		id := key.(string)
 		request := value.(*RequestInfo)
-		request.InvokeResult(StatusFailed, fmt.Sprintf("RequestID: %s Process exited before completing request", id))
+		errMsg := fmt.Sprintf("RequestID: %s Process exited before completing request", id)
+		request.InvokeResult(StatusFailed, errMsg)
+		if stream := request.getStream(); stream != nil {
+			stream.close(errors.New(errMsg))
+		}
+		request.finish()
 		request.Notify()
 		return true
 	})
 	info.requestMap = sync.Map{}
 	close(info.runtimeStopChan)
 	if !info.WithStreamMode {
-		info.runtimeConn.Close()
+		if info.runtimeConn != nil {
+			info.runtimeConn.Close()
+		}
 	} else {
 		info.httpClient = nil
 	}
@@ -403,6 +520,7 @@ func (info *RuntimeInfo) InvokeFunc(request *RequestInfo, invokeReq *InvokeReque
 
 // InvokeFunc
 func (info *RuntimeInfo) InvokeHTTPFunc(request *RequestInfo, invokeReq *InvokeHTTPRequest) error {
+	request.cancel = invokeReq.CtxCancel
 	info.requestMap.Store(request.RequestID, request)
 
 	select {
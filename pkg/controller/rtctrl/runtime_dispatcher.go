@@ -34,12 +34,23 @@ import (
 type RuntimeDispatcher interface {
 	// runtime
 	RuntimeList() []*RuntimeInfo
+	RuntimeCount() int
 	GetRuntime(string) (*RuntimeInfo, error)
 	NewRuntime(*NewRuntimeParameters) *RuntimeInfo
 	OccupyColdRuntime(*InvocationInput) (*RuntimeInfo, *api.ScaleUpRecommendation)
+	OccupyEvictedRuntime(*RuntimeInfo, *InvocationInput) (*RuntimeInfo, *api.ScaleUpRecommendation)
 	FindWarmRuntime(*InvocationInput) *RuntimeInfo
+	WaitRuntime(*InvocationInput) (*RuntimeInfo, error)
 	CoolDownRuntime(*RuntimeInfo) (*api.ScaleDownRecommendation, error)
+	DrainRuntime(*RuntimeInfo) (*api.ScaleDownRecommendation, error)
+	SetIdleTimeoutPolicy(IdleTimeoutPolicy)
+	FreezeRuntime(*RuntimeInfo) error
+	ProbeRuntime(*RuntimeInfo) error
+	SetFreezer(Freezer)
+	EvictRuntime(*InvocationInput) (*RuntimeInfo, *api.ScaleDownRecommendation, error)
 	ResetRuntime(*RuntimeInfo) (*api.ScaleDownRecommendation, error)
+	CancelRequest(string, string) (*RuntimeInfo, error)
+	RestoreRuntime(*RuntimeInfo, *api.ContainerFunction) error
 
 	// resource
 	IncreaseUsedResource(*api.Resource) bool
@@ -50,6 +61,8 @@ type RuntimeDispatcher interface {
 
 	// statistic
 	RuntimeStatistics() (cold, inUse, all int)
+	ColdRuntimeCount() int
+	IdleWarmRuntimeCount(commitID string) int
 	ResourceStatistics() *api.ServiceResource
 }
 
@@ -65,8 +78,33 @@ type RuntimeManagerParameters struct {
 	// The longest expire time after runner last reset
 	// Units: seconds
 	MaxRunnerResetTimeout int
+
+	// Max number of requests waiting for runtimes of one function
+	MaxWaitQueueLength int
+
+	// Max time a request waits for a released runtime
+	// Units: milliseconds
+	MaxWaitTime int
+
+	// Policy to pick idle warm runtimes to evict when no cold one is free
+	EvictionPolicy string
+
+	// Idle time before a warm runtime is frozen, 0 means never freeze
+	// Units: seconds
+	RuntimeFreezeIdle int
+
+	// Time a warm runtime has to answer a liveness probe, 0 means never probe
+	// Units: milliseconds
+	ProbeTimeout int
+
+	// Consecutive probe failures before a runtime is invalidated
+	ProbeFailureThreshold int
 }
 
+// IdleTimeoutPolicy returns the idle timeout of the runtimes with the commit id,
+// MaxRuntimeIdle is used when it is not chosen
+type IdleTimeoutPolicy func(commitID string) (time.Duration, bool)
+
 type occupyColdRuntimeContext struct {
 	memBytes *uint64
 	input    *OccupyInput
@@ -82,10 +120,19 @@ type RuntimeManager struct {
 	MaxRuntimeIdle        int
 	MaxRunnerDefunct      int
 	MaxRunnerResetTimeout int
+	RuntimeFreezeIdle     int
+	ProbeTimeout          int
+	ProbeFailureThreshold int
 	rtMap                 sync.Map // TODO: No need to add a lock for map
 	rtArray               []*RuntimeInfo
+	rtArrayLock           sync.RWMutex
 	resource              *api.ServiceResource
 	resourceLock          sync.RWMutex
+	waitQueue             *waitQueue
+	index                 *runtimeIndex
+	evictionPolicy        EvictionPolicy
+	idleTimeoutPolicy     IdleTimeoutPolicy
+	freezer               Freezer
 }
 
 func NewRuntimeManager(r *api.FuncletNodeInfo, params *RuntimeManagerParameters) *RuntimeManager {
@@ -101,11 +148,22 @@ func NewRuntimeManager(r *api.FuncletNodeInfo, params *RuntimeManagerParameters)
 		MaxRuntimeIdle:        params.MaxRuntimeIdle,
 		MaxRunnerDefunct:      params.MaxRunnerDefunct,
 		MaxRunnerResetTimeout: params.MaxRunnerResetTimeout,
+		RuntimeFreezeIdle:     params.RuntimeFreezeIdle,
+		ProbeTimeout:          params.ProbeTimeout,
+		ProbeFailureThreshold: params.ProbeFailureThreshold,
 		rtMap:                 sync.Map{},
 		rtArray:               make([]*RuntimeInfo, 0),
 		resource:              &resource,
 		resourceLock:          sync.RWMutex{},
+		waitQueue:             newWaitQueue(params.MaxWaitQueueLength, time.Duration(params.MaxWaitTime)*time.Millisecond),
+		index:                 newRuntimeIndex(),
 	}
+	rtMap.index.coldFreed = rtMap.coldFreed
+	policy, err := GetEvictionPolicy(params.EvictionPolicy)
+	if err != nil {
+		logs.Warnf("runtime eviction disabled: %s", err)
+	}
+	rtMap.evictionPolicy = policy
 	return rtMap
 }
 
@@ -119,8 +177,20 @@ func (m *RuntimeManager) String() string {
 	return buf.String()
 }
 
+// RuntimeList returns a copy of the runtimes
 func (m *RuntimeManager) RuntimeList() []*RuntimeInfo {
-	return m.rtArray
+	m.rtArrayLock.RLock()
+	defer m.rtArrayLock.RUnlock()
+	list := make([]*RuntimeInfo, len(m.rtArray))
+	copy(list, m.rtArray)
+	return list
+}
+
+// RuntimeCount
+func (m *RuntimeManager) RuntimeCount() int {
+	m.rtArrayLock.RLock()
+	defer m.rtArrayLock.RUnlock()
+	return len(m.rtArray)
 }
 
 func (m *RuntimeManager) NewRuntime(params *NewRuntimeParameters) *RuntimeInfo {
@@ -134,7 +204,14 @@ func (m *RuntimeManager) NewRuntime(params *NewRuntimeParameters) *RuntimeInfo {
 	if loaded {
 		return nil
 	}
+	r.releaseHook = m.handOff
+	r.invokeLock.Lock()
+	r.index = m.index
+	m.index.update(r, "", "")
+	r.invokeLock.Unlock()
+	m.rtArrayLock.Lock()
 	m.rtArray = append(m.rtArray, r)
+	m.rtArrayLock.Unlock()
 	return r
 }
 
@@ -150,6 +227,17 @@ func (m *RuntimeManager) GetRuntime(runtimeID string) (ri *RuntimeInfo, err erro
 	return rt, nil
 }
 
+// CancelRequest cancels the in-flight request of the account on whichever runtime serves it,
+// requests of other accounts are not found
+func (m *RuntimeManager) CancelRequest(requestID, accountID string) (*RuntimeInfo, error) {
+	for _, rt := range m.RuntimeList() {
+		if rt.CancelRequest(requestID, accountID) {
+			return rt, nil
+		}
+	}
+	return nil, RequestNotExist{RequestID: requestID}
+}
+
 func (m *RuntimeManager) RuntimeStatistics() (cold, inUse, all int) {
 	rtlist := m.RuntimeList()
 	if len(rtlist) == 0 {
@@ -160,7 +248,7 @@ func (m *RuntimeManager) RuntimeStatistics() (cold, inUse, all int) {
 		case RuntimeStateCold:
 			cold++
 			all++
-		case RuntimeStateWarmUp, RuntimeStateWarm:
+		case RuntimeStateWarmUp, RuntimeStateWarm, RuntimeStateFrozen:
 			inUse++
 			all++
 		case RuntimeStateMerged, RuntimeStateReclaiming:
@@ -172,6 +260,21 @@ func (m *RuntimeManager) RuntimeStatistics() (cold, inUse, all int) {
 	return
 }
 
+// ColdRuntimeCount returns the number of free cold runtimes
+func (m *RuntimeManager) ColdRuntimeCount() int {
+	return m.index.coldCount()
+}
+
+// IdleWarmRuntimeCount returns the number of warm runtimes of the function serving no request
+func (m *RuntimeManager) IdleWarmRuntimeCount(commitID string) (idle int) {
+	for _, rt := range m.index.warmCandidates(commitID) {
+		if rt.IsIdle() {
+			idle++
+		}
+	}
+	return
+}
+
 func (m *RuntimeManager) ResourceStatistics() (resource *api.ServiceResource) {
 	m.resourceLock.RLock()
 	defer m.resourceLock.RUnlock()
@@ -185,6 +288,16 @@ func (m *RuntimeManager) DelRuntime(runtimeID string) {
 
 // OccupyColdRuntime
 func (m *RuntimeManager) OccupyColdRuntime(req *InvocationInput) (ri *RuntimeInfo, recommend *api.ScaleUpRecommendation) {
+	return m.occupyColdRuntime(req, nil)
+}
+
+// OccupyEvictedRuntime occupies the runtime evicted for the request once it gets cold,
+// other requests never take it over
+func (m *RuntimeManager) OccupyEvictedRuntime(victim *RuntimeInfo, req *InvocationInput) (ri *RuntimeInfo, recommend *api.ScaleUpRecommendation) {
+	return m.occupyColdRuntime(req, victim)
+}
+
+func (m *RuntimeManager) occupyColdRuntime(req *InvocationInput, evicted *RuntimeInfo) (ri *RuntimeInfo, recommend *api.ScaleUpRecommendation) {
 	memBytes := functionMemorySizeToBytes(*req.Configuration.MemorySize)
 	if !m.checkAndMarkResource(int64(memBytes)) {
 		req.Logger.Warnf("resource is insufficient: acquire mem %s, resource %s", memBytes, m.resource)
@@ -202,6 +315,7 @@ func (m *RuntimeManager) OccupyColdRuntime(req *InvocationInput) (ri *RuntimeInf
 		WithStreamMode: req.WithStreamMode,
 		MemorySize:     memBytes,
 		MilliCPUs:      m.resource.Default.MilliCPUs,
+		Evicted:        evicted,
 	}
 	ctx := occupyColdRuntimeContext{
 		input:    input,
@@ -211,13 +325,7 @@ func (m *RuntimeManager) OccupyColdRuntime(req *InvocationInput) (ri *RuntimeInf
 	needScale := m.isNeedScale(memBytes)
 
 	if !needScale {
-		for _, rt := range m.rtArray {
-			if err := rt.CAS(OpOccupy, input); err == nil && rt != nil {
-				ri = rt
-				break
-			}
-		}
-		return ri, nil
+		return m.claimColdRuntime(OpOccupy, input), nil
 	}
 	return m.occupyColdWithScaleUpRecommendation(&ctx)
 }
@@ -226,16 +334,11 @@ func (m *RuntimeManager) occupyColdWithScaleUpRecommendation(ctx *occupyColdRunt
 	var done bool
 	recommend = &api.ScaleUpRecommendation{}
 	ctx.input.MilliCPUs = m.getMilliCPUsByMemory(ctx.input.MemorySize)
-	for _, rt := range m.rtArray {
-		if err := rt.CAS(OpOccupy, ctx.input); err == nil && rt != nil {
-			ri = rt
-			recommend.TargetContainer = rt.RuntimeID
-			break
-		}
-	}
+	ri = m.claimColdRuntime(OpOccupy, ctx.input)
 	if ri == nil {
 		return nil, nil
 	}
+	recommend.TargetContainer = ri.RuntimeID
 	defer func(rt *RuntimeInfo) {
 		if !done {
 			m.rollbackRuntime(rt, ctx.input.CommitID, ctx.logger)
@@ -250,21 +353,17 @@ func (m *RuntimeManager) occupyColdWithScaleUpRecommendation(ctx *occupyColdRunt
 	input := MergedInput{
 		CommitID: ctx.input.CommitID,
 	}
-	var cnt int
-	for _, rt := range m.rtArray {
-		if cnt == scaleCount {
+	for cnt := 0; cnt < scaleCount; cnt++ {
+		rt := m.claimColdRuntime(OpMerged, &input)
+		if rt == nil {
 			break
 		}
-		if err := rt.CAS(OpMerged, &input); err == nil {
-			recommend.MergedContainers = append(recommend.MergedContainers, rt.RuntimeID)
-			rtd := rt
-			defer func(rtr *RuntimeInfo) {
-				if !done {
-					m.rollbackRuntime(rtr, ctx.input.CommitID, ctx.logger)
-				}
-			}(rtd)
-			cnt++
-		}
+		recommend.MergedContainers = append(recommend.MergedContainers, rt.RuntimeID)
+		defer func(rtr *RuntimeInfo) {
+			if !done {
+				m.rollbackRuntime(rtr, ctx.input.CommitID, ctx.logger)
+			}
+		}(rt)
 	}
 	if len(recommend.MergedContainers) == scaleCount {
 		done = true
@@ -276,21 +375,52 @@ func (m *RuntimeManager) occupyColdWithScaleUpRecommendation(ctx *occupyColdRunt
 }
 
 func (m *RuntimeManager) CoolDownRuntime(runtime *RuntimeInfo) (recommend *api.ScaleDownRecommendation, err error) {
-	deadline := time.Now().Add(-time.Duration(m.MaxRuntimeIdle) * time.Second)
+	idle := time.Duration(m.MaxRuntimeIdle) * time.Second
+	if m.idleTimeoutPolicy != nil {
+		if d, ok := m.idleTimeoutPolicy(runtime.CommitID); ok {
+			idle = d
+		}
+	}
+	deadline := time.Now().Add(-idle)
 	if err := runtime.CAS(OpStop, &StopInput{Deadline: deadline}); err != nil {
 		return nil, err
 	}
-	ctx := &occupyScaleDownContext{
-		targetRuntime: runtime,
+	return m.scaleDownRecommendation(runtime)
+}
+
+// DrainRuntime stops an idle runtime regardless of its idle time and provisioning
+func (m *RuntimeManager) DrainRuntime(runtime *RuntimeInfo) (recommend *api.ScaleDownRecommendation, err error) {
+	if err := runtime.CAS(OpStop, &StopInput{Force: true, Drain: true}); err != nil {
+		return nil, err
 	}
-	mem := uint64(runtime.Resource.Memory)
-	ctx.memBytes = &mem
-	needScale := m.isNeedScale(mem)
+	return m.scaleDownRecommendation(runtime)
+}
 
-	if !needScale {
-		return nil, nil
+// RestoreRuntime brings back the function of a runtime warmed before the controller restarted
+func (m *RuntimeManager) RestoreRuntime(runtime *RuntimeInfo, fn *api.ContainerFunction) error {
+	memBytes := functionMemorySizeToBytes(fn.MemorySize)
+	if !m.checkAndMarkResource(int64(memBytes)) {
+		return fmt.Errorf("resource is insufficient to restore runtime %s: acquire mem %d", runtime.RuntimeID, memBytes)
 	}
-	return m.occupyWithScaleDownRecommendation(ctx)
+	input := &RestoreInput{
+		CommitID:       fn.CommitID,
+		MemorySize:     memBytes,
+		MilliCPUs:      m.resource.Default.MilliCPUs,
+		ConcurrentMode: fn.ConcurrentMode && runtime.DefaultConcurrentMode,
+	}
+	if m.isNeedScale(memBytes) {
+		input.MilliCPUs = m.getMilliCPUsByMemory(memBytes)
+	}
+	if err := runtime.CAS(OpRestore, input); err != nil {
+		m.ReleaseMarkedResource(&api.Resource{Memory: int64(memBytes)})
+		return err
+	}
+	return nil
+}
+
+// SetIdleTimeoutPolicy
+func (m *RuntimeManager) SetIdleTimeoutPolicy(policy IdleTimeoutPolicy) {
+	m.idleTimeoutPolicy = policy
 }
 
 func (m *RuntimeManager) ResetRuntime(runtime *RuntimeInfo) (recommend *api.ScaleDownRecommendation, err error) {
@@ -298,6 +428,11 @@ func (m *RuntimeManager) ResetRuntime(runtime *RuntimeInfo) (recommend *api.Scal
 	if err := runtime.CAS(OpReset, &ResetInput{Deadline: deadline}); err != nil {
 		return nil, err
 	}
+	return m.scaleDownRecommendation(runtime)
+}
+
+// scaleDownRecommendation retrieves the runtimes merged into the stopped runtime
+func (m *RuntimeManager) scaleDownRecommendation(runtime *RuntimeInfo) (recommend *api.ScaleDownRecommendation, err error) {
 	ctx := &occupyScaleDownContext{
 		targetRuntime: runtime,
 	}
@@ -325,7 +460,7 @@ func (m *RuntimeManager) occupyWithScaleDownRecommendation(ctx *occupyScaleDownC
 		excludeID, scaleCount, *ctx.memBytes, m.resource.BaseMemory)
 
 	var cnt int
-	for _, rt := range m.rtArray {
+	for _, rt := range m.RuntimeList() {
 		if cnt == scaleCount {
 			break
 		}
@@ -353,15 +488,70 @@ func (m *RuntimeManager) FindWarmRuntime(req *InvocationInput) *RuntimeInfo {
 		CommitID:        *req.Configuration.CommitID,
 		ConcurrentQuota: req.Configuration.PodConcurrentQuota,
 	}
-	for _, rt := range m.rtArray {
+	candidates := m.index.warmCandidates(input.CommitID)
+	for _, rt := range candidates {
 		if err := rt.CAS(OpMark, input); err == nil {
 			return rt
 		}
 	}
+	for _, rt := range candidates {
+		if rt.isFrozen() && m.thawRuntime(rt, input) {
+			return rt
+		}
+	}
 
 	return nil
 }
 
+// claimColdRuntime applies the op to one of the free cold runtimes
+func (m *RuntimeManager) claimColdRuntime(op CASOpType, input interface{}) *RuntimeInfo {
+	if occupy, ok := input.(*OccupyInput); ok && occupy.Evicted != nil {
+		if err := occupy.Evicted.CAS(op, input); err != nil {
+			return nil
+		}
+		return occupy.Evicted
+	}
+	// runtimes failing the op stay in the free list, e.g. the ones evicted for other requests,
+	// so each round moves past the candidates tried already
+	rounds := m.RuntimeCount()/coldBatchSize + 1
+	for i := 0; i < rounds; i++ {
+		candidates := m.index.coldCandidates(i*coldBatchSize, coldBatchSize)
+		for _, rt := range candidates {
+			if err := rt.CAS(op, input); err == nil {
+				return rt
+			}
+		}
+		if len(candidates) < coldBatchSize {
+			break
+		}
+	}
+	return nil
+}
+
+// WaitRuntime waits in the function's queue for a runtime released by other requests,
+// ColdRuntimeFreed is returned when the request should try a freed cold runtime instead
+func (m *RuntimeManager) WaitRuntime(req *InvocationInput) (*RuntimeInfo, error) {
+	input := &MarkInput{
+		CommitID:        *req.Configuration.CommitID,
+		ConcurrentQuota: req.Configuration.PodConcurrentQuota,
+	}
+	if !m.waitQueue.enabled() {
+		return nil, WaitQueueFull{CommitID: input.CommitID}
+	}
+	return m.waitQueue.wait(input, req.Priority, func() *RuntimeInfo {
+		return m.FindWarmRuntime(req)
+	})
+}
+
+func (m *RuntimeManager) handOff(rt *RuntimeInfo, commitID string) {
+	m.waitQueue.handOff(rt, commitID)
+}
+
+// coldFreed lets the waiting requests of any function start the freed cold runtime
+func (m *RuntimeManager) coldFreed() {
+	m.waitQueue.coldFreed()
+}
+
 func (m *RuntimeManager) rollbackRuntime(runtime *RuntimeInfo, commitID string, logger *logs.Logger) {
 	logger.Warnf("rollback runtime %v commit id %s", runtime, commitID)
 	input := RollbackInput{
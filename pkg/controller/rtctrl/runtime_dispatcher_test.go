@@ -17,8 +17,11 @@
 package rtctrl
 
 import (
+	"net"
+	"net/url"
 	"runtime"
 	"strconv"
+	"sync"
 	"testing"
 	"time"
 
@@ -394,3 +397,178 @@ func BenchmarkFindAndMark_400Conc1Func10Rt(b *testing.B) {
 		RuntimeCount:  10,
 	})
 }
+
+func TestCancelRequest(t *testing.T) {
+	rtMap := NewRuntimeManager(getFuncletNode(), &RuntimeManagerParameters{MaxRuntimeIdle: 10, MaxRunnerDefunct: 30})
+	rt := rtMap.NewRuntime(&NewRuntimeParameters{
+		RuntimeID:      "runtime-cancel",
+		ConcurrentMode: true,
+		Resource:       &api.Resource{},
+	})
+	rt.requestChan = make(chan *InvokeRequest, 1)
+	rt.Abortable = true
+
+	request := NewRequestInfo("request-cancel", rt)
+	request.AccountID = "8f6e"
+	request.Status = StatusRunning
+	request.Output = &InvocationOutput{Output: &InvocationResponse{}}
+	rt.requestMap.Store(request.RequestID, request)
+
+	_, err := rtMap.CancelRequest("request-unknown", "8f6e")
+	assert.Equal(t, RequestNotExist{RequestID: "request-unknown"}, err)
+	// requests of other accounts are not found
+	_, err = rtMap.CancelRequest(request.RequestID, "other")
+	assert.Equal(t, RequestNotExist{RequestID: request.RequestID}, err)
+	assert.Equal(t, StatusRunning, request.Status)
+
+	got, err := rtMap.CancelRequest(request.RequestID, "8f6e")
+	assert.Nil(t, err)
+	assert.Equal(t, rt, got)
+	assert.Equal(t, StatusCancelled, request.Status)
+	assert.Equal(t, StatusClientClosedRequest, request.getResponseStatus())
+	select {
+	case <-request.SyncChannel:
+	default:
+		t.Error("expect the cancelled request to be notified")
+	}
+	select {
+	case abort := <-rt.requestChan:
+		assert.Equal(t, &InvokeRequest{RequestID: request.RequestID, Abort: true}, abort)
+	default:
+		t.Error("expect an abort request sent to the runtime")
+	}
+
+	assert.Nil(t, request.Output.Done)
+	assert.Nil(t, rt.loadRequest(request.RequestID))
+
+	_, err = rtMap.CancelRequest(request.RequestID, "8f6e")
+	assert.NotNil(t, err)
+}
+
+func TestCancelRequestNotAbortable(t *testing.T) {
+	rtMap := NewRuntimeManager(getFuncletNode(), &RuntimeManagerParameters{MaxRuntimeIdle: 10, MaxRunnerDefunct: 30})
+	rt := rtMap.NewRuntime(&NewRuntimeParameters{
+		RuntimeID: "runtime-cancel",
+		Resource:  &api.Resource{},
+	})
+	rt.requestChan = make(chan *InvokeRequest, 1)
+
+	request := NewRequestInfo("request-cancel", rt)
+	request.AccountID = "8f6e"
+	request.Status = StatusRunning
+	request.Output = &InvocationOutput{Output: &InvocationResponse{}}
+	rt.requestMap.Store(request.RequestID, request)
+
+	// the caller is answered, the runtime stays occupied until the function returns
+	_, err := rtMap.CancelRequest(request.RequestID, "8f6e")
+	assert.Nil(t, err)
+	assert.Equal(t, StatusCancelled, request.Status)
+	assert.NotNil(t, request.Output.Done)
+	assert.NotNil(t, rt.loadRequest(request.RequestID))
+	assert.Equal(t, 0, len(rt.requestChan))
+	select {
+	case <-request.SyncChannel:
+	default:
+		t.Error("expect the cancelled request to be notified")
+	}
+	_, err = rtMap.CancelRequest(request.RequestID, "8f6e")
+	assert.NotNil(t, err)
+
+	params := url.Values{}
+	params.Set("success", "true")
+	assert.True(t, rt.handleInvokeDone(request.RequestID, &params, "late"))
+	assert.Equal(t, StatusCancelled, request.Status)
+	assert.Nil(t, rt.loadRequest(request.RequestID))
+	assert.Equal(t, int64(0), rt.AcceptReqCnt)
+	assert.Equal(t, int64(0), rt.RejectReqCnt)
+	select {
+	case <-request.detached:
+	default:
+		t.Error("expect the request finished by the runtime")
+	}
+}
+
+func TestInvokeDoneOnce(t *testing.T) {
+	rt := NewRuntimeInfo(&NewRuntimeParameters{RuntimeID: "runtime-done", Resource: &api.Resource{}})
+	request := NewRequestInfo("request-done", rt)
+	request.Status = StatusRunning
+	request.Output = &InvocationOutput{Output: &InvocationResponse{}}
+	rt.requestMap.Store(request.RequestID, request)
+
+	params := url.Values{}
+	params.Set("success", "false")
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rt.handleInvokeDone(request.RequestID, &params, "failed")
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int64(1), rt.RejectReqCnt)
+}
+
+func TestCancelStreamRequest(t *testing.T) {
+	rtMap := NewRuntimeManager(getFuncletNode(), &RuntimeManagerParameters{MaxRuntimeIdle: 10, MaxRunnerDefunct: 30})
+	rt := rtMap.NewRuntime(&NewRuntimeParameters{
+		RuntimeID:  "runtime-cancel",
+		StreamMode: true,
+		Resource:   &api.Resource{},
+	})
+	rt.httpRequestChan = make(chan *InvokeHTTPRequest, 1)
+
+	request := NewRequestInfo("request-cancel", rt)
+	request.AccountID = "8f6e"
+	request.Status = StatusRunning
+	request.Output = &InvocationOutput{Output: &InvocationResponse{}}
+	cancelled := false
+	rt.InvokeHTTPFunc(request, &InvokeHTTPRequest{
+		RequestID: request.RequestID,
+		CtxCancel: func() { cancelled = true },
+	})
+
+	_, err := rtMap.CancelRequest(request.RequestID, "8f6e")
+	assert.Nil(t, err)
+	assert.True(t, cancelled)
+	assert.Equal(t, StatusCancelled, request.Status)
+}
+
+func TestRestoreRuntime(t *testing.T) {
+	rtMap := NewRuntimeManager(getFuncletNode(), &RuntimeManagerParameters{MaxRuntimeIdle: 10, MaxRunnerDefunct: 30})
+	rt := rtMap.NewRuntime(&NewRuntimeParameters{RuntimeID: "runtime-restore", ConcurrentMode: true, WaitRuntimeAliveTimeout: 3})
+	fn := &api.ContainerFunction{
+		CommitID:       "commitID-restore",
+		FunctionBrn:    "brn:cloud:faas:bj:8f6e:function:hello:1",
+		MemorySize:     256,
+		ConcurrentMode: true,
+	}
+	assert.Nil(t, rtMap.RestoreRuntime(rt, fn))
+	assert.Equal(t, RuntimeStateClosed, rt.State)
+	assert.Equal(t, "commitID-restore", rt.CommitID)
+	assert.Equal(t, int64(256*bytefmt.Megabyte), rt.Resource.Memory)
+	assert.Equal(t, int64(200), rt.Resource.MilliCPUs)
+	assert.True(t, rt.Marked)
+	assert.Equal(t, int64(256*bytefmt.Megabyte), rtMap.resource.Marked.Memory)
+
+	// restored only once
+	assert.NotNil(t, rtMap.RestoreRuntime(rt, fn))
+	assert.Equal(t, int64(256*bytefmt.Megabyte), rtMap.resource.Marked.Memory)
+
+	// the reconnected runner waits for the function instead of being cold
+	conn, _ := net.Pipe()
+	defer conn.Close()
+	assert.Nil(t, rt.initRunner(&startRunnerParams{conn: conn}))
+	assert.Equal(t, RuntimeStateWarmUp, rt.State)
+	assert.Equal(t, []*RuntimeInfo{rt}, rtMap.index.warmCandidates("commitID-restore"))
+	assert.Equal(t, 0, rtMap.ColdRuntimeCount())
+
+	// the function did not reconnect in time, the runtime is reset
+	_, err := rtMap.ResetRuntime(rt)
+	assert.Equal(t, RuntimeNoNeedToReset{RuntimeID: rt.RuntimeID}.Error(), err.Error())
+	rt.restoredAt = time.Now().Add(-4 * time.Second)
+	_, err = rtMap.ResetRuntime(rt)
+	assert.Nil(t, err)
+	assert.Equal(t, "", rt.CommitID)
+	assert.True(t, rt.restoredAt.IsZero())
+}
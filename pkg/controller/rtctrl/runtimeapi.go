@@ -0,0 +1,316 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package rtctrl
+package rtctrl
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/baidu/easyfaas/pkg/util/json"
+	"github.com/baidu/easyfaas/pkg/util/logs"
+)
+
+// The runtime API is compatible with the AWS Lambda Runtime API, so that bootstrap binaries
+// of custom runtimes poll invocations of the runtime unmodified with
+// AWS_LAMBDA_RUNTIME_API=<address>/runtimes/<runtime id>/<token>;
+// the token is generated for each warm up and only given to the runtime warmed up
+const (
+	runtimeAPIPrefix = "/runtimes/{runtimeID}/{token}/2018-06-01/runtime"
+
+	headerRuntimeRequestID     = "Lambda-Runtime-Aws-Request-Id"
+	headerRuntimeDeadline      = "Lambda-Runtime-Deadline-Ms"
+	headerRuntimeFunctionArn   = "Lambda-Runtime-Invoked-Function-Arn"
+	headerRuntimeClientContext = "Lambda-Runtime-Client-Context"
+)
+
+// runtimeAPIError: error body of the runtime API
+type runtimeAPIError struct {
+	ErrorMessage string `json:"errorMessage"`
+	ErrorType    string `json:"errorType"`
+}
+
+type runtimeAPIStatus struct {
+	Status string `json:"status"`
+}
+
+func (s *DispatchServerV2) addRuntimeAPIRoutes(r *mux.Router) {
+	r.HandleFunc(runtimeAPIPrefix+"/invocation/next", s.nextInvocationHandler).Methods(http.MethodGet)
+	r.HandleFunc(runtimeAPIPrefix+"/invocation/{requestID}/response", s.invocationResponseHandler).Methods(http.MethodPost)
+	r.HandleFunc(runtimeAPIPrefix+"/invocation/{requestID}/error", s.invocationErrorHandler).Methods(http.MethodPost)
+	r.HandleFunc(runtimeAPIPrefix+"/init/error", s.initErrorHandler).Methods(http.MethodPost)
+}
+
+func (s *DispatchServerV2) getRuntimeAPIRouteHandler() *mux.Router {
+	r := mux.NewRouter()
+	s.addRuntimeAPIRoutes(r)
+	return r
+}
+
+// nextInvocationHandler blocks until an invocation of the runtime arrives;
+// the first poll makes the warming up runtime warm
+func (s *DispatchServerV2) nextInvocationHandler(w http.ResponseWriter, r *http.Request) {
+	rt, ok := s.runtimeAPIRuntime(w, r)
+	if !ok {
+		return
+	}
+	state, protocol := rt.pullState()
+	if protocol == RuntimeProtocolLambda && (state == RuntimeStateStopping || state == RuntimeStateStopped) {
+		writeRuntimeAPIError(w, http.StatusGone, "Runtime.Stopped", "runtime "+rt.RuntimeID+" is stopped")
+		return
+	}
+	requestChan, stopChan, err := s.initPullRuntime(rt)
+	if err != nil {
+		logs.Errorf("init runtime %s from runtime api failed: %s", rt.RuntimeID, err)
+		writeRuntimeAPIError(w, http.StatusForbidden, "Runtime.InitFailed", err.Error())
+		return
+	}
+
+	for {
+		var request *InvokeRequest
+		select {
+		case request = <-requestChan:
+		case <-stopChan:
+			writeRuntimeAPIError(w, http.StatusGone, "Runtime.Stopped", "runtime "+rt.RuntimeID+" is stopped")
+			return
+		case <-r.Context().Done():
+			return
+		}
+		// invocations pulled can not be aborted
+		if request.Abort {
+			continue
+		}
+		requestInfo := rt.loadRequest(request.RequestID)
+		if requestInfo == nil {
+			continue
+		}
+		requestInfo.StepDone(StageSendRequest)
+
+		h := w.Header()
+		h.Set(headerRuntimeRequestID, request.RequestID)
+		if config := requestInfo.Input.Configuration; config != nil {
+			if config.Timeout != nil {
+				deadline := requestInfo.InvokeStartTimeMS + *config.Timeout*1000
+				h.Set(headerRuntimeDeadline, strconv.FormatInt(deadline, 10))
+			}
+			if config.FunctionArn != nil {
+				h.Set(headerRuntimeFunctionArn, *config.FunctionArn)
+			}
+		}
+		if request.ClientContext != "" {
+			h.Set(headerRuntimeClientContext, request.ClientContext)
+		}
+		h.Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if _, err := w.Write([]byte(request.EventObject)); err != nil {
+			// the runtime never gets the request, it would never answer
+			logs.Errorf("send request %s to runtime %s failed: %s", request.RequestID, rt.RuntimeID, err)
+			params := url.Values{"success": []string{"false"}}
+			rt.handleInvokeDone(request.RequestID, &params,
+				fmt.Sprintf("RequestID: %s send request to runtime failed: %s", request.RequestID, err))
+		}
+		return
+	}
+}
+
+func (s *DispatchServerV2) invocationResponseHandler(w http.ResponseWriter, r *http.Request) {
+	s.invocationDone(w, r, true)
+}
+
+func (s *DispatchServerV2) invocationErrorHandler(w http.ResponseWriter, r *http.Request) {
+	s.invocationDone(w, r, false)
+}
+
+func (s *DispatchServerV2) invocationDone(w http.ResponseWriter, r *http.Request, success bool) {
+	rt, ok := s.runtimeAPIRuntime(w, r)
+	if !ok {
+		return
+	}
+	requestID := mux.Vars(r)["requestID"]
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeRuntimeAPIError(w, http.StatusBadRequest, "InvalidRequestContent", err.Error())
+		return
+	}
+	if requestInfo := rt.loadRequest(requestID); requestInfo != nil {
+		requestInfo.StepDone(StageRecvResponse)
+	}
+	params := url.Values{"success": []string{strconv.FormatBool(success)}}
+	if !rt.handleInvokeDone(requestID, &params, string(body)) {
+		writeRuntimeAPIError(w, http.StatusBadRequest, "InvalidRequestID", "request "+requestID+" is not running")
+		return
+	}
+	writeRuntimeAPIStatus(w)
+}
+
+// initErrorHandler: the runtime fails to init, it is invalidated
+// and the request waiting for it fails right away
+func (s *DispatchServerV2) initErrorHandler(w http.ResponseWriter, r *http.Request) {
+	rt, ok := s.runtimeAPIRuntime(w, r)
+	if !ok {
+		return
+	}
+	body, _ := ioutil.ReadAll(r.Body)
+	logs.Errorf("runtime %s init failed: %s", rt.RuntimeID, string(body))
+	if err := rt.initFailed(); err != nil {
+		writeRuntimeAPIError(w, http.StatusForbidden, "Runtime.InitFailed", err.Error())
+		return
+	}
+	writeRuntimeAPIStatus(w)
+}
+
+// runtimeAPIRuntime finds the runtime of the request, the token of the runtime must match
+func (s *DispatchServerV2) runtimeAPIRuntime(w http.ResponseWriter, r *http.Request) (*RuntimeInfo, bool) {
+	vars := mux.Vars(r)
+	rt, err := s.runtimeDispatcher.GetRuntime(vars["runtimeID"])
+	if err != nil {
+		writeRuntimeAPIError(w, http.StatusNotFound, "RuntimeNotFound", err.Error())
+		return nil, false
+	}
+	if !rt.checkRuntimeAPIToken(vars["token"]) {
+		writeRuntimeAPIError(w, http.StatusForbidden, "Runtime.Unauthorized", "invalid token of runtime "+rt.RuntimeID)
+		return nil, false
+	}
+	return rt, true
+}
+
+// initPullRuntime makes the runtime warm with invocations pulled through the runtime API,
+// and returns the request queue of the runtime with its stop channel
+func (s *DispatchServerV2) initPullRuntime(rt *RuntimeInfo) (chan *InvokeRequest, chan struct{}, error) {
+	rt.invokeLock.Lock()
+	warm := rt.State == RuntimeStateWarm && rt.Protocol == RuntimeProtocolLambda
+	streamMode, commitID, available := rt.WithStreamMode, rt.CommitID, rt.available()
+	requestChan, stopChan := rt.requestChan, rt.runtimeStopChan
+	rt.invokeLock.Unlock()
+	if warm {
+		return requestChan, stopChan, nil
+	}
+	if !available {
+		return nil, nil, fmt.Errorf("runtime is invalidated")
+	}
+	if streamMode {
+		return nil, nil, fmt.Errorf("stream mode is not supported by the runtime api")
+	}
+	if commitID == "" {
+		return nil, nil, fmt.Errorf("no function is warmed up on the runtime")
+	}
+	params := &startRuntimeParams{
+		commitID:  commitID,
+		urlParams: url.Values{"concurrentmode": []string{"false"}},
+		protocol:  RuntimeProtocolLambda,
+	}
+	err := rt.initRuntime(params)
+
+	rt.invokeLock.Lock()
+	warm = rt.State == RuntimeStateWarm && rt.Protocol == RuntimeProtocolLambda
+	requestChan, stopChan = rt.requestChan, rt.runtimeStopChan
+	stoppingChan := rt.runtimeStoppingChan
+	rt.invokeLock.Unlock()
+	if err != nil {
+		if warm {
+			// made warm by a concurrent poll
+			return requestChan, stopChan, nil
+		}
+		return nil, nil, err
+	}
+	logs.V(9).Infof("[resource modify]-[increase]: runtime %s, resource %s", rt.RuntimeID, rt.Resource)
+	s.runtimeDispatcher.IncreaseUsedResource(rt.Resource)
+	rt.runtimeWaitGroup.Add(1)
+	go rt.waitPullRuntimeStop(stoppingChan)
+	return requestChan, stopChan, nil
+}
+
+// pullState: the state and protocol of the runtime
+func (info *RuntimeInfo) pullState() (RuntimeStateType, RuntimeProtocol) {
+	info.invokeLock.Lock()
+	defer info.invokeLock.Unlock()
+	return info.State, info.Protocol
+}
+
+// NewRuntimeAPIToken generates the token of the runtime api for the runtime going to warm up
+func (info *RuntimeInfo) NewRuntimeAPIToken() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		logs.Errorf("generate runtime api token of %s failed: %s", info.RuntimeID, err)
+		return ""
+	}
+	info.invokeLock.Lock()
+	defer info.invokeLock.Unlock()
+	info.runtimeAPIToken = hex.EncodeToString(b)
+	return info.runtimeAPIToken
+}
+
+func (info *RuntimeInfo) checkRuntimeAPIToken(token string) bool {
+	info.invokeLock.Lock()
+	defer info.invokeLock.Unlock()
+	return info.runtimeAPIToken != "" &&
+		subtle.ConstantTimeCompare([]byte(info.runtimeAPIToken), []byte(token)) == 1
+}
+
+// initFailed invalidates the warming up runtime and wakes up the request waiting for it
+func (info *RuntimeInfo) initFailed() error {
+	info.invokeLock.Lock()
+	defer info.invokeLock.Unlock()
+
+	if info.State != RuntimeStateCold && info.State != RuntimeStateWarmUp {
+		return &RuntimeStateUnmatched{
+			RuntimeID:     info.RuntimeID,
+			CurrentState:  info.State,
+			ExpectedState: []RuntimeStateType{RuntimeStateCold, RuntimeStateWarmUp},
+		}
+	}
+	if !info.Abnormal {
+		info.SetAbnormal(true)
+		info.AbnormalTimes++
+	}
+	select {
+	case <-info.initFailChan:
+	default:
+		close(info.initFailChan)
+	}
+	return nil
+}
+
+// waitPullRuntimeStop stops the pull runtime when it is going to stop,
+// as there is no connection to lose like the other protocols
+func (info *RuntimeInfo) waitPullRuntimeStop(stoppingChan chan struct{}) {
+	<-stoppingChan
+	info.runtimeWaitGroup.Done()
+	info.stopRuntime()
+}
+
+func writeRuntimeAPIStatus(w http.ResponseWriter) {
+	body, _ := json.Marshal(runtimeAPIStatus{Status: "OK"})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	w.Write(body)
+}
+
+func writeRuntimeAPIError(w http.ResponseWriter, status int, errorType, message string) {
+	body, _ := json.Marshal(runtimeAPIError{ErrorMessage: message, ErrorType: errorType})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
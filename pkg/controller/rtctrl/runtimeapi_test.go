@@ -0,0 +1,192 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package rtctrl
+package rtctrl
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/baidu/easyfaas/pkg/api"
+	"github.com/baidu/easyfaas/pkg/util/logs"
+)
+
+func newPullRequestInfo(rt *RuntimeInfo, requestID string) *RequestInfo {
+	timeout := int64(3)
+	arn := "brn:cloud:faas:bj:8f6e:function:hello:1"
+	reqinfo := NewRequestInfo(requestID, rt)
+	reqinfo.Status = StatusRunning
+	reqinfo.Input = &InvocationInput{
+		Runtime:   rt,
+		RequestID: requestID,
+		Configuration: &api.FunctionConfiguration{
+			FunctionConfiguration: lambda.FunctionConfiguration{
+				Timeout:     &timeout,
+				FunctionArn: &arn,
+			},
+		},
+		Logger: logs.NewLogger(),
+	}
+	reqinfo.Output = &InvocationOutput{Output: &InvocationResponse{}, Statistic: &InvocationStatistic{}}
+	return reqinfo
+}
+
+func pullInvocation(t *testing.T, url string) *http.Response {
+	results := make(chan *http.Response, 1)
+	go func() {
+		resp, err := http.Get(url + "/invocation/next")
+		assert.Nil(t, err)
+		results <- resp
+	}()
+	select {
+	case resp := <-results:
+		return resp
+	case <-time.After(3 * time.Second):
+		t.Fatal("poll next invocation timeout")
+	}
+	return nil
+}
+
+func TestRuntimeAPI(t *testing.T) {
+	rtMap := NewRuntimeManager(getFuncletNode(), &RuntimeManagerParameters{MaxRuntimeIdle: 10, MaxRunnerDefunct: 30})
+	rt := rtMap.NewRuntime(&NewRuntimeParameters{
+		RuntimeID:               "runtime-pull",
+		ConcurrentMode:          true,
+		WaitRuntimeAliveTimeout: 3,
+		Resource:                &api.Resource{},
+	})
+	rt.SetCommitID("commit-1")
+	rt.SetState(RuntimeStateWarmUp)
+	token := rt.NewRuntimeAPIToken()
+	s := NewDispatchServerV2(&DispatcherV2Options{}, rtMap)
+	ts := httptest.NewServer(s.getRuntimeAPIRouteHandler())
+	defer ts.Close()
+	base := ts.URL + "/runtimes/runtime-pull/" + token + "/2018-06-01/runtime"
+
+	// the first poll makes the runtime warm and waits for an invocation
+	go func() {
+		assert.True(t, rt.Wait(3))
+		assert.Equal(t, RuntimeProtocolLambda, rt.Protocol)
+		assert.False(t, rt.ConcurrentMode)
+		reqinfo := newPullRequestInfo(rt, "req-1")
+		assert.Nil(t, rt.InvokeFunc(reqinfo, &InvokeRequest{RequestID: "req-1", EventObject: `{"k":1}`, ClientContext: `{"custom":{}}`}))
+	}()
+	resp := pullInvocation(t, base)
+	body, _ := ioutil.ReadAll(resp.Body)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, `{"k":1}`, string(body))
+	assert.Equal(t, "req-1", resp.Header.Get(headerRuntimeRequestID))
+	assert.Equal(t, "brn:cloud:faas:bj:8f6e:function:hello:1", resp.Header.Get(headerRuntimeFunctionArn))
+	assert.Equal(t, `{"custom":{}}`, resp.Header.Get(headerRuntimeClientContext))
+	assert.NotEmpty(t, resp.Header.Get(headerRuntimeDeadline))
+
+	reqinfo := rt.loadRequest("req-1")
+	resp, err := http.Post(base+"/invocation/req-1/response", "application/json", strings.NewReader(`"done"`))
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+	assert.Equal(t, StatusSuccess, reqinfo.Status)
+	assert.Equal(t, `"done"`, reqinfo.Output.Output.FuncResult)
+
+	// function errors
+	reqinfo = newPullRequestInfo(rt, "req-2")
+	assert.Nil(t, rt.InvokeFunc(reqinfo, &InvokeRequest{RequestID: "req-2", EventObject: `{}`}))
+	pullInvocation(t, base)
+	resp, err = http.Post(base+"/invocation/req-2/error", "application/json", strings.NewReader(`{"errorMessage":"boom","errorType":"Error"}`))
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+	assert.Equal(t, StatusFailed, reqinfo.Status)
+	assert.Equal(t, "Unhandled", reqinfo.Output.Output.FuncError)
+	assert.Equal(t, `{"errorMessage":"boom","errorType":"Error"}`, reqinfo.Output.Output.ErrorInfo)
+
+	// unknown requests and runtimes
+	resp, _ = http.Post(base+"/invocation/req-3/response", "application/json", strings.NewReader(`{}`))
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp, _ = http.Get(ts.URL + "/runtimes/runtime-none/" + token + "/2018-06-01/runtime/invocation/next")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp, _ = http.Get(ts.URL + "/runtimes/runtime-pull/other/2018-06-01/runtime/invocation/next")
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	// the runtime going to stop fails the poll
+	rt.invokeLock.Lock()
+	rt.SetState(RuntimeStateStopping)
+	rt.invokeLock.Unlock()
+	close(rt.runtimeStoppingChan)
+	resp = pullInvocation(t, base)
+	assert.Equal(t, http.StatusGone, resp.StatusCode)
+	for {
+		if state, _ := rt.pullState(); state == RuntimeStateStopped {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRuntimeAPIInitError(t *testing.T) {
+	rtMap := NewRuntimeManager(getFuncletNode(), &RuntimeManagerParameters{MaxRuntimeIdle: 10, MaxRunnerDefunct: 30})
+	rt := rtMap.NewRuntime(&NewRuntimeParameters{
+		RuntimeID:               "runtime-pull",
+		WaitRuntimeAliveTimeout: 3,
+		Resource:                &api.Resource{},
+	})
+	rt.SetCommitID("commit-1")
+	rt.SetState(RuntimeStateWarmUp)
+	token := rt.NewRuntimeAPIToken()
+	s := NewDispatchServerV2(&DispatcherV2Options{}, rtMap)
+	ts := httptest.NewServer(s.getRuntimeAPIRouteHandler())
+	defer ts.Close()
+	base := ts.URL + "/runtimes/runtime-pull/" + token + "/2018-06-01/runtime"
+
+	// the request waiting for the runtime fails right away
+	waited := make(chan bool, 1)
+	go func() {
+		waited <- rt.Wait(10)
+	}()
+	resp, err := http.Post(base+"/init/error", "application/json", strings.NewReader(`{"errorMessage":"boom"}`))
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+	select {
+	case ok := <-waited:
+		assert.False(t, ok)
+	case <-time.After(3 * time.Second):
+		t.Fatal("wait runtime after init error timeout")
+	}
+	assert.False(t, rt.available())
+
+	// the invalidated runtime is not made warm by later polls
+	resp, err = http.Get(base + "/invocation/next")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
+
+func TestRuntimeAPIWithoutFunction(t *testing.T) {
+	rtMap := initRuntimeList(1)
+	s := NewDispatchServerV2(&DispatcherV2Options{}, rtMap)
+	ts := httptest.NewServer(s.getRuntimeAPIRouteHandler())
+	defer ts.Close()
+	rt, _ := rtMap.GetRuntime("runtime-0")
+	token := rt.NewRuntimeAPIToken()
+	resp, err := http.Get(ts.URL + "/runtimes/runtime-0/" + token + "/2018-06-01/runtime/invocation/next")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
@@ -33,6 +33,7 @@ func NewRuntimeInfo(params *NewRuntimeParameters) *RuntimeInfo {
 		Abnormal:                false,
 		State:                   RuntimeStateClosed,
 		runtimeRunChan:          make(chan struct{}),
+		initFailChan:            make(chan struct{}),
 		runtimeStopChan:         make(chan struct{}),
 		runtimeStoppingChan:     make(chan struct{}),
 		requestChan:             make(chan *InvokeRequest, 100), // TODO: length is a magic num
@@ -42,7 +43,7 @@ func NewRuntimeInfo(params *NewRuntimeParameters) *RuntimeInfo {
 		WithStreamMode:          params.StreamMode,
 		Resource:                params.Resource,
 	}
-	if params.IsFrozen {
+	if params.Merged {
 		ri.SetState(RuntimeStateMerged)
 	}
 	return ri
@@ -50,7 +51,9 @@ func NewRuntimeInfo(params *NewRuntimeParameters) *RuntimeInfo {
 
 func (info *RuntimeInfo) SetState(s RuntimeStateType) {
 	logs.V(3).Infof("update runtime %s state %s to %s", info.RuntimeID, info.State, s)
+	old := info.State
 	info.State = s
+	info.notifyIndex(old, info.CommitID)
 }
 
 func (info *RuntimeInfo) SetResource(mem uint64, cpu int64) {
@@ -76,7 +79,22 @@ func (info *RuntimeInfo) SetInitTime(pre, post int64) {
 }
 
 func (info *RuntimeInfo) SetCommitID(cm string) {
+	old := info.CommitID
 	info.CommitID = cm
+	info.notifyIndex(info.State, old)
+}
+
+// SetAbnormal marks the runner available or not
+func (info *RuntimeInfo) SetAbnormal(abnormal bool) {
+	info.Abnormal = abnormal
+	info.notifyIndex(info.State, info.CommitID)
+}
+
+// notifyIndex keeps the runtime index of manager consistent with the state
+func (info *RuntimeInfo) notifyIndex(oldState RuntimeStateType, oldCommitID string) {
+	if info.index != nil {
+		info.index.update(info, oldState, oldCommitID)
+	}
 }
 
 // RebootBegin
@@ -103,6 +121,14 @@ func (info *RuntimeInfo) SetUsed(m bool) {
 	info.Used = m
 }
 
+// SetProvisioned marks the runtime as pre-warmed by provisioned concurrency
+func (info *RuntimeInfo) SetProvisioned(p bool) {
+	info.invokeLock.Lock()
+	defer info.invokeLock.Unlock()
+	logs.V(3).Infof("update runtime %s provisioned %t to %t", info.RuntimeID, info.Provisioned, p)
+	info.Provisioned = p
+}
+
 func (info *RuntimeInfo) Invalidate() {
 	info.invokeLock.Lock()
 	defer info.invokeLock.Unlock()
@@ -113,7 +139,7 @@ func (info *RuntimeInfo) Invalidate() {
 		return
 	}
 
-	info.Abnormal = true
+	info.SetAbnormal(true)
 	info.AbnormalTimes++
 }
 
@@ -132,6 +158,38 @@ func (info *RuntimeInfo) updateLastLivenessTime() {
 	info.LastLivenessTime = time.Now()
 }
 
+// isAlive: runtime is loaded with a function and able to serve it
+func (info *RuntimeInfo) isAlive() bool {
+	return (info.State == RuntimeStateWarmUp || info.State == RuntimeStateWarm || info.State == RuntimeStateFrozen) &&
+		info.available()
+}
+
+// AliveState returns the commit id of the runtime, whether it is alive and provisioned, read under its lock
+func (info *RuntimeInfo) AliveState() (commitID string, alive, provisioned bool) {
+	info.invokeLock.Lock()
+	defer info.invokeLock.Unlock()
+	return info.CommitID, info.isAlive(), info.Provisioned
+}
+
+// IsIdle tells whether the runtime is warm and serves no request, read under its lock
+func (info *RuntimeInfo) IsIdle() bool {
+	info.invokeLock.Lock()
+	defer info.invokeLock.Unlock()
+	return (info.State == RuntimeStateWarm || info.State == RuntimeStateFrozen) && info.Concurrency == 0
+}
+
+// Provision marks the runtime as provisioned if it is still alive with the function of commitID
+func (info *RuntimeInfo) Provision(commitID string) bool {
+	info.invokeLock.Lock()
+	defer info.invokeLock.Unlock()
+	if info.CommitID != commitID || !info.isAlive() {
+		return false
+	}
+	logs.V(3).Infof("update runtime %s provisioned %t to true", info.RuntimeID, info.Provisioned)
+	info.Provisioned = true
+	return true
+}
+
 // available
 func (info *RuntimeInfo) available() bool {
 	return !info.Abnormal
@@ -167,3 +225,11 @@ func (info *RuntimeInfo) IsRunnerDefunct(deadline time.Time) bool {
 
 	return false
 }
+
+// isRestoreExpired: no request waits for the restored runtime, and its function did not reconnect in time
+func (info *RuntimeInfo) isRestoreExpired() bool {
+	if info.restoredAt.IsZero() || info.State != RuntimeStateWarmUp || info.Concurrency != 0 {
+		return false
+	}
+	return time.Since(info.restoredAt) > time.Duration(info.WaitRuntimeAliveTimeout)*time.Second
+}
@@ -195,3 +195,23 @@ func TestRuntimeInfo_IsRunnerDefunct(t *testing.T) {
 		t.Errorf("runner defunct expected false ,but got %v", res)
 	}
 }
+
+func TestProvision(t *testing.T) {
+	rt := NewRuntimeInfo(&NewRuntimeParameters{RuntimeID: "aa", Resource: &api.Resource{}})
+	rt.SetState(RuntimeStateWarm)
+	rt.SetCommitID("commit-1")
+	if rt.Provision("commit-2") {
+		t.Error("runtime of another function should not be provisioned")
+	}
+	rt.SetState(RuntimeStateStopping)
+	if rt.Provision("commit-1") {
+		t.Error("stopping runtime should not be provisioned")
+	}
+	rt.SetState(RuntimeStateWarm)
+	if !rt.Provision("commit-1") {
+		t.Error("expect alive runtime provisioned")
+	}
+	if commitID, alive, provisioned := rt.AliveState(); commitID != "commit-1" || !alive || !provisioned {
+		t.Errorf("unexpected alive state %s %t %t", commitID, alive, provisioned)
+	}
+}
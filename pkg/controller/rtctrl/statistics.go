@@ -71,6 +71,8 @@ func statisticsInfo(request *RequestInfo) *StatisticInfo {
 		statusCode = http.StatusOK
 	} else if request.Status == StatusTimeout {
 		statusCode = http.StatusRequestTimeout
+	} else if request.Status == StatusCancelled {
+		statusCode = StatusClientClosedRequest
 	}
 	msg := StatisticInfo{
 		UserID:     request.Input.User.ID,
@@ -0,0 +1,156 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rtctrl
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/baidu/easyfaas/pkg/api"
+	"github.com/baidu/easyfaas/pkg/util/logs"
+)
+
+// responseStream: chunks of a generic runtime response piped to the caller;
+// chunks are queued and written by the stream's own goroutine,
+// so that a slow caller never blocks the other responses of the runtime
+type responseStream struct {
+	requestID string
+	writer    *io.PipeWriter
+
+	lock    sync.Mutex
+	pending []string
+	closed  bool
+	err     error
+	signal  chan struct{}
+}
+
+func newResponseStream(requestID string) (*responseStream, io.ReadCloser) {
+	reader, writer := io.Pipe()
+	stream := &responseStream{
+		requestID: requestID,
+		writer:    writer,
+		signal:    make(chan struct{}, 1),
+	}
+	go stream.writeLoop()
+	return stream, reader
+}
+
+// write queues a chunk of the response
+func (s *responseStream) write(data string) {
+	s.lock.Lock()
+	if !s.closed {
+		s.pending = append(s.pending, data)
+	}
+	s.lock.Unlock()
+	s.notify()
+}
+
+// close ends the response after the queued chunks, the reader gets err if it is not nil
+func (s *responseStream) close(err error) {
+	s.lock.Lock()
+	if s.closed {
+		s.lock.Unlock()
+		return
+	}
+	s.closed = true
+	s.err = err
+	s.lock.Unlock()
+	if err != nil {
+		logs.Warnf("response stream of %s aborted: %s", s.requestID, err)
+		// unblocks the write to a caller not reading any more
+		s.writer.CloseWithError(err)
+	}
+	s.notify()
+}
+
+func (s *responseStream) notify() {
+	select {
+	case s.signal <- struct{}{}:
+	default:
+	}
+}
+
+func (s *responseStream) writeLoop() {
+	for range s.signal {
+		s.lock.Lock()
+		pending, closed, err := s.pending, s.closed, s.err
+		s.pending = nil
+		s.lock.Unlock()
+
+		for _, data := range pending {
+			if _, werr := io.WriteString(s.writer, data); werr != nil {
+				logs.Warnf("write chunk of %s failed: %s", s.requestID, werr)
+				break
+			}
+		}
+		if closed {
+			s.writer.CloseWithError(err)
+			return
+		}
+	}
+}
+
+// canStream: event invocations have no caller to stream to, their chunks are buffered
+func (info *RequestInfo) canStream() bool {
+	return info.Input != nil && info.Input.InvokeType != api.InvokeTypeEvent &&
+		info.Output != nil && info.Output.Output.Response != nil
+}
+
+// handleInvokeChunk writes a partial result of the request;
+// the first chunk hands the response over to a stream and wakes up the caller,
+// while the request stays in flight until the final response of the runtime
+func (info *RuntimeInfo) handleInvokeChunk(requestID string, data string) {
+	request := info.loadRequest(requestID)
+	if request == nil {
+		return
+	}
+	if request.stream != nil {
+		request.stream.write(data)
+		return
+	}
+	if !request.canStream() {
+		request.chunks.WriteString(data)
+		return
+	}
+	if !request.IsRunning() {
+		return
+	}
+	stream, reader := newResponseStream(requestID)
+	if !request.detach(stream) {
+		stream.close(nil)
+		return
+	}
+	response := request.Output.Output.Response
+	response.StatusCode = http.StatusOK
+	response.BodyStream = reader
+	stream.write(data)
+	request.Notify()
+}
+
+// finishStream ends the stream of the request with the final response of the runtime
+func (info *RequestInfo) finishStream(output *InvokeResponse) {
+	if !output.Success {
+		info.stream.close(errors.New(output.FuncError))
+		return
+	}
+	if output.FuncResult != "" {
+		info.stream.write(output.FuncResult)
+	}
+	info.stream.close(nil)
+}
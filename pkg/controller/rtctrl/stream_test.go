@@ -0,0 +1,100 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package rtctrl
+package rtctrl
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/baidu/easyfaas/pkg/api"
+)
+
+func newStreamRuntime() *RuntimeInfo {
+	return NewRuntimeInfo(&NewRuntimeParameters{
+		RuntimeID:               "runtime-stream",
+		WaitRuntimeAliveTimeout: 3,
+		Resource:                &api.Resource{},
+	})
+}
+
+func TestStreamResponse(t *testing.T) {
+	rt := newStreamRuntime()
+	request := newPullRequestInfo(rt, "req-1")
+	request.Output.Output.Response = api.NewInvokeProxyResponseWithRequestID("req-1")
+	rt.requestMap.Store(request.RequestID, request)
+
+	// the first chunk wakes up the caller, the request stays in flight
+	rt.handleInvokeChunk("req-1", "data: 1\n\n")
+	select {
+	case <-request.SyncChannel:
+	case <-time.After(3 * time.Second):
+		t.Fatal("wait stream timeout")
+	}
+	assert.Equal(t, StatusRunning, request.Status)
+	assert.NotNil(t, rt.loadRequest("req-1"))
+	assert.NotNil(t, request.Output.Done)
+	response := request.Output.Output.Response
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.NotNil(t, response.BodyStream)
+
+	// a slow reader does not block the runtime
+	rt.handleInvokeChunk("req-1", "data: 2\n\n")
+	rt.handleInvokeResponse(&InvokeResponse{RequestID: "req-1", Success: true, FuncResult: "data: end\n\n"}, url.Values{})
+	assert.Equal(t, StatusSuccess, request.Status)
+	assert.Equal(t, "", request.Output.Output.FuncResult)
+	assert.Nil(t, rt.loadRequest("req-1"))
+	<-request.detached
+
+	body, err := ioutil.ReadAll(response.BodyStream)
+	assert.Nil(t, err)
+	assert.Equal(t, "data: 1\n\ndata: 2\n\ndata: end\n\n", string(body))
+}
+
+func TestStreamResponseAborted(t *testing.T) {
+	rt := newStreamRuntime()
+	request := newPullRequestInfo(rt, "req-1")
+	request.Output.Output.Response = api.NewInvokeProxyResponseWithRequestID("req-1")
+	rt.requestMap.Store(request.RequestID, request)
+
+	rt.handleInvokeChunk("req-1", "part")
+	<-request.SyncChannel
+	rt.handleInvokeResponse(&InvokeResponse{RequestID: "req-1", FuncError: "boom"}, url.Values{})
+	assert.Equal(t, StatusFailed, request.Status)
+	_, err := ioutil.ReadAll(request.Output.Output.Response.BodyStream)
+	assert.EqualError(t, err, "boom")
+}
+
+func TestChunksOfEventInvocation(t *testing.T) {
+	rt := newStreamRuntime()
+	request := newPullRequestInfo(rt, "req-1")
+	request.Input.InvokeType = api.InvokeTypeEvent
+	rt.requestMap.Store(request.RequestID, request)
+
+	// event invocations have no caller to stream to, the chunks are buffered
+	rt.handleInvokeChunk("req-1", "chunk 1,")
+	rt.handleInvokeChunk("req-1", "chunk 2,")
+	assert.NotNil(t, rt.loadRequest("req-1"))
+	assert.Nil(t, request.Output.Done)
+	rt.handleInvokeResponse(&InvokeResponse{RequestID: "req-1", Success: true, FuncResult: "end"}, url.Values{})
+	assert.Equal(t, "chunk 1,chunk 2,end", request.Output.Output.FuncResult)
+}
@@ -34,6 +34,7 @@ const (
 	RuntimeStateCold       RuntimeStateType = "cold"
 	RuntimeStateWarmUp     RuntimeStateType = "warmup"
 	RuntimeStateWarm       RuntimeStateType = "warm"
+	RuntimeStateFrozen     RuntimeStateType = "frozen"
 	RuntimeStateMerged     RuntimeStateType = "merged"
 	RuntimeStateStopping   RuntimeStateType = "stopping"
 	RuntimeStateStopped    RuntimeStateType = "stopped"
@@ -46,8 +47,9 @@ type NewRuntimeParameters struct {
 	ConcurrentMode          bool
 	StreamMode              bool
 	WaitRuntimeAliveTimeout int
-	IsFrozen                bool
 	Resource                *api.Resource
+	// Merged: the container is kept frozen by funclet without any function, it is not dispatched
+	Merged bool
 }
 
 type ReportedRunnerInfo struct {
@@ -62,6 +64,9 @@ type startRuntimeParams struct {
 	conn       net.Conn
 	warmNotify chan struct{}
 	urlParams  url.Values
+	protocol   RuntimeProtocol
+	probe      bool
+	abortable  bool
 }
 
 type startRunnerParams struct {
@@ -77,6 +82,9 @@ type InvokeRequest struct {
 	SecurityToken   string `json:"securityToken"`
 	ClientContext   string `json:"clientContext,omitempty"`
 	EventObject     string `json:"eventObject,omitempty"`
+	Abort           bool   `json:"abort,omitempty"`
+	// Ping: liveness probe the runtime answers with a pong of the same request id
+	Ping bool `json:"ping,omitempty"`
 }
 
 type InvokeHTTPRequest struct {
@@ -97,12 +105,18 @@ type InvokeResponse struct {
 	Success    bool   `json:"success"`
 	FuncResult string `json:"result,omitempty"`
 	FuncError  string `json:"error,omitempty"`
+	// Chunk: a partial result, the response without chunk ends it
+	Chunk bool `json:"chunk,omitempty"`
+	// Pong: answer of the ping with the request id
+	Pong bool `json:"pong,omitempty"`
 }
 
 type RuntimeInfo struct {
 	RuntimeID  string `json:"RuntimeID"`
 	invokeLock sync.Mutex
 	rebootLock sync.Mutex
+	// freezeLock: serializes freezing and thawing of the runtime processes
+	freezeLock sync.Mutex
 
 	// runtime state machine
 	State         RuntimeStateType `json:"State"`
@@ -110,6 +124,16 @@ type RuntimeInfo struct {
 	Marked        bool             `json:"marked"`
 	Abnormal      bool             `json:"abnormal"`
 	AbnormalTimes uint             `json:"abnormalTimes"`
+	// ProbeFailures: consecutive liveness probes the runtime failed to answer
+	ProbeFailures uint `json:"probeFailures"`
+	// Unresponsive: the runtime failed too many probes and waits to be reborn
+	Unresponsive bool `json:"unresponsive"`
+	// Provisioned: pre-warmed for provisioned concurrency, exempt from idle cool down
+	Provisioned bool `json:"provisioned"`
+	// restore: function warmed before the controller restarted, applied when the runner reconnects
+	restore *RestoreInput
+	// restoredAt: when the runner of the restored runtime reconnected, zero once its function is alive
+	restoredAt time.Time
 
 	// runtime resource
 	Resource *api.Resource `json:"Resource"`
@@ -124,6 +148,13 @@ type RuntimeInfo struct {
 	WithStreamMode          bool   `json:"WithStreamMode"` // is http stream mode
 	WaitRuntimeAliveTimeout int    `json:"WaitRuntimeAliveTimeout"`
 
+	// Protocol: wire protocol negotiated by the generic runtime
+	Protocol RuntimeProtocol `json:"Protocol"`
+	// Probe: the runtime answers ping frames
+	Probe bool `json:"Probe"`
+	// Abortable: the runtime aborts requests on abort frames
+	Abortable bool `json:"Abortable"`
+
 	// Statistics
 	PreLoadTimeMS  int64 `json:"PreLoadTimeMS"`
 	PostLoadTimeMS int64 `json:"PostLoadTimeMS"`
@@ -158,12 +189,22 @@ type RuntimeInfo struct {
 	retryDeadline    time.Duration
 
 	requestMap sync.Map
+	// probeMap: pings waiting for their pongs
+	probeMap sync.Map
 	// rebootWaitGroup: runner reboot wait group
 	// wait for cooldown/reborn process to finish
 	rebootWaitGroup sync.WaitGroup
 	// runtimeRunChan
 	// notify the request waiting list that runtime can handler the requests
 	runtimeRunChan chan struct{}
+	// initFailChan
+	// notify the request waiting list that runtime failed to init
+	initFailChan chan struct{}
+	// runtimeAPIToken: token of the runtime api given to the runtime warmed up last
+	runtimeAPIToken string
+	// evicting: the runtime is evicted for a request,
+	// only that request could occupy it after it gets cold
+	evicting bool
 	// runtimeStopChan
 	// notify the background goroutine that runtime is going to stop
 	runtimeStopChan chan struct{}
@@ -173,6 +214,13 @@ type RuntimeInfo struct {
 	// runtimeWaitGroup
 	// waiting for all the background goroutines to finish
 	runtimeWaitGroup sync.WaitGroup
+
+	// releaseHook
+	// called after the occupation of runtime is released
+	releaseHook func(rt *RuntimeInfo, commitID string)
+
+	// index: runtime index of manager, updated with state transitions
+	index *runtimeIndex
 }
 
 // InvocationInput function call input param
@@ -189,6 +237,9 @@ type InvocationInput struct {
 	// User xxx
 	User *api.User
 
+	// AccountID: the account invoking the function
+	AccountID string
+
 	// The object for the Lambda function location.
 	Code *api.FunctionCodeLocation
 
@@ -201,6 +252,9 @@ type InvocationInput struct {
 	// define whether transfer request body as a stream
 	WithStreamMode bool
 
+	// priority class of the invocation
+	Priority Priority
+
 	Request  *api.InvokeProxyRequest
 	Response *api.InvokeProxyResponse
 
@@ -210,6 +264,9 @@ type InvocationInput struct {
 	// IsLogTail
 	IsLogTail bool
 
+	// ClientContext: client context of the caller, passed to the runtime as it is
+	ClientContext string
+
 	Logger      *logs.Logger
 	InvokeType  string
 	TriggerType string
@@ -218,6 +275,11 @@ type InvocationInput struct {
 type InvocationOutput struct {
 	Output    *InvocationResponse
 	Statistic *InvocationStatistic
+	// RuntimeNotReady: the runtime did not get alive in time, e.g. the function failed to init
+	RuntimeNotReady bool
+	// Done: closed when the runtime is done with a request returned early, e.g. streamed or cancelled;
+	// nil if the request is finished when it returns
+	Done <-chan struct{}
 }
 
 // InvocationOutput function call output param
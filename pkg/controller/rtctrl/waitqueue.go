@@ -0,0 +1,237 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package rtctrl
+package rtctrl
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/baidu/easyfaas/pkg/util/logs"
+	"github.com/baidu/easyfaas/pkg/util/logs/metric"
+)
+
+// runtimeWaiter is a request waiting for a released runtime
+type runtimeWaiter struct {
+	input       *MarkInput
+	priority    Priority
+	runtimeChan chan *RuntimeInfo
+	enqueueTime time.Time
+	served      bool
+}
+
+// waitQueue: per function queues of the requests waiting for runtimes,
+// ordered by priority and FIFO within the same priority
+type waitQueue struct {
+	lock      sync.Mutex
+	queues    map[string]*list.List
+	maxLength int
+	maxWait   time.Duration
+	// cold is signaled when a runtime joins the free cold runtimes,
+	// the waiter receiving it wakes up the first waiter of all the functions
+	cold chan struct{}
+}
+
+func newWaitQueue(maxLength int, maxWait time.Duration) *waitQueue {
+	return &waitQueue{
+		queues:    make(map[string]*list.List),
+		maxLength: maxLength,
+		maxWait:   maxWait,
+		cold:      make(chan struct{}, 1),
+	}
+}
+
+func (q *waitQueue) enabled() bool {
+	return q.maxLength > 0 && q.maxWait > 0
+}
+
+func (q *waitQueue) push(w *runtimeWaiter) (*list.Element, error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	l, ok := q.queues[w.input.CommitID]
+	if !ok {
+		l = list.New()
+		q.queues[w.input.CommitID] = l
+	}
+	if l.Len() >= q.maxLength {
+		return nil, WaitQueueFull{CommitID: w.input.CommitID, Length: l.Len()}
+	}
+	metric.AddGauge(waitQueueDepthIndex, 1)
+	for e := l.Back(); e != nil; e = e.Prev() {
+		if e.Value.(*runtimeWaiter).priority >= w.priority {
+			return l.InsertAfter(w, e), nil
+		}
+	}
+	return l.PushFront(w), nil
+}
+
+// remove returns false if the waiter has already been served
+func (q *waitQueue) remove(commitID string, e *list.Element) bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if e.Value.(*runtimeWaiter).served {
+		return false
+	}
+	q.removeLocked(commitID, q.queues[commitID], e)
+	return true
+}
+
+func (q *waitQueue) removeLocked(commitID string, l *list.List, e *list.Element) {
+	l.Remove(e)
+	if l.Len() == 0 {
+		delete(q.queues, commitID)
+	}
+	metric.SubGauge(waitQueueDepthIndex, 1)
+}
+
+// handOff marks the runtime released by the function of commitID for its waiters in queue order
+func (q *waitQueue) handOff(rt *RuntimeInfo, commitID string) {
+	if commitID == "" {
+		return
+	}
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	l, ok := q.queues[commitID]
+	if !ok {
+		return
+	}
+	for l.Len() > 0 {
+		e := l.Front()
+		w := e.Value.(*runtimeWaiter)
+		if err := rt.CAS(OpMark, w.input); err != nil {
+			return
+		}
+		q.removeLocked(commitID, l, e)
+		w.served = true
+		w.runtimeChan <- rt
+	}
+}
+
+// coldFreed signals the waiters without blocking, it is called under the runtime lock
+func (q *waitQueue) coldFreed() {
+	select {
+	case q.cold <- struct{}{}:
+	default:
+	}
+}
+
+// wakeCold wakes up the waiter of the highest priority among all the functions,
+// the earliest one within the same priority, to start a cold runtime
+func (q *waitQueue) wakeCold() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	var (
+		first    *list.Element
+		commitID string
+	)
+	for id, l := range q.queues {
+		e := l.Front()
+		if e == nil {
+			continue
+		}
+		w := e.Value.(*runtimeWaiter)
+		if first != nil {
+			f := first.Value.(*runtimeWaiter)
+			if w.priority < f.priority || (w.priority == f.priority && !w.enqueueTime.Before(f.enqueueTime)) {
+				continue
+			}
+		}
+		first, commitID = e, id
+	}
+	if first == nil {
+		return
+	}
+	w := first.Value.(*runtimeWaiter)
+	q.removeLocked(commitID, q.queues[commitID], first)
+	w.served = true
+	w.runtimeChan <- nil
+}
+
+// wait blocks until a runtime is handed off or the max wait time passes;
+// a waiter woken up for a freed cold runtime gets ColdRuntimeFreed
+// find is retried once the request is queued, since a runtime may be released before that
+func (q *waitQueue) wait(input *MarkInput, priority Priority, find func() *RuntimeInfo) (*RuntimeInfo, error) {
+	w := &runtimeWaiter{
+		input:       input,
+		priority:    priority,
+		runtimeChan: make(chan *RuntimeInfo, 1),
+		enqueueTime: time.Now(),
+	}
+	e, err := q.push(w)
+	if err != nil {
+		return nil, err
+	}
+	if rt := find(); rt != nil {
+		if q.remove(input.CommitID, e) {
+			return rt, nil
+		}
+		served := <-w.runtimeChan
+		if served == nil {
+			// woken up for a cold runtime, the warm one found is enough
+			return rt, nil
+		}
+		// served in the meantime, give the extra runtime back
+		if err := rt.Release(); err != nil {
+			logs.Errorf("release runtime %s failed: %s", rt.RuntimeID, err)
+		}
+		return served, nil
+	}
+
+	timer := time.NewTimer(q.maxWait)
+	defer timer.Stop()
+	for waiting := true; waiting; {
+		select {
+		case rt := <-w.runtimeChan:
+			return q.served(w, rt)
+		case <-q.cold:
+			q.wakeCold()
+		case <-timer.C:
+			waiting = false
+		}
+	}
+	if !q.remove(input.CommitID, e) {
+		// served between timeout and removal
+		return q.served(w, <-w.runtimeChan)
+	}
+	observeWaitLatency(w.enqueueTime, waitResultTimeout)
+	return nil, WaitRuntimeTimeout{CommitID: input.CommitID, Timeout: q.maxWait}
+}
+
+// served returns the runtime handed off to the waiter, nil means a cold runtime was freed
+func (q *waitQueue) served(w *runtimeWaiter, rt *RuntimeInfo) (*RuntimeInfo, error) {
+	if rt == nil {
+		observeWaitLatency(w.enqueueTime, waitResultCold)
+		return nil, ColdRuntimeFreed{CommitID: w.input.CommitID}
+	}
+	observeWaitLatency(w.enqueueTime, waitResultHandOff)
+	return rt, nil
+}
+
+// length returns the number of waiting requests of the function
+func (q *waitQueue) length(commitID string) int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if l, ok := q.queues[commitID]; ok {
+		return l.Len()
+	}
+	return 0
+}
+
+func observeWaitLatency(start time.Time, result string) {
+	metric.Observe(waitQueueLatencyIndex, float64(time.Since(start)/time.Millisecond), result)
+}
@@ -0,0 +1,160 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package rtctrl
+package rtctrl
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/baidu/easyfaas/pkg/api"
+	"github.com/baidu/easyfaas/pkg/util/logs"
+)
+
+func initWaitRuntimeList(num, maxLength, maxWait int) *RuntimeManager {
+	rtMap := initRuntimeList(num)
+	rtMap.waitQueue = newWaitQueue(maxLength, time.Duration(maxWait)*time.Millisecond)
+	return rtMap
+}
+
+func waitInput(commitID string) *InvocationInput {
+	return &InvocationInput{
+		Configuration: &api.FunctionConfiguration{
+			CommitID:           &commitID,
+			PodConcurrentQuota: 1,
+			FunctionConfiguration: lambda.FunctionConfiguration{
+				MemorySize: &minMemory,
+			},
+		},
+	}
+}
+
+func TestWaitRuntimeHandOff(t *testing.T) {
+	rtMap := initWaitRuntimeList(1, 2, 2000)
+	input := waitInput("commitID-wait")
+	rt, _ := rtMap.OccupyColdRuntime(input)
+	assert.NotNil(t, rt)
+
+	results := make(chan *RuntimeInfo, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			got, err := rtMap.WaitRuntime(input)
+			assert.Nil(t, err)
+			results <- got
+		}()
+	}
+	for rtMap.waitQueue.length("commitID-wait") != 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// a third waiter exceeds the queue length
+	_, err := rtMap.WaitRuntime(input)
+	assert.IsType(t, WaitQueueFull{}, err)
+
+	assert.Nil(t, rt.Release())
+	first := <-results
+	assert.Equal(t, rt.RuntimeID, first.RuntimeID)
+	assert.Equal(t, uint64(1), first.Concurrency)
+	assert.Equal(t, 1, rtMap.waitQueue.length("commitID-wait"))
+
+	assert.Nil(t, first.Release())
+	second := <-results
+	assert.Equal(t, rt.RuntimeID, second.RuntimeID)
+	assert.Equal(t, 0, rtMap.waitQueue.length("commitID-wait"))
+	assert.Nil(t, second.Release())
+}
+
+func TestWaitRuntimeTimeout(t *testing.T) {
+	rtMap := initWaitRuntimeList(1, 2, 10)
+	input := waitInput("commitID-timeout")
+	rt, _ := rtMap.OccupyColdRuntime(input)
+	assert.NotNil(t, rt)
+
+	got, err := rtMap.WaitRuntime(input)
+	assert.Nil(t, got)
+	assert.IsType(t, WaitRuntimeTimeout{}, err)
+	assert.Equal(t, 0, rtMap.waitQueue.length("commitID-timeout"))
+
+	// the released runtime is not handed to anyone
+	assert.Nil(t, rt.Release())
+	assert.Equal(t, uint64(0), rt.Concurrency)
+}
+
+func TestWaitRuntimeDisabled(t *testing.T) {
+	rtMap := initWaitRuntimeList(1, 0, 0)
+	_, err := rtMap.WaitRuntime(waitInput("commitID-disabled"))
+	assert.IsType(t, WaitQueueFull{}, err)
+}
+
+func TestWaitRuntimePriority(t *testing.T) {
+	rtMap := initWaitRuntimeList(1, 3, 2000)
+	input := waitInput("commitID-priority")
+	rt, _ := rtMap.OccupyColdRuntime(input)
+	assert.NotNil(t, rt)
+
+	results := make(chan Priority, 3)
+	for i, p := range []Priority{PriorityLow, PriorityNormal, PriorityHigh} {
+		waiter := waitInput("commitID-priority")
+		waiter.Priority = p
+		go func() {
+			got, err := rtMap.WaitRuntime(waiter)
+			assert.Nil(t, err)
+			results <- waiter.Priority
+			assert.Nil(t, got.Release())
+		}()
+		for rtMap.waitQueue.length("commitID-priority") != i+1 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	assert.Nil(t, rt.Release())
+	assert.Equal(t, PriorityHigh, <-results)
+	assert.Equal(t, PriorityNormal, <-results)
+	assert.Equal(t, PriorityLow, <-results)
+}
+
+func TestWaitRuntimeColdFreed(t *testing.T) {
+	rtMap := initWaitRuntimeList(1, 2, 2000)
+	rt, _ := rtMap.OccupyColdRuntime(waitInput("commitID-other"))
+	assert.NotNil(t, rt)
+
+	results := make(chan string, 2)
+	for i, p := range []Priority{PriorityNormal, PriorityHigh} {
+		waiter := waitInput("commitID-cold-" + strconv.Itoa(i))
+		waiter.Priority = p
+		go func() {
+			_, err := rtMap.WaitRuntime(waiter)
+			if _, ok := err.(ColdRuntimeFreed); ok {
+				results <- *waiter.Configuration.CommitID
+				return
+			}
+			results <- err.Error()
+		}()
+		for rtMap.waitQueue.length(*waiter.Configuration.CommitID) != 1 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	// the runtime of another function goes back to cold, the first waiter of all functions tries it
+	rtMap.rollbackRuntime(rt, "commitID-other", logs.NewLogger())
+	assert.Equal(t, "commitID-cold-1", <-results)
+	assert.Equal(t, 1, rtMap.waitQueue.length("commitID-cold-0"))
+}
@@ -29,6 +29,7 @@ import (
 	"github.com/baidu/easyfaas/cmd/controller/options"
 	"github.com/baidu/easyfaas/pkg/api"
 	"github.com/baidu/easyfaas/pkg/controller/rtctrl"
+	"github.com/baidu/easyfaas/pkg/funclet/client"
 )
 
 // cronTask: response to gc and health check
@@ -40,10 +41,17 @@ func (controller *Controller) cronTask(opt *options.ControllerOptions) {
 	ticker := time.NewTicker(interval)
 	for {
 		select {
+		case <-controller.drain.stopCh:
+			ticker.Stop()
+			logger.Info("stop cron task")
+			return
 		case <-ticker.C:
 			logger.Debug("start cron task")
 			controller.resourceTask(logger)
 			controller.runtimeTask(logger)
+			controller.provisionTask(logger)
+			controller.prewarmTask(logger)
+			controller.rateLimitTask(logger)
 			logger.Debug("finish cron task")
 		}
 	}
@@ -56,6 +64,10 @@ func (controller *Controller) metricTask(opt *options.ControllerOptions) {
 	ticker := time.NewTicker(interval)
 	for {
 		select {
+		case <-controller.drain.stopCh:
+			ticker.Stop()
+			logger.Info("stop metric task")
+			return
 		case <-ticker.C:
 			logger.Debug("start metric task")
 			controller.overallMetrics()
@@ -65,6 +77,48 @@ func (controller *Controller) metricTask(opt *options.ControllerOptions) {
 	}
 }
 
+// probeTask: liveness probes of the warm runtimes
+func (controller *Controller) probeTask(opt *options.ControllerOptions) {
+	taskID := id.GetTaskID()
+	logger := logs.NewLogger().WithField("probe_task_id", taskID)
+	interval := time.Duration(opt.RuntimeProbeInterval) * time.Second
+	ticker := time.NewTicker(interval)
+	for {
+		select {
+		case <-controller.drain.stopCh:
+			ticker.Stop()
+			logger.Info("stop probe task")
+			return
+		case <-ticker.C:
+			logger.Debug("start probe task")
+			runtimes := controller.runtimeDispatcher.RuntimeList()
+			var wg sync.WaitGroup
+			for _, runtime := range runtimes {
+				wg.Add(1)
+				go func(rt *rtctrl.RuntimeInfo) {
+					controller.probe(rt, logger)
+					wg.Done()
+				}(runtime)
+			}
+			wg.Wait()
+			logger.Debug("finish probe task")
+		}
+	}
+}
+
+// probe reborns the runtime failed too many liveness probes
+func (controller *Controller) probe(runtime *rtctrl.RuntimeInfo, logger *logs.Logger) {
+	err := controller.runtimeDispatcher.ProbeRuntime(runtime)
+	if err == nil {
+		return
+	}
+	logger.V(9).Infof("probe runtime %s failed: %s", runtime.RuntimeID, err)
+	if runtime.IsUnresponsive() {
+		logger.Warnf("runtime %s is unresponsive, reborn it", runtime.RuntimeID)
+		controller.reborn(runtime, logger)
+	}
+}
+
 func (controller *Controller) resourceTask(logger *logs.Logger) {
 	logger.Info("start resource task")
 	nodeInfo, err := controller.FuncletClient.NodeInfo(&api.FuncletClientListNodeInput{RequestID: id.GetRequestID()})
@@ -120,6 +174,39 @@ func (controller *Controller) runtimeTask(logger *logs.Logger) {
 func (controller *Controller) processRuntime(runtime *rtctrl.RuntimeInfo, logger *logs.Logger) {
 	controller.coolDown(runtime, logger)
 	controller.reborn(runtime, logger)
+	controller.freeze(runtime, logger)
+}
+
+// freeze
+func (controller *Controller) freeze(runtime *rtctrl.RuntimeInfo, logger *logs.Logger) {
+	if controller.runOptions.RuntimeFreezeIdle <= 0 {
+		return
+	}
+	if err := controller.runtimeDispatcher.FreezeRuntime(runtime); err != nil {
+		logger.V(9).Infof("freeze runtime %s skipped: %s", runtime.RuntimeID, err)
+	}
+}
+
+// runtimeFreezer freezes runtimes through funclet
+type runtimeFreezer struct {
+	client client.FuncletInterface
+}
+
+func (f *runtimeFreezer) Freeze(rt *rtctrl.RuntimeInfo) error {
+	return f.update(rt, api.Frozen)
+}
+
+func (f *runtimeFreezer) Thaw(rt *rtctrl.RuntimeInfo) error {
+	return f.update(rt, api.Thawed)
+}
+
+func (f *runtimeFreezer) update(rt *rtctrl.RuntimeInfo, state api.FreezerState) error {
+	_, err := f.client.Freeze(&api.FuncletClientFreezeInput{
+		ContainerID: rt.RuntimeID,
+		RequestID:   id.GetRequestID(),
+		State:       state,
+	})
+	return err
 }
 
 // coolDown
@@ -128,6 +215,11 @@ func (controller *Controller) coolDown(runtime *rtctrl.RuntimeInfo, logger *logs
 	if err != nil {
 		return
 	}
+	controller.resetStoppedRuntime(runtime, recommend, logger)
+}
+
+// resetStoppedRuntime cools down a stopped runtime through funclet and releases its resource
+func (controller *Controller) resetStoppedRuntime(runtime *rtctrl.RuntimeInfo, recommend *api.ScaleDownRecommendation, logger *logs.Logger) (err error) {
 	res := runtime.Resource.Copy()
 	used := runtime.Used
 	marked := runtime.Marked
@@ -190,6 +282,7 @@ func (controller *Controller) coolDown(runtime *rtctrl.RuntimeInfo, logger *logs
 		}
 		logger.Errorf("scale down runtime %s failed: %v", runtime.RuntimeID, response.ScaleDownResult.Fails)
 	}
+	return err
 }
 
 func (controller *Controller) reborn(runtime *rtctrl.RuntimeInfo, logger *logs.Logger) {
@@ -19,7 +19,12 @@ package controller
 
 import (
 	"github.com/baidu/easyfaas/cmd/controller/options"
+	"github.com/baidu/easyfaas/pkg/controller/destination"
+	"github.com/baidu/easyfaas/pkg/controller/eventqueue"
 	"github.com/baidu/easyfaas/pkg/controller/function"
+	"github.com/baidu/easyfaas/pkg/controller/predictor"
+	"github.com/baidu/easyfaas/pkg/controller/ratelimit"
+	"github.com/baidu/easyfaas/pkg/controller/resultstore"
 	"github.com/baidu/easyfaas/pkg/controller/rtctrl"
 	"github.com/baidu/easyfaas/pkg/funclet/client"
 )
@@ -32,6 +37,16 @@ type Controller struct {
 	dataStorer            function.DataStorer
 	insideDataStorer      function.DataStorer
 	httpTriggerDataStorer function.DataStorer
+	eventQueue            *eventqueue.Queue
+	concurrency           *concurrencyLimiter
+	provisioner           *provisioner
+	predictor             *predictor.Predictor
+	results               *resultstore.Store
+	webhook               *destination.Webhook
+	destinations          *destination.Dispatcher
+	rateLimiter           *ratelimit.Limiter
+	drain                 *drainer
+	breaker               *warmUpBreaker
 }
 
 // Clients save all clients to make rpc calls
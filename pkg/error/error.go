@@ -71,6 +71,7 @@ const (
 	RequestTimeoutException      ErrorType = "RequestTimeoutException"
 	AccountProblemException      ErrorType = "AccountProblemException"
 	InvalidInvokeCallerException ErrorType = "InvalidInvokeCallerException"
+	ServiceUnavailableException  ErrorType = "ServiceUnavailableException"
 )
 
 // Define error message that will be returned in http body
@@ -330,6 +331,17 @@ func NewTooManyRequestsException(cause string, lasterr error) FinalError {
 	}, lasterr)
 }
 
+// NewServiceUnavailableException creates a ServiceUnavailableException
+// HTTP status code is StatusServiceUnavailable (503)
+func NewServiceUnavailableException(cause string, lasterr error) FinalError {
+	return NewGenericException(BasicError{
+		Code:    ServiceUnavailableException,
+		Cause:   cause,
+		Message: "Service is temporarily unavailable",
+		Status:  http.StatusServiceUnavailable,
+	}, lasterr)
+}
+
 func NewResourceNotFoundException(cause string, lasterr error) FinalError {
 	return NewGenericException(BasicError{
 		Code:    ResourceNotFoundException,
@@ -58,6 +58,7 @@ type FuncletInterface interface {
 	IDEWarmUp(*api.FuncletClientWarmUpInput) (*api.WarmUpResponse, error)
 	WarmUp(*api.FuncletClientWarmUpInput) (*api.WarmUpResponse, error)
 	CoolDown(*api.FuncletClientCoolDownInput) (*api.ResetResponse, error)
+	Freeze(*api.FuncletClientFreezeInput) (*api.FreezeResponse, error)
 	Reborn(*api.FuncletClientRebornInput) (*api.ResetResponse, error)
 	NodeInfo(*api.FuncletClientListNodeInput) (*api.FuncletNodeInfo, error)
 }
@@ -183,6 +184,29 @@ func (f *FuncletClient) CoolDown(input *api.FuncletClientCoolDownInput) (out *ap
 	return out, nil
 }
 
+func (f *FuncletClient) Freeze(input *api.FuncletClientFreezeInput) (out *api.FreezeResponse, err error) {
+	body := api.FreezeRequest{
+		ContainerID: input.ContainerID,
+		RequestID:   input.RequestID,
+		State:       input.State,
+	}
+	out = &api.FreezeResponse{}
+	req := f.client.Post().
+		BaseURL(baseURL).
+		Resource("funclet/freezer").
+		Body(body).
+		Timeout(timeout)
+
+	if input.RequestID != "" {
+		req = req.SetHeader(api.HeaderXRequestID, input.RequestID)
+	}
+
+	if err := req.Do().Into(out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (f *FuncletClient) Reborn(input *api.FuncletClientRebornInput) (out *api.ResetResponse, err error) {
 	body := api.ResetRequest{
 		ContainerID:             input.ContainerID,
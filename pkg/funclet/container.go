@@ -124,6 +124,16 @@ func (m *ContainerMap) UpdateContainerStreamMode(id string, streamMode bool) (er
 	return nil
 }
 
+// UpdateContainerFunction keeps the function loaded in the container, nil when it is reset
+func (m *ContainerMap) UpdateContainerFunction(id string, fn *api.ContainerFunction) (err error) {
+	info, exist := m.Exist(id)
+	if !exist {
+		return ContainerNotExists
+	}
+	info.Function = fn
+	return nil
+}
+
 func InitContainerMap(podName string, num int) *ContainerMap {
 	cMap := &ContainerMap{
 		CMap: sync.Map{},
@@ -0,0 +1,49 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package funclet
+
+import (
+	"github.com/baidu/easyfaas/pkg/api"
+	svcErr "github.com/baidu/easyfaas/pkg/error"
+	funcletCtx "github.com/baidu/easyfaas/pkg/funclet/context"
+)
+
+// FreezeContainerEvent freezes or thaws the processes of an idle container
+func (f *Funclet) FreezeContainerEvent(ctx *funcletCtx.Context, params *api.FreezeRequest) (err error) {
+	containerID := params.ContainerID
+	if params.State != api.Frozen && params.State != api.Thawed {
+		return svcErr.NewInvalidParameterValueException("invalid freezer state "+string(params.State), nil)
+	}
+
+	if _, err = f.ContainerManager.LockContainer(containerID, api.EventFreeze, ctx); err != nil {
+		ctx.Logger.WithField("containerID", containerID).Errorf("get lock failed: %s", err)
+		return err
+	}
+	defer f.ContainerManager.UnLockContainerWithLog(containerID, ctx)
+
+	if params.State == api.Frozen {
+		err = f.RuntimeClient.FrozenContainer(containerID)
+	} else {
+		err = f.RuntimeClient.ThawContainer(containerID)
+	}
+	if err != nil {
+		ctx.Logger.Errorf("update container %s freezer state %s failed: %s", containerID, params.State, err)
+		return err
+	}
+	ctx.Logger.Infof("update container %s freezer state %s", containerID, params.State)
+	return nil
+}
@@ -0,0 +1,84 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package funclet
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/baidu/easyfaas/pkg/api"
+	"github.com/baidu/easyfaas/pkg/util/json"
+)
+
+// functionMetaFile keeps the function loaded in the container next to its runner spec,
+// so it lives as long as the container state on disk
+const functionMetaFile = "function.json"
+
+// setContainerFunction keeps the function loaded in the container in memory and on disk, nil when it is reset
+func (f *Funclet) setContainerFunction(containerID string, fn *api.ContainerFunction) error {
+	if err := f.ContainerManager.ContainerMap.UpdateContainerFunction(containerID, fn); err != nil {
+		return err
+	}
+	paths, err := f.PathManager.GetPaths(containerID)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(paths.RunnerSpecPath, functionMetaFile)
+	if fn == nil {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.Marshal(fn)
+	if err != nil {
+		return err
+	}
+	// written to a temporary file and renamed, so a crash never leaves partial metadata
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// loadedFunction returns the function loaded in the container, read from disk when it is not cached
+func (f *Funclet) loadedFunction(cacheInfo *api.ContainerInfo) *api.ContainerFunction {
+	if cacheInfo.Function != nil {
+		return cacheInfo.Function
+	}
+	paths, err := f.PathManager.GetPaths(cacheInfo.ContainerID)
+	if err != nil {
+		return nil
+	}
+	data, err := ioutil.ReadFile(filepath.Join(paths.RunnerSpecPath, functionMetaFile))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			f.logger.Warnf("read function of container %s failed: %s", cacheInfo.ContainerID, err)
+		}
+		return nil
+	}
+	fn := &api.ContainerFunction{}
+	if err := json.Unmarshal(data, fn); err != nil {
+		f.logger.Warnf("unmarshal function of container %s failed: %s", cacheInfo.ContainerID, err)
+		return nil
+	}
+	f.ContainerManager.ContainerMap.UpdateContainerFunction(cacheInfo.ContainerID, fn)
+	return fn
+}
@@ -0,0 +1,78 @@
+/*
+ * Copyright (c) 2020 Baidu, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package funclet
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/baidu/easyfaas/pkg/api"
+	"github.com/baidu/easyfaas/pkg/funclet/file"
+	"github.com/baidu/easyfaas/pkg/util/logs"
+)
+
+func TestContainerFunctionPersisted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "funclet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	pc := &file.PathConfig{
+		RunnerSpecPath: filepath.Join(dir, "spec"),
+		RunnerDataPath: filepath.Join(dir, "data"),
+		RunnerTmpPath:  filepath.Join(dir, "tmp"),
+		EtcPath:        filepath.Join(dir, "spec", "%s"),
+		ConfPath:       filepath.Join(dir, "conf", "%s"),
+		CodePath:       filepath.Join(dir, "code", "%s"),
+		RuntimePath:    filepath.Join(dir, "runtime", "%s"),
+	}
+	newFunclet := func() *Funclet {
+		return &Funclet{
+			ContainerManager: &ContainerManager{ContainerMap: InitContainerMap("test", 1)},
+			PathManager:      file.NewPathManager(pc, make(chan string, 1), make(chan string, 1)),
+			logger:           logs.NewLogger(),
+		}
+	}
+	f := newFunclet()
+	containerID := generateContainerID("test", 0)
+	cp, err := f.PathManager.GeneratePaths(containerID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fn := &api.ContainerFunction{CommitID: "commit-1", FunctionBrn: "brn", MemorySize: 128}
+	if err := f.setContainerFunction(containerID, fn); err != nil {
+		t.Fatal(err)
+	}
+
+	// a funclet without the cached metadata reads it next to the container state
+	restarted := newFunclet()
+	restarted.PathManager = f.PathManager
+	info, _ := restarted.ContainerManager.ContainerMap.GetContainer(containerID)
+	if loaded := restarted.loadedFunction(info); loaded == nil || *loaded != *fn {
+		t.Errorf("expect function %+v loaded, got %+v", fn, loaded)
+	}
+
+	if err := f.setContainerFunction(containerID, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(cp.RunnerSpecPath, functionMetaFile)); !os.IsNotExist(err) {
+		t.Errorf("expect function metadata removed on reset, got %v", err)
+	}
+}
@@ -65,6 +65,11 @@ func (f *Funclet) InstallAPI(container *restful.Container) {
 			Path:    "funclet/cooldown",
 			Handler: server.WrapRestRouteFunc(f.CoolDownHandler),
 		},
+		{
+			Verb:    "POST",
+			Path:    "funclet/freezer",
+			Handler: server.WrapRestRouteFunc(f.FreezerHandler),
+		},
 		{
 			Verb:    "POST",
 			Path:    "funclet/reborn",
@@ -205,6 +210,25 @@ func (f *Funclet) CoolDownHandler(c *server.Context) {
 	response.WriteHeaderAndEntity(http.StatusOK, res)
 }
 
+func (f *Funclet) FreezerHandler(c *server.Context) {
+	response := c.Response()
+	logger := c.Logger()
+
+	params := api.FreezeRequest{}
+	if err := c.Request().ReadEntity(&params); err != nil {
+		c.WithWarnLog(err).WriteTo(response)
+		return
+	}
+	logger.V(6).Infof("update container %s freezer state %s start", params.ContainerID, params.State)
+	defer logger.TimeTrack(time.Now(), "update container freezer state finish")
+	fCtx := f.NewContext(c.RequestID(), logger)
+	if err := f.FreezeContainerEvent(fCtx, &params); err != nil {
+		c.WithErrorLog(err).WriteTo(response)
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusOK, api.FreezeResponse{ContainerID: params.ContainerID, State: params.State})
+}
+
 func (f *Funclet) RebornHandler(c *server.Context) {
 	response := c.Response()
 	logger := c.Logger()
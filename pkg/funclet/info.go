@@ -59,5 +59,6 @@ func (f *Funclet) ContainerInfo(ID string) (info *api.ContainerInfo, err error)
 		IsFrozen:       isFrozen,
 		Resource:       cr,
 		ResourceStats:  stats,
+		Function:       f.loadedFunction(cacheInfo),
 	}, nil
 }
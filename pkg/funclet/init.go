@@ -104,6 +104,9 @@ func (f *Funclet) InitContainer(ctx *funcletCtx.Context) (err error) {
 	}
 
 	f.ContainerManager.ContainerMap.UpdateContainerStreamMode(containerID, false)
+	if err := f.setContainerFunction(containerID, nil); err != nil {
+		ctx.Logger.Warnf("clear function of container %s failed: %s", containerID, err)
+	}
 	f.ContainerManager.ContainerMap.UpdateContainerPid(containerID, info.Pid)
 	return nil
 }
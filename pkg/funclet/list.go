@@ -78,6 +78,7 @@ func (f *Funclet) ListAllContainers() (list []*api.ContainerInfo, err error) {
 				WithStreamMode: cacheInfo.WithStreamMode,
 				IsFrozen:       isFrozen,
 				Resource:       resource,
+				Function:       f.loadedFunction(cacheInfo),
 			})
 		} else {
 			cInfo := v.(*api.ContainerInfo)
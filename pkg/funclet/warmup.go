@@ -170,9 +170,29 @@ Loop:
 		}
 	}
 
+	if err := f.setContainerFunction(containerID, containerFunction(params.Configuration)); err != nil {
+		ctx.Logger.Warnf("save function of container %s failed: %s", containerID, err)
+	}
 	return nil
 }
 
+// containerFunction extracts the metadata to restore the runtime after controller restarted
+func containerFunction(config *api.FunctionConfig) *api.ContainerFunction {
+	fn := &api.ContainerFunction{
+		FunctionBrn: config.FunctionArn,
+	}
+	if config.CommitID != nil {
+		fn.CommitID = *config.CommitID
+	}
+	if config.MemorySize != nil {
+		fn.MemorySize = int64(*config.MemorySize)
+	}
+	if config.PodConcurrentQuota != nil {
+		fn.ConcurrentMode = *config.PodConcurrentQuota > 0
+	}
+	return fn
+}
+
 // GetUserCodePath
 func (f *Funclet) GetUserCodePath(codeSha256 string) string {
 	return filepath.Join(f.Options.CachePath, codeSha256)
@@ -256,6 +276,10 @@ func (f *Funclet) prepareRuntimeConf(ctx *funcletCtx.Context, warmUpContainerArg
 		variables["BCE_CFC_RUNTIME_MODE"] = "stream"
 		variables["BCE_CFC_HTTP_SOCKET"] = filepath.Join(f.Options.RunnerSpecOption.TargetRuntimeSocketPath, RuntimeHTTPSock)
 	}
+	if f.Options.RuntimeAPIAddress != "" && warmUpContainerArgs.RuntimeAPIToken != "" {
+		variables["AWS_LAMBDA_RUNTIME_API"] = fmt.Sprintf("%s/runtimes/%s/%s",
+			f.Options.RuntimeAPIAddress, ctx.Container.ContainerID, warmUpContainerArgs.RuntimeAPIToken)
+	}
 	f.prepareEnvConf(envConfPath, ctx.Container.Hostname, variables)
 
 	mf, err := os.Create(metaConfPath)
@@ -70,7 +70,7 @@ func RequestController(ctx *ProxyContext) {
 		ctx.RouteCtx.Response.Header.Set(key, value)
 	}
 	ctx.RouteCtx.SetStatusCode(c)
-	if ctx.WithStreamMode {
+	if ctx.WithStreamMode || bs != nil {
 		ctx.RouteCtx.Response.SetBodyStream(bs, -1)
 	} else {
 		ctx.RouteCtx.Write(b)
@@ -111,7 +111,8 @@ func makeResponse(resp *api.InvokeResponse, c *ProxyContext) (statusCode int, he
 		return
 	}
 
-	if c.WithStreamMode {
+	// generic runtimes may stream the response as well
+	if c.WithStreamMode || resp.BodyStream() != nil {
 		statusCode = http.StatusOK
 		header = *resp.Headers()
 		bodyStream = resp.BodyStream()
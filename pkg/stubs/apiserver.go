@@ -55,6 +55,7 @@ func installApiserver(router *routing.Router, options *options.StubsOptions) {
 	routerGroup.Get("/functions/<functionName>", GetFunctionHandler(options)).
 		Post(CreateFunctionHandler(options))
 	routerGroup.Get("/runtimes/<runtimeName>/configuration", GetRuntimeHandler(options))
+	routerGroup.Get("/concurrencies", ListReservedConcurrencyHandler(options))
 }
 
 func CreateFunctionHandler(options *options.StubsOptions) routing.Handler {
@@ -143,6 +144,47 @@ func GetFunctionHandler(options *options.StubsOptions) routing.Handler {
 	}
 }
 
+// ListReservedConcurrencyHandler lists the functions with reserved concurrency in the function dir
+func ListReservedConcurrencyHandler(options *options.StubsOptions) routing.Handler {
+	return func(c *routing.Context) error {
+		dirs, err := ioutil.ReadDir(options.FunctionDir)
+		if err != nil {
+			return ErrResponse(c, http.StatusInternalServerError, err)
+		}
+		out := api.ListReservedConcurrencyOutput{Functions: make([]*api.ReservedConcurrency, 0)}
+		for _, dir := range dirs {
+			if !dir.IsDir() {
+				continue
+			}
+			metaData, err := ioutil.ReadFile(filepath.Join(options.FunctionDir, dir.Name(), "meta.json"))
+			if err != nil {
+				continue
+			}
+			function := api.GetFunctionOutput{}
+			if err := json.Unmarshal(metaData, &function); err != nil {
+				logs.Warnf("unmarshal function meta %s failed: %s", dir.Name(), err)
+				continue
+			}
+			if function.Concurrency == nil || function.Concurrency.ReservedConcurrentExecutions == nil ||
+				function.Configuration == nil || function.Configuration.FunctionArn == nil {
+				continue
+			}
+			out.Functions = append(out.Functions, &api.ReservedConcurrency{
+				FunctionBrn:                  *function.Configuration.FunctionArn,
+				Uid:                          function.Configuration.Uid,
+				ReservedConcurrentExecutions: *function.Concurrency.ReservedConcurrentExecutions,
+			})
+		}
+		data, err := json.Marshal(out)
+		if err != nil {
+			return ErrResponse(c, http.StatusInternalServerError, err)
+		}
+		c.SetStatusCode(http.StatusOK)
+		c.Write(data)
+		return nil
+	}
+}
+
 func GetRuntimeHandler(options *options.StubsOptions) routing.Handler {
 	return func(c *routing.Context) error {
 		logs.Infof("get runtime")
@@ -26,7 +26,8 @@ import (
 
 var fastjson = jsoniter.ConfigCompatibleWithStandardLibrary
 
-type RawMessage stdjson.RawMessage
+// RawMessage is the same type as json.RawMessage, so that it is kept raw by Unmarshal
+type RawMessage = stdjson.RawMessage
 
 type Number stdjson.Number
 